@@ -1,18 +1,32 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/flapflapio/simulator/core/app"
 	"github.com/flapflapio/simulator/core/controllers"
+	"github.com/flapflapio/simulator/core/controllers/conversioncontroller"
+	"github.com/flapflapio/simulator/core/controllers/diffcontroller"
+	"github.com/flapflapio/simulator/core/controllers/exercisecontroller"
+	"github.com/flapflapio/simulator/core/controllers/generatorcontroller"
+	"github.com/flapflapio/simulator/core/controllers/machinecontroller"
+	"github.com/flapflapio/simulator/core/controllers/rendercontroller"
 	"github.com/flapflapio/simulator/core/controllers/schemacontroller"
 	"github.com/flapflapio/simulator/core/controllers/simulationcontroller"
+	"github.com/flapflapio/simulator/core/controllers/suitecontroller"
+	"github.com/flapflapio/simulator/core/services/exercisestore"
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/services/resultcache"
 	"github.com/flapflapio/simulator/core/services/simulatorservice"
+	"github.com/flapflapio/simulator/core/services/storage"
+	"github.com/flapflapio/simulator/core/simulation"
 )
 
 var (
@@ -20,6 +34,11 @@ var (
 	srv = app.New(cfg)
 	sim = simulatorservice.New()
 
+	database  = openDatabase()
+	machines  = openMachineStore()
+	exercises = openExerciseStore()
+	results   = newResultCache()
+
 	// Add any new middlewares to this slice - mids is added in
 	// reverse order (i.e. mids at the top of this slice is applied
 	// first)
@@ -32,7 +51,22 @@ var (
 	// Add any new cntrls to this slice
 	cntrls = []controllers.Controller{
 		schemacontroller.New(),
-		simulationcontroller.New(sim),
+		conversioncontroller.New(),
+		diffcontroller.New(),
+		rendercontroller.New().WithBudget(budget),
+		machinecontroller.New(machines),
+		exercisecontroller.New(exercises).WithBudget(budget),
+		suitecontroller.New().WithBudget(budget),
+		generatorcontroller.New(),
+		simulationcontroller.New(sim).
+			WithBudget(budget).
+			WithMachines(machines).
+			WithCache(results),
+	}
+
+	budget = simulation.Budget{
+		MaxSteps: cfg.MaxSteps,
+		Timeout:  time.Duration(cfg.MaxRunTime) * time.Second,
 	}
 )
 
@@ -59,6 +93,56 @@ func configure() app.Config {
 	return config
 }
 
+// The SQLite database at `Database`, shared by the stores, or nil if no
+// database is configured
+func openDatabase() *sql.DB {
+	if cfg.Database == nil || *cfg.Database == "" {
+		return nil
+	}
+	db, err := storage.OpenSqlite(*cfg.Database)
+	if err != nil {
+		log.Println("An error occured while opening the database")
+		log.Fatalf("%v\n", err)
+	}
+	return db
+}
+
+// Keeps the machine library in the database, or in memory if no database is
+// configured
+func openMachineStore() machinestore.Store {
+	if database == nil {
+		return machinestore.NewMemoryStore()
+	}
+	store, err := machinestore.NewSqliteStore(database)
+	if err != nil {
+		log.Println("An error occured while opening the machine database")
+		log.Fatalf("%v\n", err)
+	}
+	return store
+}
+
+// Keeps exercises in the same database as the machine library
+func openExerciseStore() exercisestore.Store {
+	if database == nil {
+		return exercisestore.NewMemoryStore()
+	}
+	store, err := exercisestore.NewSqliteStore(database)
+	if err != nil {
+		log.Println("An error occured while opening the exercise database")
+		log.Fatalf("%v\n", err)
+	}
+	return store
+}
+
+// Caches the results of simulations, unless the cache is turned off with a
+// `CacheSize` of 0
+func newResultCache() *resultcache.ResultCache {
+	if cfg.CacheSize == 0 {
+		return nil
+	}
+	return resultcache.New(cfg.CacheSize, time.Duration(cfg.CacheTTL)*time.Second)
+}
+
 var (
 	healthcheck = flag.Bool(
 		"health",
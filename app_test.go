@@ -49,7 +49,7 @@ func TestSimulate(t *testing.T) {
 		{
 			name:  "POST success",
 			route: "/simulate?tape=aaba",
-			body:  `{"Accepted":true,"Path":["q0","q1","q0","q0","q1"],"RemainingInput":""}`,
+			body:  `{"Accepted":true,"Path":["q0","q1","q0","q0","q1"],"RemainingInput":"","Outcome":"Accepted"}`,
 		},
 	}
 
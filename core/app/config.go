@@ -6,7 +6,7 @@ import (
 	"strconv"
 	"sync"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 const CONFIG_FILENAME = "config.yml"
@@ -21,6 +21,10 @@ var defaultConfig = Config{
 	ReadTimeout:    60,
 	WriteTimeout:   60,
 	MaxHeaderBytes: 4096,
+	MaxSteps:       10000000,
+	MaxRunTime:     10,
+	CacheSize:      1000,
+	CacheTTL:       600,
 }
 
 type Config struct {
@@ -28,8 +32,13 @@ type Config struct {
 	ReadTimeout    int       `json:"ReadTimeout"`
 	WriteTimeout   int       `json:"WriteTimeout"`
 	MaxHeaderBytes int       `json:"MaxHeaderBytes"`
+	MaxSteps       int       `json:"MaxSteps"`
+	MaxRunTime     int       `json:"MaxRunTime"`
+	CacheSize      int       `json:"CacheSize"`
+	CacheTTL       int       `json:"CacheTTL"`
 	Name           *string   `json:"Name"`
 	CORS           *[]string `json:"CORS"`
+	Database       *string   `json:"Database"`
 }
 
 // Reads parameters from `config.yml` and from env vars. The first time this
@@ -68,8 +77,13 @@ func ReadConfig(filename string) (Config, error) {
 		ReadTimeout:    extractIntOrMinusOne(cfg, "ReadTimeout"),
 		WriteTimeout:   extractIntOrMinusOne(cfg, "WriteTimeout"),
 		MaxHeaderBytes: extractIntOrMinusOne(cfg, "MaxHeaderBytes"),
+		MaxSteps:       extractIntOrMinusOne(cfg, "MaxSteps"),
+		MaxRunTime:     extractIntOrMinusOne(cfg, "MaxRunTime"),
+		CacheSize:      extractIntOrMinusOne(cfg, "CacheSize"),
+		CacheTTL:       extractIntOrMinusOne(cfg, "CacheTTL"),
 		Name:           extractString(cfg, "Name"),
 		CORS:           extractSlice(cfg, "CORS"),
+		Database:       extractString(cfg, "Database"),
 	}, nil
 }
 
@@ -79,7 +93,12 @@ func ReadEnvVarsConfig() Config {
 		ReadTimeout:    getEnvInt("READ_TIMEOUT", -1),
 		WriteTimeout:   getEnvInt("WRITE_TIMEOUT", -1),
 		MaxHeaderBytes: getEnvInt("MAX_HEADER_BYTES", -1),
+		MaxSteps:       getEnvInt("MAX_STEPS", -1),
+		MaxRunTime:     getEnvInt("MAX_RUN_TIME", -1),
+		CacheSize:      getEnvInt("CACHE_SIZE", -1),
+		CacheTTL:       getEnvInt("CACHE_TTL", -1),
 		Name:           getEnvString("NAME", nil),
+		Database:       getEnvString("DATABASE", nil),
 	}
 }
 
@@ -89,8 +108,13 @@ func MergeConfigs(cfg1 Config, cfg2 Config) Config {
 		ReadTimeout:    takeNonNegative(cfg1.ReadTimeout, cfg2.ReadTimeout),
 		WriteTimeout:   takeNonNegative(cfg1.WriteTimeout, cfg2.WriteTimeout),
 		MaxHeaderBytes: takeNonNegative(cfg1.MaxHeaderBytes, cfg2.MaxHeaderBytes),
+		MaxSteps:       takeNonNegative(cfg1.MaxSteps, cfg2.MaxSteps),
+		MaxRunTime:     takeNonNegative(cfg1.MaxRunTime, cfg2.MaxRunTime),
+		CacheSize:      takeNonNegative(cfg1.CacheSize, cfg2.CacheSize),
+		CacheTTL:       takeNonNegative(cfg1.CacheTTL, cfg2.CacheTTL),
 		Name:           takeNonNilStr(cfg1.Name, cfg2.Name),
 		CORS:           takeNonNilSlice(cfg1.CORS, cfg2.CORS),
+		Database:       takeNonNilStr(cfg1.Database, cfg2.Database),
 	}
 }
 
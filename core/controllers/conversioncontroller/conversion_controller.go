@@ -0,0 +1,101 @@
+package conversioncontroller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/jflap"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_JFLAP_FILE_MSG = "The JFLAP file that was sent could not be imported"
+
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	PLEASE_PROVIDE_A_MACHINE_MSG = `` +
+		`{"Err":"Please provide a machine with query param 'machine'"}`
+
+	jflapFilename = "machine.jff"
+)
+
+// Converts machines to and from the file formats of other tools
+type ConversionController struct {
+	prefix string
+}
+
+func New() *ConversionController {
+	return &ConversionController{prefix: "/"}
+}
+
+func (c *ConversionController) WithPrefix(prefix string) *ConversionController {
+	return &ConversionController{prefix: app.Trim(prefix)}
+}
+
+// Attaches this controller to the given router
+func (c *ConversionController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("POST").Path("/import/jflap").HandlerFunc(ImportJflap)
+	r.Methods("GET", "POST").Path("/export/jflap").HandlerFunc(ExportJflap)
+}
+
+// Converts the JFLAP file (.jff) in the request body into a machine document.
+// If successful: 200 + the machine, in JSON or YAML (see `utils.WriteDocument`).
+// If the file cannot be converted: 422 + the reason.
+func ImportJflap(rw http.ResponseWriter, r *http.Request) {
+	doc, err := jflap.Import(r.Body)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_JFLAP_FILE_MSG, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, doc)
+}
+
+// Converts a machine into a JFLAP file. The machine is sent in the body of a
+// POST, or in query param 'machine' of a GET.
+// If successful: 200 + the .jff file as an attachment.
+// If the machine is invalid or cannot be represented in JFLAP: 422.
+func ExportJflap(rw http.ResponseWriter, r *http.Request) {
+	var m simulation.Machine
+	var err error
+	if r.Method == http.MethodGet {
+		doc := r.URL.Query().Get("machine")
+		if doc == "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(PLEASE_PROVIDE_A_MACHINE_MSG))
+			return
+		}
+		m, err = utils.LoadMachineFrom(r, []byte(doc))
+	} else {
+		m, err = utils.LoadMachine(r)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+
+	data, err := jflap.Export(m)
+	if err != nil {
+		msg := "The machine could not be converted to a JFLAP file"
+		if errors.Is(err, jflap.ErrUnsupported) {
+			msg = "The machine cannot be represented in JFLAP"
+		}
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, msg, err)
+		return
+	}
+
+	rw.Header().Del("Content-Disposition")
+	rw.Header().Add(
+		"Content-Disposition",
+		fmt.Sprintf("attachment; filename=\"%s\"", jflapFilename))
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", "application/xml; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(data)
+}
@@ -0,0 +1,116 @@
+package conversioncontroller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportJflap(t *testing.T) {
+	file, err := os.ReadFile("../../simulation/jflap/testdata/odd-a.jff")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		accept string
+		status int
+		check  func(t *testing.T, body string)
+	}{
+		{
+			name:   "json",
+			body:   string(file),
+			status: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, `"Start":"even"`)
+				assert.Contains(t, body, `"Meta":{"X":84,"Y":122}`)
+			},
+		},
+		{
+			name:   "yaml",
+			body:   string(file),
+			accept: "application/yaml",
+			status: http.StatusOK,
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, "Start: even\n")
+			},
+		},
+		{
+			name:   "unsupported",
+			body:   "<structure><type>mealy</type></structure>",
+			status: http.StatusUnprocessableEntity,
+			check: func(t *testing.T, body string) {
+				assert.Contains(t, body, INVALID_JFLAP_FILE_MSG)
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest("POST", "/import/jflap", strings.NewReader(tc.body))
+			req.Header.Set("Accept", tc.accept)
+			recorder := serve(req)
+			assert.Equal(t, tc.status, recorder.Code)
+			tc.check(t, recorder.Body.String())
+		})
+	}
+}
+
+func TestExportJflap(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		request *http.Request
+		status  int
+	}{
+		{
+			name:    "post",
+			request: httptest.NewRequest("POST", "/export/jflap", strings.NewReader(dfa.ODDA)),
+			status:  http.StatusOK,
+		},
+		{
+			name: "get",
+			request: httptest.NewRequest("GET",
+				"/export/jflap?machine="+url.QueryEscape(dfa.ODDA), nil),
+			status: http.StatusOK,
+		},
+		{
+			name:    "get-without-machine",
+			request: httptest.NewRequest("GET", "/export/jflap", nil),
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid-machine",
+			request: httptest.NewRequest("POST", "/export/jflap", strings.NewReader("{}")),
+			status:  http.StatusUnprocessableEntity,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := serve(tc.request)
+			assert.Equal(t, tc.status, recorder.Code)
+			if tc.status != http.StatusOK {
+				return
+			}
+			assert.Equal(t,
+				"application/xml; charset=utf-8",
+				recorder.Header().Get("Content-Type"))
+			assert.Contains(t, recorder.Body.String(), "<type>fa</type>")
+		})
+	}
+}
+
+func serve(req *http.Request) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	New().Attach(router)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
@@ -0,0 +1,204 @@
+package diffcontroller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	INVALID_PATCH_MSG = "The patch could not be applied to the machine"
+
+	INVALID_PAIR_REQUEST_MSG = `` +
+		`{"Err":"The body must be a JSON object with the machines to compare ` +
+		`in 'From' and 'To'"}`
+
+	INVALID_PATCH_REQUEST_MSG = `` +
+		`{"Err":"The body must be a JSON object with a machine in 'Machine' ` +
+		`and a JSON Patch (a list) or merge patch (an object) in 'Patch'"}`
+)
+
+// Compares and patches machines sent in the request, so that editors can
+// exchange small edits instead of whole documents
+type DiffController struct {
+	prefix string
+}
+
+func New() *DiffController {
+	return &DiffController{prefix: "/"}
+}
+
+func (c *DiffController) WithPrefix(prefix string) *DiffController {
+	return &DiffController{prefix: app.Trim(prefix)}
+}
+
+// Attaches this controller to the given router
+func (c *DiffController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("POST").Path("/diff").HandlerFunc(Diff)
+	r.Methods("POST").Path("/patch").HandlerFunc(Patch)
+	r.Methods("POST").Path("/canonical").HandlerFunc(Canonical)
+	r.Methods("POST").Path("/isomorphic").HandlerFunc(Isomorphic)
+}
+
+// Compares the machines in 'From' and 'To' of the body structurally (see
+// `machine.Diff`).
+// If successful: 200 + the changes, in JSON or YAML.
+// If either machine is invalid: 422 + a list of diagnostics, pointing into
+// the body.
+func Diff(rw http.ResponseWriter, r *http.Request) {
+	from, to, ok := loadPair(rw, r)
+	if !ok {
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, machine.Diff(from, to))
+}
+
+// Whether the machines in 'From' and 'To' of the body are the same up to the
+// names of their states (see `machine.Isomorphic`).
+// If successful: 200 + {"Isomorphic": true|false}, in JSON or YAML.
+// If either machine is invalid: 422 + a list of diagnostics, pointing into
+// the body.
+func Isomorphic(rw http.ResponseWriter, r *http.Request) {
+	from, to, ok := loadPair(rw, r)
+	if !ok {
+		return
+	}
+	isomorphic, err := machine.Isomorphic(from, to)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{"Isomorphic": isomorphic})
+}
+
+// The canonical form and hash of the machine in the body (see
+// `machine.Canonical`).
+// If successful: 200 + {"Hash", "Machine"}, in JSON or YAML.
+// If the machine is invalid: 422 + a list of diagnostics.
+func Canonical(rw http.ResponseWriter, r *http.Request) {
+	doc, err := utils.LoadDocument(r)
+	if err == nil {
+		err = utils.CheckDocument(doc)
+	}
+	var canonical map[string]interface{}
+	var hash string
+	if err == nil {
+		canonical, err = machine.Canonical(doc)
+	}
+	if err == nil {
+		hash, err = machine.Hash(doc)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{
+		"Hash":    hash,
+		"Machine": canonical,
+	})
+}
+
+// Applies the patch in 'Patch' of the body to the machine in 'Machine'. A
+// list is a JSON Patch (RFC 6902) and an object is a JSON Merge Patch (RFC
+// 7386).
+// If successful: 200 + the patched machine, in JSON or YAML.
+// If the machine is invalid or the patch fails: 422 + a list of diagnostics,
+// pointing into the body.
+// If the patched machine is invalid: 422 + a list of diagnostics, pointing
+// into the patched machine.
+func Patch(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Machine interface{} `json:"Machine"`
+		Patch   interface{} `json:"Patch"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	var apply func(map[string]interface{}, interface{}) (map[string]interface{}, error)
+	switch req.Patch.(type) {
+	case []interface{}:
+		apply = machine.JsonPatch
+	case map[string]interface{}:
+		apply = machine.MergePatch
+	}
+	if err != nil || req.Machine == nil || apply == nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(INVALID_PATCH_REQUEST_MSG))
+		return
+	}
+
+	doc, err := loadDocument(r, req.Machine, "Machine")
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	patched, err := apply(doc, req.Patch)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_PATCH_MSG,
+			within("Patch", err))
+		return
+	}
+	patched, err = utils.LoadDocumentFrom(r, patched)
+	if err == nil {
+		err = utils.CheckDocument(patched)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, patched)
+}
+
+// Loads the machines in 'From' and 'To' of the body. If false is returned, a
+// response has already been written
+func loadPair(rw http.ResponseWriter, r *http.Request) (from, to map[string]interface{}, ok bool) {
+	var req struct {
+		From interface{} `json:"From"`
+		To   interface{} `json:"To"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.From == nil || req.To == nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(INVALID_PAIR_REQUEST_MSG))
+		return nil, nil, false
+	}
+	from, err := loadDocument(r, req.From, "From")
+	if err == nil {
+		to, err = loadDocument(r, req.To, "To")
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return nil, nil, false
+	}
+	return from, to, true
+}
+
+// Loads and checks the machine in a field of the body
+func loadDocument(r *http.Request, src interface{}, field string) (map[string]interface{}, error) {
+	doc, err := utils.LoadDocumentFrom(r, src)
+	if err == nil {
+		err = utils.CheckDocument(doc)
+	}
+	return doc, within(field, err)
+}
+
+// Moves the diagnostics of a document into a field of the body, so that they
+// point into the body
+func within(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	diags := machine.DiagnosticsOf(err)
+	moved := make(machine.Diagnostics, len(diags))
+	for i, d := range diags {
+		d.Pointer = machine.Pointer(field) + d.Pointer
+		moved[i] = d
+	}
+	return moved
+}
@@ -0,0 +1,150 @@
+package diffcontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	reordered := strings.Replace(dfa.ODDA, `"Alphabet": "ab"`, `"Alphabet": ["b", "a"]`, 1)
+	startOdd := strings.Replace(dfa.ODDA, `"Start": "q0"`, `"Start": "q1"`, 1)
+	for _, tc := range []struct {
+		name     string
+		body     string
+		status   int
+		expected string
+		contains string
+	}{
+		{
+			name:     "same",
+			body:     fmt.Sprintf(`{"From": %v, "To": %v}`, dfa.ODDA, dfa.ODDA),
+			status:   http.StatusOK,
+			expected: `{}`,
+		},
+		{
+			name:     "alphabet-order",
+			body:     fmt.Sprintf(`{"From": %v, "To": %v}`, dfa.ODDA, reordered),
+			status:   http.StatusOK,
+			expected: `{}`,
+		},
+		{
+			name:     "start",
+			body:     fmt.Sprintf(`{"From": %v, "To": %v}`, dfa.ODDA, startOdd),
+			status:   http.StatusOK,
+			expected: `{"Start": {"From": "q0", "To": "q1"}}`,
+		},
+		{
+			name:     "invalid-machine",
+			body:     fmt.Sprintf(`{"From": %v, "To": {"Type": "DFA"}}`, dfa.ODDA),
+			status:   http.StatusUnprocessableEntity,
+			contains: `"Pointer":"/To/Start"`,
+		},
+		{
+			name:     "missing-machine",
+			body:     fmt.Sprintf(`{"From": %v}`, dfa.ODDA),
+			status:   http.StatusBadRequest,
+			contains: INVALID_PAIR_REQUEST_MSG,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := mux.NewRouter()
+			New().Attach(router)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("POST", "/diff", strings.NewReader(tc.body)))
+			assert.Equal(t, tc.status, recorder.Code, recorder.Body.String())
+			if tc.expected != "" {
+				assert.JSONEq(t, tc.expected, recorder.Body.String())
+			}
+			assert.Contains(t, recorder.Body.String(), tc.contains)
+		})
+	}
+}
+
+func TestPatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patch    string
+		status   int
+		contains string
+	}{
+		{
+			name:     "json-patch",
+			patch:    `[{ "op": "replace", "path": "/Start", "value": "q1" }]`,
+			status:   http.StatusOK,
+			contains: `"Start":"q1"`,
+		},
+		{
+			name:     "merge-patch",
+			patch:    `{ "Alphabet": ["a", "b"] }`,
+			status:   http.StatusOK,
+			contains: `"Alphabet":["a","b"]`,
+		},
+		{
+			name:     "patch-fails",
+			patch:    `[{ "op": "test", "path": "/Start", "value": "q1" }]`,
+			status:   http.StatusUnprocessableEntity,
+			contains: `"Pointer":"/Patch/0"`,
+		},
+		{
+			name:     "patched-machine-invalid",
+			patch:    `{ "Start": null }`,
+			status:   http.StatusUnprocessableEntity,
+			contains: INVALID_MACHINE_MSG,
+		},
+		{
+			name:     "not-a-patch",
+			patch:    `"remove everything"`,
+			status:   http.StatusBadRequest,
+			contains: INVALID_PATCH_REQUEST_MSG,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := mux.NewRouter()
+			New().Attach(router)
+			recorder := httptest.NewRecorder()
+			body := fmt.Sprintf(`{"Machine": %v, "Patch": %v}`, dfa.ODDA, tc.patch)
+			router.ServeHTTP(recorder, httptest.NewRequest("POST", "/patch", strings.NewReader(body)))
+			assert.Equal(t, tc.status, recorder.Code, recorder.Body.String())
+			assert.Contains(t, recorder.Body.String(), tc.contains)
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	renamed := strings.NewReplacer(`"q0"`, `"even"`, `"q1"`, `"odd"`).Replace(dfa.ODDA)
+	hash := func(doc string) string {
+		router := mux.NewRouter()
+		New().Attach(router)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("POST", "/canonical", strings.NewReader(doc)))
+		assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		var res struct {
+			Hash    string
+			Machine map[string]interface{}
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &res)
+		assert.Equal(t, "q0", res.Machine["Start"])
+		return res.Hash
+	}
+	assert.Equal(t, hash(dfa.ODDA), hash(renamed))
+
+	router := mux.NewRouter()
+	New().Attach(router)
+	recorder := httptest.NewRecorder()
+	body := fmt.Sprintf(`{"From": %v, "To": %v}`, dfa.ODDA, renamed)
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", "/isomorphic", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.JSONEq(t, `{"Isomorphic": true}`, recorder.Body.String())
+}
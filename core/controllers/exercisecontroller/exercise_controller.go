@@ -0,0 +1,405 @@
+package exercisecontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/services/exercisestore"
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/mutation"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	INVALID_REFERENCE_MSG = "" +
+		"The reference of the exercise must be a valid DFA, or a regex " +
+		"with an alphabet of single characters"
+
+	INVALID_REQUEST_MSG = `` +
+		`{"Err":"The body must be a JSON object with a 'Reference' holding ` +
+		`either a 'Machine' or a 'Regex' and its 'Alphabet', and optionally ` +
+		`'Id', 'Name', 'Description', 'Cases' and 'EquivalenceWeight'"}`
+
+	INVALID_WEIGHT_MSG = `{"Err":"Weights cannot be negative"}`
+
+	INVALID_ID_MSG = `` +
+		`{"Err":"Exercise ids must be 1 to 64 letters, digits, '-' or '_'"}`
+
+	EXERCISE_NOT_FOUND_MSG = `{"Err":"No exercise with this id was found"}`
+
+	EXERCISE_EXISTS_MSG = `{"Err":"An exercise with this id already exists"}`
+
+	STORE_FAILED_MSG = `{"Err":"Failed to access the exercises"}`
+
+	FAILED_TO_GRADE_MSG = `{"Err":"Failed to grade the machine"}`
+
+	FAILED_TO_TEST_MUTANTS_MSG = `{"Err":"Failed to test the mutants of the reference"}`
+
+	INVALID_LIMIT_MSG = `{"Err":"Query param 'limit' must be a positive integer"}`
+
+	UNKNOWN_FORMAT_MSG = `` +
+		`{"Err":"Query param 'format' must be one of 'json', 'junit' or 'tap'"}`
+)
+
+// Most mutants tested by a request, see `TestMutants`
+const maxMutants = 1000
+
+// Exercises kept in an `exercisestore.Store`, and the grading of machines
+// submitted for them
+type ExerciseController struct {
+	prefix string
+	store  exercisestore.Store
+	refs   *references
+	budget simulation.Budget
+}
+
+// The body of create and update requests
+type exerciseRequest struct {
+	Id                string       `json:"Id"`
+	Name              string       `json:"Name"`
+	Description       string       `json:"Description"`
+	Reference         *reference   `json:"Reference"`
+	Cases             []suite.Case `json:"Cases"`
+	EquivalenceWeight float64      `json:"EquivalenceWeight"`
+}
+
+type reference struct {
+	Machine  interface{} `json:"Machine"`
+	Regex    string      `json:"Regex"`
+	Alphabet string      `json:"Alphabet"`
+}
+
+func New(store exercisestore.Store) *ExerciseController {
+	return &ExerciseController{prefix: "/", store: store, refs: newReferences()}
+}
+
+func (c *ExerciseController) WithPrefix(prefix string) *ExerciseController {
+	return &ExerciseController{
+		prefix: app.Trim(prefix),
+		store:  c.store,
+		refs:   c.refs,
+		budget: c.budget,
+	}
+}
+
+// Limits each run of a submitted machine over the tape of a case
+func (c *ExerciseController) WithBudget(budget simulation.Budget) *ExerciseController {
+	return &ExerciseController{
+		prefix: c.prefix,
+		store:  c.store,
+		refs:   c.refs,
+		budget: budget,
+	}
+}
+
+// Attaches this controller to the given router
+func (c *ExerciseController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("GET").Path("/exercises").HandlerFunc(c.ListExercises)
+	r.Methods("POST").Path("/exercises").HandlerFunc(c.CreateExercise)
+	r.Methods("POST").Path("/exercises/{id}").HandlerFunc(c.CreateExercise)
+	r.Methods("GET").Path("/exercises/{id}").HandlerFunc(c.GetExercise)
+	r.Methods("PUT").Path("/exercises/{id}").HandlerFunc(c.UpdateExercise)
+	r.Methods("DELETE").Path("/exercises/{id}").HandlerFunc(c.DeleteExercise)
+	r.Methods("POST").Path("/exercises/{id}/grade").HandlerFunc(c.Grade)
+	r.Methods("POST").Path("/exercises/{id}/mutants").HandlerFunc(c.TestMutants)
+}
+
+// Lists every exercise, without their references and cases.
+// If successful: 200 + {"Exercises": [...]}, in JSON or YAML.
+func (c *ExerciseController) ListExercises(rw http.ResponseWriter, r *http.Request) {
+	list, err := c.store.List(r.Context())
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{"Exercises": list})
+}
+
+// Stores a new exercise. The id is taken from the path, or the 'Id' of the
+// body, or generated if neither is given.
+// If successful: 201 + the stored exercise, with its location in the
+// `Location` header.
+// If the reference is invalid: 422 + a list of diagnostics.
+// If the id is taken: 409.
+func (c *ExerciseController) CreateExercise(rw http.ResponseWriter, r *http.Request) {
+	e, ref, ok := c.readExercise(rw, r)
+	if !ok {
+		return
+	}
+	if id := mux.Vars(r)["id"]; id != "" {
+		if e.Id != "" && e.Id != id {
+			utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+			return
+		}
+		e.Id = id
+	}
+	if e.Id == "" {
+		e.Id = utils.NewId()
+	}
+	if e.Name == "" {
+		e.Name = e.Id
+	}
+
+	stored, err := c.store.Create(r.Context(), e)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	c.refs.put(stored, ref)
+	rw.Header().Set("Location", fmt.Sprintf("%v/exercises/%v",
+		strings.TrimSuffix(c.prefix, "/"), stored.Id))
+	utils.WriteDocument(rw, r, http.StatusCreated, stored)
+}
+
+// An exercise, with its reference and cases.
+// If successful: 200 + the exercise, in JSON or YAML.
+// If there is no such exercise: 404.
+func (c *ExerciseController) GetExercise(rw http.ResponseWriter, r *http.Request) {
+	e, err := c.store.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, e)
+}
+
+// Replaces an exercise.
+// If successful: 200 + the exercise.
+// If the reference is invalid: 422 + a list of diagnostics.
+// If there is no such exercise: 404.
+func (c *ExerciseController) UpdateExercise(rw http.ResponseWriter, r *http.Request) {
+	e, ref, ok := c.readExercise(rw, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if e.Id != "" && e.Id != id {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	e.Id = id
+	if e.Name == "" {
+		e.Name = e.Id
+	}
+
+	stored, err := c.store.Update(r.Context(), e)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	c.refs.put(stored, ref)
+	utils.WriteDocument(rw, r, http.StatusOK, stored)
+}
+
+// Deletes an exercise.
+// If successful: 200.
+// If there is no such exercise: 404.
+func (c *ExerciseController) DeleteExercise(rw http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if err := c.store.Delete(r.Context(), id); err != nil {
+		c.fail(rw, err)
+		return
+	}
+	c.refs.forget(id)
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(`{"Status":"Exercise deleted successfully"}`))
+}
+
+// Grades the machine in the body (JSON or YAML) against an exercise: the
+// machine is run over the tape of every case, and checked for equivalence
+// with the reference (see `suite.Grader`). Query param 'format' can ask for
+// the grade as a JUnit XML ('junit') or TAP ('tap') report instead, where the
+// equivalence check is one more test.
+// If successful: 200 + {"Cases", "Passed", "Failed", "Score", "Equivalent",
+// "Counterexample"}, in JSON or YAML.
+// If the machine is invalid: 422 + a list of diagnostics.
+// If there is no such exercise: 404.
+func (c *ExerciseController) Grade(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := utils.ReportFormat(r); !ok {
+		utils.WriteError(rw, http.StatusBadRequest, UNKNOWN_FORMAT_MSG)
+		return
+	}
+	e, err := c.store.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	ref, err := c.refs.get(e)
+	if err != nil {
+		log.Printf("Reference of exercise '%v' failed to load: %v", e.Id, err)
+		utils.WriteError(rw, http.StatusInternalServerError, FAILED_TO_GRADE_MSG)
+		return
+	}
+	m, err := utils.LoadMachine(r)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+
+	grade, err := suite.Grader{
+		Reference:         ref,
+		Cases:             e.Cases,
+		EquivalenceWeight: e.EquivalenceWeight,
+		Budget:            c.budget,
+	}.Grade(r.Context(), m)
+	if errors.Is(err, suite.ErrNotADFA) {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG,
+			machine.Diagnostics{machine.Errorf(machine.Pointer("Type"),
+				machine.CodeUnsupportedType, "%v", err)})
+		return
+	}
+	if err != nil {
+		log.Printf("Grading failed: %v", err)
+		utils.WriteError(rw, http.StatusInternalServerError, FAILED_TO_GRADE_MSG)
+		return
+	}
+	utils.WriteReport(rw, r, e.Name, grade)
+}
+
+// Tests the mutants of the reference of an exercise against its cases (see
+// `mutation.Tester`), to tell whether the cases would catch likely mistakes.
+// At most 1000 mutants are tested, or fewer with query param 'limit'.
+// If successful: 200 + {"Mutants", "Killed", "Survived", "Equivalent",
+// "Score", "Truncated"}, in JSON or YAML.
+// If there is no such exercise: 404.
+func (c *ExerciseController) TestMutants(rw http.ResponseWriter, r *http.Request) {
+	limit, ok := limitParam(rw, r)
+	if !ok {
+		return
+	}
+	e, err := c.store.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	ref, err := c.refs.get(e)
+	if err == nil {
+		var report mutation.Report
+		report, err = mutation.Tester{
+			Cases:      e.Cases,
+			Budget:     c.budget,
+			MaxMutants: limit,
+		}.Test(r.Context(), ref)
+		if err == nil {
+			utils.WriteDocument(rw, r, http.StatusOK, report)
+			return
+		}
+	}
+	log.Printf("Mutation testing of exercise '%v' failed: %v", e.Id, err)
+	utils.WriteError(rw, http.StatusInternalServerError, FAILED_TO_TEST_MUTANTS_MSG)
+}
+
+// Reads query param 'limit', the number of mutants to test. If false is
+// returned, a response has already been written
+func limitParam(rw http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return maxMutants, true
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_LIMIT_MSG)
+		return 0, false
+	}
+	if limit > maxMutants {
+		limit = maxMutants
+	}
+	return limit, true
+}
+
+// Reads and checks the exercise in the body of a create or update request, and
+// builds its reference DFA. If false is returned, a response has already been
+// written
+func (c *ExerciseController) readExercise(
+	rw http.ResponseWriter,
+	r *http.Request,
+) (exercisestore.Exercise, *dfa.DFA, bool) {
+	var req exerciseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Reference == nil ||
+		(req.Reference.Machine == nil) == (req.Reference.Regex == "") ||
+		(req.Reference.Regex != "" && req.Reference.Alphabet == "") {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return exercisestore.Exercise{}, nil, false
+	}
+	if req.EquivalenceWeight < 0 || suite.Validate(req.Cases) != nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_WEIGHT_MSG)
+		return exercisestore.Exercise{}, nil, false
+	}
+
+	ref := exercisestore.Reference{
+		Regex:    req.Reference.Regex,
+		Alphabet: req.Reference.Alphabet,
+	}
+	if req.Reference.Machine != nil {
+		ref.Machine, err = utils.LoadDocumentFrom(r, req.Reference.Machine)
+	}
+	var d *dfa.DFA
+	if err == nil {
+		d, err = loadReference(ref)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_REFERENCE_MSG, err)
+		return exercisestore.Exercise{}, nil, false
+	}
+
+	cases := req.Cases
+	if cases == nil {
+		cases = []suite.Case{}
+	}
+	return exercisestore.Exercise{
+		Id:                req.Id,
+		Name:              req.Name,
+		Description:       req.Description,
+		Reference:         ref,
+		Cases:             cases,
+		EquivalenceWeight: req.EquivalenceWeight,
+	}, d, true
+}
+
+// The DFA of the reference language of an exercise
+func loadReference(ref exercisestore.Reference) (*dfa.DFA, error) {
+	if ref.Machine == nil {
+		return dfa.FromRegex(ref.Regex, machine.RunesAlphabet(ref.Alphabet))
+	}
+	m, err := automata.Load(ref.Machine)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := m.(*dfa.DFA)
+	if !ok {
+		return nil, suite.ErrNotADFA
+	}
+	return d, nil
+}
+
+// Responds to an error of the store
+func (c *ExerciseController) fail(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, exercisestore.ErrNotFound):
+		utils.WriteError(rw, http.StatusNotFound, EXERCISE_NOT_FOUND_MSG)
+	case errors.Is(err, exercisestore.ErrExists):
+		utils.WriteError(rw, http.StatusConflict, EXERCISE_EXISTS_MSG)
+	case errors.Is(err, exercisestore.ErrInvalidId):
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_ID_MSG)
+	default:
+		log.Printf("Exercise store failed: %v", err)
+		utils.WriteError(rw, http.StatusInternalServerError, STORE_FAILED_MSG)
+	}
+}
@@ -0,0 +1,206 @@
+package exercisecontroller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flapflapio/simulator/core/services/exercisestore"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/internal/simtest"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// Accepts every tape that contains an a
+const containsA = `{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+	"Transitions": [
+		{ "Start": "q0", "End": "q1", "Symbol": "a" },
+		{ "Start": "q0", "End": "q0", "Symbol": "b" },
+		{ "Start": "q1", "End": "q1", "Symbol": "[ab]" }
+	]
+}`
+
+const oddACases = `[
+	{ "Tape": "a", "Accept": true },
+	{ "Tape": "aa", "Accept": false, "Weight": 2 },
+	{ "Tape": "bab", "Accept": true }
+]`
+
+func TestExerciseController(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(exercisestore.NewMemoryStore()).Attach(router)
+
+	// Creating
+	res := simtest.ServeRequest(router, "POST", "/exercises/odd-a", `{
+		"Name": "Odd a's",
+		"Reference": { "Regex": "b*a(b*ab*a)*b*", "Alphabet": "ab" },
+		"Cases": `+oddACases+`
+	}`)
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	assert.Equal(t, "/exercises/odd-a", res.Header().Get("Location"))
+	assert.Len(t, simtest.DecodeJSON(t, res)["Cases"], 3)
+
+	res = simtest.ServeRequest(router, "POST", "/exercises", `{
+		"Reference": { "Machine": `+dfa.ODDA+` },
+		"EquivalenceWeight": 4
+	}`)
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	machineId := simtest.DecodeJSON(t, res)["Id"].(string)
+	assert.Regexp(t, "^[0-9a-f]{12}$", machineId)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"no reference", "/exercises", `{"Cases": []}`, http.StatusBadRequest},
+		{"two references", "/exercises",
+			`{"Reference": {"Regex": "a", "Alphabet": "a", "Machine": {}}}`,
+			http.StatusBadRequest},
+		{"no alphabet", "/exercises", `{"Reference": {"Regex": "a"}}`, http.StatusBadRequest},
+		{"bad regex", "/exercises",
+			`{"Reference": {"Regex": "(a", "Alphabet": "a"}}`,
+			http.StatusUnprocessableEntity},
+		{"bad machine", "/exercises",
+			`{"Reference": {"Machine": {"Type": "DFA"}}}`,
+			http.StatusUnprocessableEntity},
+		{"negative weight", "/exercises",
+			`{"Reference": {"Regex": "a", "Alphabet": "a"}, "Cases": [{"Weight": -1}]}`,
+			http.StatusBadRequest},
+		{"taken", "/exercises/odd-a",
+			`{"Reference": {"Regex": "a", "Alphabet": "a"}}`,
+			http.StatusConflict},
+		{"bad id", "/exercises",
+			`{"Id": "a b", "Reference": {"Regex": "a", "Alphabet": "a"}}`,
+			http.StatusBadRequest},
+	} {
+		res := simtest.ServeRequest(router, "POST", tc.path, tc.body)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+	}
+
+	// Grading
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade", dfa.ODDA)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	grade := simtest.DecodeJSON(t, res)
+	assert.Equal(t, true, grade["Equivalent"])
+	assert.Equal(t, 1.0, grade["Score"])
+	assert.Equal(t, 3.0, grade["Passed"])
+	assert.Nil(t, grade["Counterexample"])
+
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade", containsA)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	grade = simtest.DecodeJSON(t, res)
+	assert.Equal(t, false, grade["Equivalent"])
+	assert.Equal(t, 2.0, grade["Passed"])
+	assert.InDelta(t, 2.0/5.0, grade["Score"], 1e-9)
+	assert.Equal(t, map[string]interface{}{
+		"Tape":     "aa",
+		"Expected": false,
+		"Accepted": true,
+	}, grade["Counterexample"])
+	cases := grade["Cases"].([]interface{})
+	assert.Equal(t, false, cases[1].(map[string]interface{})["Passed"])
+
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade?format=tap", containsA)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Contains(t, res.Body.String(), "1..4\n")
+	assert.Contains(t, res.Body.String(), "not ok 4 - equivalent to the reference\n")
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade?format=junit", containsA)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Contains(t, res.Body.String(), `<testsuite name="Odd a&#39;s" tests="4" failures="2">`)
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade?format=pdf", containsA)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = simtest.ServeRequest(router, "POST", "/exercises/"+machineId+"/grade", containsA)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, 0.0, simtest.DecodeJSON(t, res)["Score"])
+
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade", `{"Type": "DFA"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, res.Code)
+	res = simtest.ServeRequest(router, "POST", "/exercises/missing/grade", dfa.ODDA)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	// Mutation testing of the reference
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/mutants", "")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	mutants := simtest.DecodeJSON(t, res)
+	assert.NotEmpty(t, mutants["Mutants"])
+	for _, m := range mutants["Mutants"].([]interface{}) {
+		m := m.(map[string]interface{})
+		if m["Killed"] == false && m["Equivalent"] == false {
+			assert.NotNil(t, m["Tape"], m["Description"])
+		}
+	}
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/mutants?limit=2", "")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Len(t, simtest.DecodeJSON(t, res)["Mutants"], 2)
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/mutants?limit=-1", "")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	res = simtest.ServeRequest(router, "POST", "/exercises/missing/mutants", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	// Reading, updating and deleting
+	res = simtest.ServeRequest(router, "GET", "/exercises", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Len(t, simtest.DecodeJSON(t, res)["Exercises"], 2)
+
+	res = simtest.ServeRequest(router, "PUT", "/exercises/odd-a", `{
+		"Reference": { "Machine": `+containsA+` },
+		"Cases": [{ "Tape": "ba", "Accept": true }]
+	}`)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = simtest.ServeRequest(router, "GET", "/exercises/odd-a", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Len(t, simtest.DecodeJSON(t, res)["Cases"], 1)
+	res = simtest.ServeRequest(router, "POST", "/exercises/odd-a/grade", containsA)
+	assert.Equal(t, true, simtest.DecodeJSON(t, res)["Equivalent"])
+
+	res = simtest.ServeRequest(router, "PUT", "/exercises/missing", `{"Reference": {"Regex": "a", "Alphabet": "a"}}`)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+	res = simtest.ServeRequest(router, "DELETE", "/exercises/odd-a", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	res = simtest.ServeRequest(router, "GET", "/exercises/odd-a", "")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestWithPrefix(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(exercisestore.NewMemoryStore()).WithPrefix("/api/").Attach(router)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/exercises/a",
+		strings.NewReader(`{"Reference": {"Regex": "a", "Alphabet": "a"}}`))
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "/api/exercises/a", recorder.Header().Get("Location"))
+}
+
+func TestReferenceChangedByAnotherServer(t *testing.T) {
+	t.Parallel()
+	store := exercisestore.NewMemoryStore()
+	first, second := mux.NewRouter(), mux.NewRouter()
+	New(store).Attach(first)
+	New(store).Attach(second)
+
+	res := simtest.ServeRequest(first, "POST", "/exercises/odd-a",
+		`{"Reference": { "Regex": "b*a(b*ab*a)*b*", "Alphabet": "ab" }}`)
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	res = simtest.ServeRequest(first, "POST", "/exercises/odd-a/grade", containsA)
+	assert.Contains(t, res.Body.String(), `"Equivalent":false`)
+
+	// The first server notices that its reference is out of date
+	time.Sleep(2 * time.Millisecond)
+	res = simtest.ServeRequest(second, "PUT", "/exercises/odd-a", `{"Reference": { "Machine": `+containsA+` }}`)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = simtest.ServeRequest(first, "POST", "/exercises/odd-a/grade", containsA)
+	assert.Contains(t, res.Body.String(), `"Equivalent":true`)
+}
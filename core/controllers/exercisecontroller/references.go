@@ -0,0 +1,57 @@
+package exercisecontroller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/flapflapio/simulator/core/services/exercisestore"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+)
+
+// The reference DFAs of exercises, keyed by exercise id, so that a reference
+// is built once rather than on every request. An entry is only used for the
+// version of the exercise it was built from, so exercises changed by another
+// server sharing the store are rebuilt. Safe for concurrent use
+type references struct {
+	entries map[string]cachedReference
+	lock    sync.Mutex
+}
+
+type cachedReference struct {
+	updated time.Time
+	dfa     *dfa.DFA
+}
+
+func newReferences() *references {
+	return &references{entries: map[string]cachedReference{}}
+}
+
+// The reference DFA of an exercise, built if it is not cached yet
+func (c *references) get(e exercisestore.Exercise) (*dfa.DFA, error) {
+	c.lock.Lock()
+	cached, ok := c.entries[e.Id]
+	c.lock.Unlock()
+	if ok && cached.updated.Equal(e.Updated) {
+		return cached.dfa, nil
+	}
+	d, err := loadReference(e.Reference)
+	if err != nil {
+		return nil, err
+	}
+	c.put(e, d)
+	return d, nil
+}
+
+// Caches the reference DFA of a stored exercise. The DFA must not be modified
+// afterwards, as requests share it
+func (c *references) put(e exercisestore.Exercise, d *dfa.DFA) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[e.Id] = cachedReference{updated: e.Updated, dfa: d}
+}
+
+func (c *references) forget(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, id)
+}
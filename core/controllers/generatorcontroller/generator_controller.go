@@ -0,0 +1,260 @@
+package generatorcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/generator"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	INVALID_REQUEST_MSG = `{"Err":"The body must be a JSON object"}`
+
+	INVALID_TYPE_MSG = `{"Err":"Only machines of type 'DFA' or 'NFA' can be generated"}`
+
+	TOO_LARGE_MSG = `` +
+		`{"Err":"At most 100 states, alphabets of 50 symbols, 1000 tapes ` +
+		`and tapes of 100 symbols can be generated"}`
+
+	NO_MINIMAL_DFA_MSG = `{"Err":"No minimal DFA was found with the given parameters"}`
+
+	NO_TAPES_FOR_NFA_MSG = `{"Err":"Tapes can only be generated for DFAs"}`
+
+	// Limits of a request, as the work grows with each of them
+	maxStates  = 100
+	maxSymbols = 50
+	maxTapes   = 1000
+	maxLength  = 100
+
+	// Length of generated tapes if the request does not give one
+	defaultLength = 10
+)
+
+// Generates random machines and tapes, e.g. for practice problems
+type GeneratorController struct {
+	prefix string
+}
+
+// The body of a request for a machine. Density defaults to 1 and Accepting to
+// 0.5, see `generator.Params` for the others
+type machineRequest struct {
+	Type      string      `json:"Type"`
+	States    int         `json:"States"`
+	Alphabet  interface{} `json:"Alphabet"`
+	Density   *float64    `json:"Density"`
+	Accepting *float64    `json:"Accepting"`
+	Epsilon   float64     `json:"Epsilon"`
+	Connected bool        `json:"Connected"`
+	Minimal   bool        `json:"Minimal"`
+	Seed      *int64      `json:"Seed"`
+
+	// Cases for the machine (DFAs only), see `generator.Tapes`
+	Tapes     int `json:"Tapes"`
+	MaxLength int `json:"MaxLength"`
+}
+
+// The body of a request for tapes of a machine
+type tapesRequest struct {
+	Machine   interface{} `json:"Machine"`
+	Count     int         `json:"Count"`
+	MaxLength int         `json:"MaxLength"`
+	Seed      *int64      `json:"Seed"`
+}
+
+// The seed is returned so that the same machine or tapes can be generated again
+type generated struct {
+	Machine interface{}  `json:"Machine,omitempty"`
+	Cases   []suite.Case `json:"Cases,omitempty"`
+	Seed    int64        `json:"Seed"`
+}
+
+func New() *GeneratorController {
+	return &GeneratorController{prefix: "/"}
+}
+
+func (c *GeneratorController) WithPrefix(prefix string) *GeneratorController {
+	return &GeneratorController{prefix: app.Trim(prefix)}
+}
+
+// Attaches this controller to the given router
+func (c *GeneratorController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("POST").Path("/generate").HandlerFunc(GenerateMachine)
+	r.Methods("POST").Path("/generate/tapes").HandlerFunc(GenerateTapes)
+}
+
+// Generates a random DFA or NFA (see `generator.DFA` and `generator.NFA`), and
+// for DFAs, optionally cases with random tapes for it.
+// If successful: 200 + {"Machine", "Cases", "Seed"}, in JSON or YAML.
+// If the parameters are invalid: 400 + the reason.
+// If no minimal DFA was found: 422.
+func GenerateMachine(rw http.ResponseWriter, r *http.Request) {
+	var req machineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	if req.Type == "" {
+		req.Type = machine.DFA
+	}
+	if req.Type != machine.DFA && req.Type != machine.NFA {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_TYPE_MSG)
+		return
+	}
+	if req.Type == machine.NFA && req.Tapes > 0 {
+		utils.WriteError(rw, http.StatusBadRequest, NO_TAPES_FOR_NFA_MSG)
+		return
+	}
+	length, ok := checkTapes(rw, req.Tapes, req.MaxLength)
+	if !ok {
+		return
+	}
+	if req.States > maxStates {
+		utils.WriteError(rw, http.StatusBadRequest, TOO_LARGE_MSG)
+		return
+	}
+	params := generator.Params{
+		States:    req.States,
+		Density:   1,
+		Accepting: 0.5,
+		Epsilon:   req.Epsilon,
+		Connected: req.Connected,
+		Minimal:   req.Minimal,
+	}
+	if req.Density != nil {
+		params.Density = *req.Density
+	}
+	if req.Accepting != nil {
+		params.Accepting = *req.Accepting
+	}
+	if req.Alphabet != nil {
+		alphabet, err := machine.ParseAlphabet(req.Alphabet)
+		if err != nil {
+			writeReason(rw, http.StatusBadRequest, err)
+			return
+		}
+		if len(alphabet) > maxSymbols {
+			utils.WriteError(rw, http.StatusBadRequest, TOO_LARGE_MSG)
+			return
+		}
+		params.Alphabet = alphabet
+	}
+	if err := params.Validate(); err != nil {
+		writeReason(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	res := generated{Seed: seed(req.Seed)}
+	rng := rand.New(rand.NewSource(res.Seed))
+	if req.Type == machine.NFA {
+		doc, err := generator.NFA(rng, params)
+		if err != nil {
+			writeReason(rw, http.StatusBadRequest, err)
+			return
+		}
+		res.Machine = doc
+		utils.WriteDocument(rw, r, http.StatusOK, res)
+		return
+	}
+
+	d, err := generator.DFA(r.Context(), rng, params)
+	if r.Context().Err() != nil {
+		return // The client is gone
+	}
+	if errors.Is(err, generator.ErrNoMinimalDFA) {
+		utils.WriteError(rw, http.StatusUnprocessableEntity, NO_MINIMAL_DFA_MSG)
+		return
+	}
+	if err != nil {
+		writeReason(rw, http.StatusBadRequest, err)
+		return
+	}
+	res.Machine = d.JsonMap()
+	if req.Tapes > 0 {
+		res.Cases = generator.Tapes(rng, d, req.Tapes, length)
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, res)
+}
+
+// Generates cases with random tapes for the DFA in the body, see
+// `generator.Tapes`.
+// If successful: 200 + {"Cases", "Seed"}, in JSON or YAML.
+// If the machine is invalid or not a DFA: 422 + a list of diagnostics.
+func GenerateTapes(rw http.ResponseWriter, r *http.Request) {
+	var req tapesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Machine == nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	length, ok := checkTapes(rw, req.Count, req.MaxLength)
+	if !ok {
+		return
+	}
+	m, err := utils.LoadMachineFrom(r, req.Machine)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	d, ok := m.(*dfa.DFA)
+	if !ok {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG,
+			machine.Diagnostics{machine.Errorf(machine.Pointer("Machine", "Type"),
+				machine.CodeUnsupportedType, "tapes can only be generated for DFAs")})
+		return
+	}
+
+	res := generated{Seed: seed(req.Seed)}
+	res.Cases = generator.Tapes(rand.New(rand.NewSource(res.Seed)), d, req.Count, length)
+	if res.Cases == nil {
+		res.Cases = []suite.Case{}
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, res)
+}
+
+// Checks the number and length of the tapes to generate, and returns the
+// length with its default. If false is returned, a response has already been
+// written
+func checkTapes(rw http.ResponseWriter, count, length int) (int, bool) {
+	if count < 0 || length < 0 {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return 0, false
+	}
+	if count > maxTapes || length > maxLength {
+		utils.WriteError(rw, http.StatusBadRequest, TOO_LARGE_MSG)
+		return 0, false
+	}
+	if length == 0 {
+		length = defaultLength
+	}
+	return length, true
+}
+
+// The seed of the request, or a new one
+func seed(requested *int64) int64 {
+	if requested != nil {
+		return *requested
+	}
+	return time.Now().UnixNano()
+}
+
+// Writes an error with the reason that `err` gives
+func writeReason(rw http.ResponseWriter, status int, err error) {
+	data, merr := json.Marshal(map[string]string{"Err": err.Error()})
+	if merr != nil {
+		panic(merr)
+	}
+	utils.WriteError(rw, status, string(data))
+}
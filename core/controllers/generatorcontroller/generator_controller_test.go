@@ -0,0 +1,99 @@
+package generatorcontroller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/internal/simtest"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	body := `{"States": 4, "Alphabet": "ab", "Minimal": true, "Seed": 7, "Tapes": 6}`
+	res := simtest.ServeRequest(router, "POST", "/generate", body)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	generated := simtest.DecodeJSON(t, res)
+	assert.Equal(t, 7.0, generated["Seed"])
+	assert.Len(t, generated["Cases"], 6)
+	data, err := json.Marshal(generated["Machine"])
+	assert.NoError(t, err)
+	d, err := dfa.Load(data)
+	assert.NoError(t, err)
+	assert.Len(t, d.States, 4)
+
+	// The same seed gives the same machine
+	assert.Equal(t, res.Body.String(), simtest.ServeRequest(router, "POST", "/generate", body).Body.String())
+
+	res = simtest.ServeRequest(router, "POST", "/generate", `{"Type": "NFA", "States": 3, "Alphabet": ["0", "1"], "Density": 0.2}`)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	generated = simtest.DecodeJSON(t, res)
+	assert.Equal(t, "NFA", generated["Machine"].(map[string]interface{})["Type"])
+	assert.Nil(t, generated["Cases"])
+
+	res = simtest.ServeRequest(router, "POST", "/generate/tapes", `{"Machine": `+dfa.ODDA+`, "Count": 5, "MaxLength": 4}`)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	cases := simtest.DecodeJSON(t, res)["Cases"].([]interface{})
+	assert.Len(t, cases, 5)
+	assert.Equal(t, map[string]interface{}{
+		"Name":   "shortest accepted tape",
+		"Tape":   "a",
+		"Accept": true,
+	}, cases[1])
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"not json", "/generate", `States`, http.StatusBadRequest},
+		{"no states", "/generate", `{"Alphabet": "ab"}`, http.StatusBadRequest},
+		{"no alphabet", "/generate", `{"States": 2}`, http.StatusBadRequest},
+		{"bad alphabet", "/generate", `{"States": 2, "Alphabet": ["a", "a"]}`, http.StatusBadRequest},
+		{"bad density", "/generate", `{"States": 2, "Alphabet": "a", "Density": 3}`, http.StatusBadRequest},
+		{"bad type", "/generate", `{"Type": "TM", "States": 2, "Alphabet": "a"}`, http.StatusBadRequest},
+		{"too many states", "/generate", `{"States": 101, "Alphabet": "a"}`, http.StatusBadRequest},
+		{"too many symbols", "/generate",
+			`{"States": 1, "Alphabet": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"}`,
+			http.StatusBadRequest},
+		{"too many tapes", "/generate", `{"States": 1, "Alphabet": "a", "Tapes": 1001}`, http.StatusBadRequest},
+		{"tapes of an nfa", "/generate",
+			`{"Type": "NFA", "States": 1, "Alphabet": "a", "Tapes": 2}`,
+			http.StatusBadRequest},
+		{"minimal nfa", "/generate",
+			`{"Type": "NFA", "States": 1, "Alphabet": "a", "Minimal": true}`,
+			http.StatusBadRequest},
+		{"no minimal dfa", "/generate",
+			`{"States": 3, "Alphabet": "a", "Accepting": 0, "Minimal": true}`,
+			http.StatusUnprocessableEntity},
+		{"no machine", "/generate/tapes", `{"Count": 2}`, http.StatusBadRequest},
+		{"negative count", "/generate/tapes",
+			`{"Machine": ` + dfa.ODDA + `, "Count": -1}`,
+			http.StatusBadRequest},
+		{"invalid machine", "/generate/tapes", `{"Machine": {"Type": "DFA"}}`,
+			http.StatusUnprocessableEntity},
+	} {
+		res := simtest.ServeRequest(router, "POST", tc.path, tc.body)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().WithPrefix("/api/").Attach(router)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/generate",
+		strings.NewReader(`{"States": 2, "Alphabet": "ab"}`))
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
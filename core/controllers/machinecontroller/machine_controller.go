@@ -0,0 +1,398 @@
+package machinecontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	INVALID_REQUEST_MSG = `` +
+		`{"Err":"The body must be a JSON object with the machine in 'Machine', ` +
+		`and optionally 'Id', 'Name', 'Description' and 'Tags'"}`
+
+	INVALID_ID_MSG = `` +
+		`{"Err":"Machine ids must be 1 to 64 letters, digits, '-' or '_'"}`
+
+	INVALID_REVISION_MSG = `` +
+		`{"Err":"Query params 'revision', 'from' and 'to' must be positive integers"}`
+
+	MACHINE_NOT_FOUND_MSG = `{"Err":"No machine with this id was found"}`
+
+	REVISION_NOT_FOUND_MSG = `{"Err":"This machine has no such revision"}`
+
+	MACHINE_EXISTS_MSG = `{"Err":"A machine with this id already exists"}`
+
+	STORE_FAILED_MSG = `{"Err":"Failed to access the machine library"}`
+
+	UNSUPPORTED_PATCH_MSG = `` +
+		`{"Err":"Patches must have Content-Type 'application/json-patch+json' ` +
+		`or 'application/merge-patch+json'"}`
+
+	INVALID_PATCH_MSG = "The patch could not be applied to the machine"
+)
+
+// A library of named machines, kept in a `machinestore.Store`
+type MachineController struct {
+	prefix string
+	store  machinestore.Store
+}
+
+// The body of create and update requests
+type machineRequest struct {
+	Id          string      `json:"Id"`
+	Name        string      `json:"Name"`
+	Description string      `json:"Description"`
+	Tags        []string    `json:"Tags"`
+	Machine     interface{} `json:"Machine"`
+}
+
+func New(store machinestore.Store) *MachineController {
+	return &MachineController{prefix: "/", store: store}
+}
+
+func (c *MachineController) WithPrefix(prefix string) *MachineController {
+	return &MachineController{prefix: app.Trim(prefix), store: c.store}
+}
+
+// Attaches this controller to the given router
+func (c *MachineController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("GET").Path("/machines").HandlerFunc(c.ListMachines)
+	r.Methods("POST").Path("/machines").HandlerFunc(c.CreateMachine)
+	r.Methods("POST").Path("/machines/{id}").HandlerFunc(c.CreateMachine)
+	r.Methods("GET").Path("/machines/{id}").HandlerFunc(c.GetMachine)
+	r.Methods("PUT").Path("/machines/{id}").HandlerFunc(c.UpdateMachine)
+	r.Methods("PATCH").Path("/machines/{id}").HandlerFunc(c.PatchMachine)
+	r.Methods("DELETE").Path("/machines/{id}").HandlerFunc(c.DeleteMachine)
+	r.Methods("GET").Path("/machines/{id}/revisions").HandlerFunc(c.ListRevisions)
+	r.Methods("GET").Path("/machines/{id}/diff").HandlerFunc(c.DiffRevisions)
+	r.Methods("POST").Path("/machines/{id}/rollback").HandlerFunc(c.Rollback)
+}
+
+// Lists the latest revision of every stored machine, without their documents.
+// With `?tag=...`, only machines with that tag are listed.
+// If successful: 200 + {"Machines": [...]}, in JSON or YAML.
+func (c *MachineController) ListMachines(rw http.ResponseWriter, r *http.Request) {
+	list, err := c.store.List(r.Context(), r.URL.Query().Get("tag"))
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{"Machines": list})
+}
+
+// Stores a new machine. The id is taken from the path, or the 'Id' of the
+// body, or generated if neither is given.
+// If successful: 201 + the stored machine, with its location in the
+// `Location` header.
+// If the machine is invalid: 422 + a list of diagnostics.
+// If the id is taken: 409.
+func (c *MachineController) CreateMachine(rw http.ResponseWriter, r *http.Request) {
+	m, ok := c.readMachine(rw, r)
+	if !ok {
+		return
+	}
+	if id := mux.Vars(r)["id"]; id != "" {
+		if m.Id != "" && m.Id != id {
+			utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+			return
+		}
+		m.Id = id
+	}
+	if m.Id == "" {
+		m.Id = utils.NewId()
+	}
+	if m.Name == "" {
+		m.Name = m.Id
+	}
+
+	stored, err := c.store.Create(r.Context(), m)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	rw.Header().Set("Location", fmt.Sprintf("%v/machines/%v",
+		strings.TrimSuffix(c.prefix, "/"), stored.Id))
+	utils.WriteDocument(rw, r, http.StatusCreated, stored)
+}
+
+// The latest revision of a machine, or the revision in query param
+// 'revision'.
+// If successful: 200 + the machine, in JSON or YAML.
+// If there is no such machine or revision: 404.
+func (c *MachineController) GetMachine(rw http.ResponseWriter, r *http.Request) {
+	revision, ok := revisionParam(rw, r, "revision", 0)
+	if !ok {
+		return
+	}
+	m, ok := c.getRevision(rw, r, revision)
+	if !ok {
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, m)
+}
+
+// Stores a new revision of a machine, replacing its name, description, tags
+// and document.
+// If successful: 200 + the new revision.
+// If the machine is invalid: 422 + a list of diagnostics.
+// If there is no such machine: 404.
+func (c *MachineController) UpdateMachine(rw http.ResponseWriter, r *http.Request) {
+	m, ok := c.readMachine(rw, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	if m.Id != "" && m.Id != id {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	m.Id = id
+	if m.Name == "" {
+		m.Name = m.Id
+	}
+
+	stored, err := c.store.Update(r.Context(), m)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, stored)
+}
+
+// Stores a new revision of a machine by patching the document of its latest
+// revision, keeping its name, description and tags. The body is a JSON Patch
+// (RFC 6902, Content-Type 'application/json-patch+json') or a JSON Merge Patch
+// (RFC 7386, Content-Type 'application/merge-patch+json').
+// If successful: 200 + the new revision.
+// If the patch fails or the patched machine is invalid: 422 + a list of
+// diagnostics.
+// If there is no such machine: 404.
+func (c *MachineController) PatchMachine(rw http.ResponseWriter, r *http.Request) {
+	var apply func(map[string]interface{}, interface{}) (map[string]interface{}, error)
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "application/json-patch+json":
+		apply = machine.JsonPatch
+	case "application/merge-patch+json":
+		apply = machine.MergePatch
+	default:
+		utils.WriteError(rw, http.StatusUnsupportedMediaType, UNSUPPORTED_PATCH_MSG)
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+
+	m, ok := c.getRevision(rw, r, 0)
+	if !ok {
+		return
+	}
+	doc, err := apply(m.Document, patch)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_PATCH_MSG, err)
+		return
+	}
+	if doc, err = utils.LoadDocumentFrom(r, doc); err == nil {
+		err = utils.CheckDocument(doc)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+
+	m.Document = doc
+	stored, err := c.store.Update(r.Context(), m)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, stored)
+}
+
+// Deletes a machine and all of its revisions.
+// If successful: 200.
+// If there is no such machine: 404.
+func (c *MachineController) DeleteMachine(rw http.ResponseWriter, r *http.Request) {
+	if err := c.store.Delete(r.Context(), mux.Vars(r)["id"]); err != nil {
+		c.fail(rw, err)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(`{"Status":"Machine deleted successfully"}`))
+}
+
+// Every revision of a machine, oldest first and without their documents.
+// If successful: 200 + {"Revisions": [...]}, in JSON or YAML.
+// If there is no such machine: 404.
+func (c *MachineController) ListRevisions(rw http.ResponseWriter, r *http.Request) {
+	list, err := c.store.Revisions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{"Revisions": list})
+}
+
+// The structural differences between revisions 'from' and 'to' of a machine
+// (see `machine.Diff`). 'to' defaults to the latest revision, and 'from' to
+// the revision before 'to'.
+// If successful: 200 + {"Id", "From", "To", "Changes"}, in JSON or YAML.
+// If there is no such machine or revision: 404.
+func (c *MachineController) DiffRevisions(rw http.ResponseWriter, r *http.Request) {
+	to, ok := revisionParam(rw, r, "to", 0)
+	if !ok {
+		return
+	}
+	newer, ok := c.getRevision(rw, r, to)
+	if !ok {
+		return
+	}
+	from, ok := revisionParam(rw, r, "from", newer.Revision-1)
+	if !ok {
+		return
+	}
+	if from == 0 {
+		utils.WriteError(rw, http.StatusNotFound, REVISION_NOT_FOUND_MSG)
+		return
+	}
+	older, ok := c.getRevision(rw, r, from)
+	if !ok {
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{
+		"Id":      newer.Id,
+		"From":    older.Revision,
+		"To":      newer.Revision,
+		"Changes": machine.Diff(older.Document, newer.Document),
+	})
+}
+
+// Restores revision 'revision' of a machine by storing a copy of it as a new
+// revision, so that no history is lost.
+// If successful: 200 + the new revision.
+// If there is no such machine or revision: 404.
+func (c *MachineController) Rollback(rw http.ResponseWriter, r *http.Request) {
+	revision, ok := revisionParam(rw, r, "revision", 0)
+	if !ok {
+		return
+	}
+	if revision == 0 {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REVISION_MSG)
+		return
+	}
+	old, ok := c.getRevision(rw, r, revision)
+	if !ok {
+		return
+	}
+	stored, err := c.store.Update(r.Context(), old)
+	if err != nil {
+		c.fail(rw, err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, stored)
+}
+
+// Reads a revision number from a query param, or `def` if it is absent. If
+// false is returned, a response has already been written
+func revisionParam(rw http.ResponseWriter, r *http.Request, param string, def int) (int, bool) {
+	s := r.URL.Query().Get(param)
+	if s == "" {
+		return def, true
+	}
+	revision, err := strconv.Atoi(s)
+	if err != nil || revision < 1 {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REVISION_MSG)
+		return 0, false
+	}
+	return revision, true
+}
+
+// Gets a revision of the machine in the path, or its latest revision if
+// `revision` is 0. If false is returned, a response has already been written
+func (c *MachineController) getRevision(
+	rw http.ResponseWriter,
+	r *http.Request,
+	revision int,
+) (machinestore.Machine, bool) {
+	id := mux.Vars(r)["id"]
+	var m machinestore.Machine
+	var err error
+	if revision == 0 {
+		m, err = c.store.Get(r.Context(), id)
+	} else {
+		m, err = c.store.GetRevision(r.Context(), id, revision)
+	}
+	if errors.Is(err, machinestore.ErrNotFound) && revision != 0 {
+		if _, err := c.store.Get(r.Context(), id); err == nil {
+			utils.WriteError(rw, http.StatusNotFound, REVISION_NOT_FOUND_MSG)
+			return m, false
+		}
+	}
+	if err != nil {
+		c.fail(rw, err)
+		return m, false
+	}
+	return m, true
+}
+
+// Reads and checks the machine in the body of a create or update request. If
+// false is returned, a response has already been written
+func (c *MachineController) readMachine(
+	rw http.ResponseWriter,
+	r *http.Request,
+) (machinestore.Machine, bool) {
+	var req machineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Machine == nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return machinestore.Machine{}, false
+	}
+	doc, err := utils.LoadDocumentFrom(r, req.Machine)
+	if err == nil {
+		err = utils.CheckDocument(doc)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return machinestore.Machine{}, false
+	}
+	return machinestore.Machine{
+		Id:          req.Id,
+		Name:        req.Name,
+		Description: req.Description,
+		Tags:        req.Tags,
+		Document:    doc,
+	}, true
+}
+
+// Responds to an error of the store
+func (c *MachineController) fail(rw http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, machinestore.ErrNotFound):
+		utils.WriteError(rw, http.StatusNotFound, MACHINE_NOT_FOUND_MSG)
+	case errors.Is(err, machinestore.ErrExists):
+		utils.WriteError(rw, http.StatusConflict, MACHINE_EXISTS_MSG)
+	case errors.Is(err, machinestore.ErrInvalidId):
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_ID_MSG)
+	default:
+		log.Printf("Machine store failed: %v", err)
+		utils.WriteError(rw, http.StatusInternalServerError, STORE_FAILED_MSG)
+	}
+}
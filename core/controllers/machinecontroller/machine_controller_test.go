@@ -0,0 +1,276 @@
+package machinecontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/internal/simtest"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidDFA = `{
+	"Type": "DFA",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }],
+	"Transitions": [{ "Start": "q0", "End": "q9", "Symbol": "a" }]
+}`
+
+const partialPDA = `{
+	"Type": "PDA",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+	"Transitions": [
+	  { "Start": "q0", "End": "q1", "Symbol": "a", "Pop": "Z", "Push": "AZ" }
+	]
+}`
+
+func body(id, name string, tags []string, machine string) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"Id":      id,
+		"Name":    name,
+		"Tags":    tags,
+		"Machine": json.RawMessage(machine),
+	})
+	return string(data)
+}
+
+func TestMachineController(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(machinestore.NewMemoryStore()).Attach(router)
+
+	// Creating
+	res := simtest.ServeRequest(router, "POST", "/machines", body("odd-a", "Odd a's", []string{"dfa"}, dfa.ODDA))
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	assert.Equal(t, "/machines/odd-a", res.Header().Get("Location"))
+	created := simtest.DecodeJSON(t, res)
+	assert.Equal(t, "odd-a", created["Id"])
+	assert.Equal(t, 1.0, created["Revision"])
+	assert.Equal(t, 1.0, created["Machine"].(map[string]interface{})["SchemaVersion"])
+
+	res = simtest.ServeRequest(router, "POST", "/machines/pda", body("", "", []string{"pda"}, partialPDA))
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	assert.Equal(t, "pda", simtest.DecodeJSON(t, res)["Name"])
+
+	res = simtest.ServeRequest(router, "POST", "/machines", body("", "Generated", nil, dfa.ODDA))
+	assert.Equal(t, http.StatusCreated, res.Code, res.Body.String())
+	generated := simtest.DecodeJSON(t, res)["Id"].(string)
+	assert.Regexp(t, "^[0-9a-f]{12}$", generated)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		msg    string
+	}{
+		{"exists", "POST", "/machines", body("odd-a", "", nil, dfa.ODDA), http.StatusConflict, MACHINE_EXISTS_MSG},
+		{"invalid-id", "POST", "/machines", body("odd a", "", nil, dfa.ODDA), http.StatusBadRequest, INVALID_ID_MSG},
+		{"id-mismatch", "POST", "/machines/x", body("y", "", nil, dfa.ODDA), http.StatusBadRequest, INVALID_REQUEST_MSG},
+		{"no-machine", "POST", "/machines", `{"Id":"x"}`, http.StatusBadRequest, INVALID_REQUEST_MSG},
+		{"not-json", "POST", "/machines", `{`, http.StatusBadRequest, INVALID_REQUEST_MSG},
+		{"invalid-machine", "POST", "/machines", body("x", "", nil, invalidDFA), http.StatusUnprocessableEntity, INVALID_MACHINE_MSG},
+		{"get-missing", "GET", "/machines/x", "", http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+		{"get-bad-revision", "GET", "/machines/odd-a?revision=0", "", http.StatusBadRequest, INVALID_REVISION_MSG},
+		{"get-missing-revision", "GET", "/machines/odd-a?revision=2", "", http.StatusNotFound, REVISION_NOT_FOUND_MSG},
+		{"update-missing", "PUT", "/machines/x", body("", "", nil, dfa.ODDA), http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+		{"delete-missing", "DELETE", "/machines/x", "", http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+	} {
+		res := simtest.ServeRequest(router, tc.method, tc.path, tc.body)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+		assert.Contains(t, res.Body.String(), tc.msg, tc.name)
+	}
+
+	// Updating keeps the older revisions
+	res = simtest.ServeRequest(router, "PUT", "/machines/odd-a", body("", "Odd number of a's", []string{"dfa", "regular"}, dfa.ODDA))
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, 2.0, simtest.DecodeJSON(t, res)["Revision"])
+
+	res = simtest.ServeRequest(router, "GET", "/machines/odd-a", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Odd number of a's", simtest.DecodeJSON(t, res)["Name"])
+
+	res = simtest.ServeRequest(router, "GET", "/machines/odd-a?revision=1", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "Odd a's", simtest.DecodeJSON(t, res)["Name"])
+
+	// Listing
+	ids := func(path string) []string {
+		res := simtest.ServeRequest(router, "GET", path, "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		var list struct{ Machines []machinestore.Machine }
+		json.Unmarshal(res.Body.Bytes(), &list)
+		ids := []string{}
+		for _, m := range list.Machines {
+			ids = append(ids, m.Id)
+			assert.Nil(t, m.Document)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"odd-a", "pda", generated}, ids("/machines"))
+	assert.Equal(t, []string{"odd-a"}, ids("/machines?tag=regular"))
+
+	// Deleting
+	res = simtest.ServeRequest(router, "DELETE", fmt.Sprintf("/machines/%v", generated), "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.ElementsMatch(t, []string{"odd-a", "pda"}, ids("/machines"))
+}
+
+func TestWithPrefix(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(machinestore.NewMemoryStore()).WithPrefix("/api/").Attach(router)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/machines",
+		strings.NewReader(body("odd-a", "", nil, dfa.ODDA)))
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "/api/machines/odd-a", recorder.Header().Get("Location"))
+}
+
+func TestRevisions(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(machinestore.NewMemoryStore()).Attach(router)
+
+	// Revision 2 accepts an even number of a's
+	evenA := strings.Replace(strings.Replace(dfa.ODDA,
+		`"Id": "q0", "Ending": false`, `"Id": "q0", "Ending": true`, 1),
+		`"Id": "q1", "Ending": true`, `"Id": "q1", "Ending": false`, 1)
+	assert.Equal(t, http.StatusCreated, simtest.ServeRequest(router, "POST", "/machines", body("a", "Odd", nil, dfa.ODDA)).Code)
+	assert.Equal(t, http.StatusOK, simtest.ServeRequest(router, "PUT", "/machines/a", body("", "Even", nil, evenA)).Code)
+
+	res := simtest.ServeRequest(router, "GET", "/machines/a/revisions", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var revisions struct{ Revisions []machinestore.Machine }
+	json.Unmarshal(res.Body.Bytes(), &revisions)
+	if assert.Len(t, revisions.Revisions, 2) {
+		assert.Equal(t, "Odd", revisions.Revisions[0].Name)
+		assert.Equal(t, 2, revisions.Revisions[1].Revision)
+	}
+
+	changes := `{
+		"StatesChanged": [
+			{ "Id": "q0", "Ending": { "From": false, "To": true } },
+			{ "Id": "q1", "Ending": { "From": true, "To": false } }
+		]
+	}`
+	res = simtest.ServeRequest(router, "GET", "/machines/a/diff", "")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.JSONEq(t,
+		fmt.Sprintf(`{"Id": "a", "From": 1, "To": 2, "Changes": %v}`, changes),
+		res.Body.String())
+
+	// Rolling back stores revision 1 again, as revision 3
+	res = simtest.ServeRequest(router, "POST", "/machines/a/rollback?revision=1", "")
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var rolledBack machinestore.Machine
+	json.Unmarshal(res.Body.Bytes(), &rolledBack)
+	assert.Equal(t, 3, rolledBack.Revision)
+	assert.Equal(t, "Odd", rolledBack.Name)
+
+	res = simtest.ServeRequest(router, "GET", "/machines/a/diff?from=1&to=3", "")
+	assert.JSONEq(t, `{"Id": "a", "From": 1, "To": 3, "Changes": {}}`, res.Body.String())
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		msg          string
+	}{
+		{"GET", "/machines/b/revisions", http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+		{"GET", "/machines/a/diff?to=1", http.StatusNotFound, REVISION_NOT_FOUND_MSG},
+		{"GET", "/machines/a/diff?from=9", http.StatusNotFound, REVISION_NOT_FOUND_MSG},
+		{"GET", "/machines/a/diff?from=x", http.StatusBadRequest, INVALID_REVISION_MSG},
+		{"GET", "/machines/b/diff", http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+		{"POST", "/machines/a/rollback", http.StatusBadRequest, INVALID_REVISION_MSG},
+		{"POST", "/machines/a/rollback?revision=9", http.StatusNotFound, REVISION_NOT_FOUND_MSG},
+		{"POST", "/machines/b/rollback?revision=1", http.StatusNotFound, MACHINE_NOT_FOUND_MSG},
+	} {
+		res := simtest.ServeRequest(router, tc.method, tc.path, "")
+		assert.Equal(t, tc.status, res.Code, tc.path)
+		assert.Equal(t, tc.msg, res.Body.String(), tc.path)
+	}
+}
+
+func TestPatchMachine(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(machinestore.NewMemoryStore()).Attach(router)
+	patch := func(contentType, patch string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest("PATCH", "/machines/odd-a", strings.NewReader(patch))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", "/machines",
+		strings.NewReader(body("odd-a", "Odd a's", []string{"dfa"}, dfa.ODDA))))
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		revision    int
+		contains    string
+	}{
+		{
+			name:        "json-patch",
+			contentType: "application/json-patch+json",
+			patch:       `[{ "op": "add", "path": "/States/0/Label", "value": "even" }]`,
+			status:      http.StatusOK,
+			revision:    2,
+			contains:    `"Label":"even"`,
+		},
+		{
+			name:        "merge-patch",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			patch:       `{ "Meta": { "Author": "someone" } }`,
+			status:      http.StatusOK,
+			revision:    3,
+			contains:    `"Author":"someone"`,
+		},
+		{
+			name:        "patch-fails",
+			contentType: "application/json-patch+json",
+			patch:       `[{ "op": "remove", "path": "/States/7" }]`,
+			status:      http.StatusUnprocessableEntity,
+			contains:    `"Code":"invalid-patch"`,
+		},
+		{
+			name:        "patched-machine-invalid",
+			contentType: "application/json-patch+json",
+			patch:       `[{ "op": "replace", "path": "/Start", "value": "q9" }]`,
+			status:      http.StatusUnprocessableEntity,
+			contains:    INVALID_MACHINE_MSG,
+		},
+		{
+			name:        "unsupported-content-type",
+			contentType: "application/json",
+			patch:       `{}`,
+			status:      http.StatusUnsupportedMediaType,
+			contains:    UNSUPPORTED_PATCH_MSG,
+		},
+	} {
+		res := patch(tc.contentType, tc.patch)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+		assert.Contains(t, res.Body.String(), tc.contains, tc.name)
+		if tc.status == http.StatusOK {
+			var m machinestore.Machine
+			json.Unmarshal(res.Body.Bytes(), &m)
+			assert.Equal(t, tc.revision, m.Revision, tc.name)
+			assert.Equal(t, "Odd a's", m.Name, tc.name)
+			assert.Equal(t, []string{"dfa"}, m.Tags, tc.name)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package rendercontroller
+
+import (
+	"net/http"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/render"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	UNKNOWN_FORMAT_MSG = `` +
+		`{"Err":"Query param 'format' must be one of 'dot', 'mermaid', 'svg' or 'tikz'"}`
+
+	CANNOT_HIGHLIGHT_MSG = "The run of the machine on the tape cannot be highlighted"
+
+	TAPE_NOT_ACCEPTED_MSG = `` +
+		`{"Err":"The tape is not accepted by the machine, so there is no path to highlight"}`
+
+	FAILED_TO_SIMULATE_MSG = `{"Err":"Failed to simulate the machine on the tape"}`
+
+	// Color of highlighted paths if query param 'color' is not given
+	defaultColor = "red"
+)
+
+// Content types of the formats that machines can be rendered in
+var formats = map[string]string{
+	"dot":     "text/vnd.graphviz; charset=utf-8",
+	"mermaid": "text/plain; charset=utf-8",
+	"svg":     "image/svg+xml; charset=utf-8",
+	"tikz":    "text/x-tex; charset=utf-8",
+}
+
+// Draws machines as diagrams
+type RenderController struct {
+	prefix string
+	budget simulation.Budget
+}
+
+func New() *RenderController {
+	return &RenderController{prefix: "/"}
+}
+
+func (c *RenderController) WithPrefix(prefix string) *RenderController {
+	return &RenderController{prefix: app.Trim(prefix), budget: c.budget}
+}
+
+// Limits the simulations run to find the paths that TikZ drawings highlight
+func (c *RenderController) WithBudget(budget simulation.Budget) *RenderController {
+	return &RenderController{prefix: c.prefix, budget: budget}
+}
+
+// Attaches this controller to the given router
+func (c *RenderController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("POST").Path("/render").HandlerFunc(c.Render)
+}
+
+// Draws the machine in the request body in the format given by query param
+// 'format': 'dot' (Graphviz), 'mermaid', 'svg' (the default) or 'tikz'
+// (LaTeX). The machine only needs to be a well formed graph, so machines that
+// cannot be simulated yet (such as partial DFAs, PDAs and TMs) can still be
+// drawn.
+//
+// TikZ drawings can highlight the path that the machine takes to accept query
+// param 'tape', in query param 'color' (red by default).
+//
+// If successful: 200 + the drawing.
+// If the machine is not a well formed graph: 422 + a list of diagnostics.
+// If the tape is given but not accepted: 422.
+// If the run on the tape runs out of budget or is cancelled: 200 + the result
+// of the run, as `/simulate` reports it.
+func (c *RenderController) Render(rw http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "svg"
+	}
+	contentType, ok := formats[format]
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(UNKNOWN_FORMAT_MSG))
+		return
+	}
+
+	doc, err := utils.LoadDocument(r)
+	if err == nil {
+		_, err = machine.Load(doc)
+	}
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	d, err := render.FromDocument(doc)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+
+	var out string
+	switch format {
+	case "dot":
+		out = d.Dot()
+	case "mermaid":
+		out = d.Mermaid()
+	case "svg":
+		out = d.Svg()
+	case "tikz":
+		highlight, ok := c.highlight(rw, r, doc)
+		if !ok {
+			return
+		}
+		out, err = d.Tikz(highlight)
+		if err != nil {
+			utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, CANNOT_HIGHLIGHT_MSG, err)
+			return
+		}
+	}
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", contentType)
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(out))
+}
+
+// Finds the path to highlight in a TikZ drawing, by running the machine on
+// query param 'tape'. Returns nil if no tape was given, and false if a
+// response has already been written. Runs that are abandoned are reported the
+// way `/simulate` reports them, rather than as tapes that are not accepted
+func (c *RenderController) highlight(
+	rw http.ResponseWriter,
+	r *http.Request,
+	doc map[string]interface{},
+) (*render.Highlight, bool) {
+	query := r.URL.Query()
+	tape, ok := query["tape"]
+	if !ok || len(tape) < 1 {
+		return nil, true
+	}
+	color := query.Get("color")
+	if color == "" {
+		color = defaultColor
+	}
+
+	m, err := automata.Load(doc)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, CANNOT_HIGHLIGHT_MSG, err)
+		return nil, false
+	}
+	res, err := simulation.Run(r.Context(), m.Simulate(tape[0]), c.budget)
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(FAILED_TO_SIMULATE_MSG))
+		return nil, false
+	}
+	switch res.Outcome {
+	case simulation.OutcomeBudgetExhausted, simulation.OutcomeCancelled:
+		utils.WriteDocument(rw, r, http.StatusOK, res)
+		return nil, false
+	}
+	if !res.Accepted {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		rw.Write([]byte(TAPE_NOT_ACCEPTED_MSG))
+		return nil, false
+	}
+	return &render.Highlight{Path: res.Path, Color: color}, true
+}
@@ -0,0 +1,131 @@
+package rendercontroller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const partialPDA = `
+{
+	"Type": "PDA",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+	"Transitions": [
+	  { "Start": "q0", "End": "q1", "Symbol": "a", "Pop": "Z", "Push": "AZ" }
+	]
+}`
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		query       string
+		machine     string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{
+			name:        "default-svg",
+			machine:     dfa.ODDA,
+			status:      http.StatusOK,
+			contentType: "image/svg+xml; charset=utf-8",
+			contains:    "<svg ",
+		},
+		{
+			name:        "dot",
+			query:       "?format=dot",
+			machine:     dfa.ODDA,
+			status:      http.StatusOK,
+			contentType: "text/vnd.graphviz; charset=utf-8",
+			contains:    "digraph machine {",
+		},
+		{
+			name:        "mermaid-pda",
+			query:       "?format=mermaid",
+			machine:     partialPDA,
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			contains:    "s0 --> s1: a, Z → AZ",
+		},
+		{
+			name:        "tikz",
+			query:       "?format=tikz",
+			machine:     dfa.ODDA,
+			status:      http.StatusOK,
+			contentType: "text/x-tex; charset=utf-8",
+			contains:    `\node[state, initial] (s0)`,
+		},
+		{
+			name:     "tikz-highlight",
+			query:    "?format=tikz&tape=ab&color=blue",
+			machine:  dfa.ODDA,
+			status:   http.StatusOK,
+			contains: `\node[state, accepting, highlight] (s1)`,
+		},
+		{
+			name:     "tikz-tape-not-accepted",
+			query:    "?format=tikz&tape=aa",
+			machine:  dfa.ODDA,
+			status:   http.StatusUnprocessableEntity,
+			contains: TAPE_NOT_ACCEPTED_MSG,
+		},
+		{
+			name:     "tikz-invalid-color",
+			query:    "?format=tikz&tape=a&color=red%7D",
+			machine:  dfa.ODDA,
+			status:   http.StatusUnprocessableEntity,
+			contains: CANNOT_HIGHLIGHT_MSG,
+		},
+		{
+			name:     "unknown-format",
+			query:    "?format=png",
+			machine:  dfa.ODDA,
+			status:   http.StatusBadRequest,
+			contains: UNKNOWN_FORMAT_MSG,
+		},
+		{
+			name:     "invalid-machine",
+			machine:  `{"Type": "DFA", "Start": "q0", "States": [], "Transitions": []}`,
+			status:   http.StatusUnprocessableEntity,
+			contains: `"Pointer":"/Start"`,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := mux.NewRouter()
+			New().Attach(router)
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/render"+tc.query, strings.NewReader(tc.machine))
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.status, recorder.Code)
+			if tc.contentType != "" {
+				assert.Equal(t, tc.contentType, recorder.Header().Get("Content-Type"))
+			}
+			assert.Contains(t, recorder.Body.String(), tc.contains)
+		})
+	}
+}
+
+// Runs that run out of budget are reported as `/simulate` reports them, not as
+// tapes that are not accepted
+func TestHighlightOutOfBudget(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().WithBudget(simulation.Budget{MaxSteps: 1}).Attach(router)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/render?format=tikz&tape=aaa", strings.NewReader(dfa.ODDA))
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), `"Outcome":"BudgetExhausted"`)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/flapflapio/simulator/core/controllers/utils"
 	"github.com/flapflapio/simulator/core/simulation/automata"
@@ -11,7 +13,11 @@ import (
 	"github.com/obonobo/mux"
 )
 
-const schemaFilename = "machine.schema.json"
+const (
+	schemaFilename = "machine.schema.json"
+
+	UNKNOWN_MACHINE_TYPE_MSG = `{"Err":"There is no schema for this type of machine"}`
+)
 
 type SchemaController struct {
 	prefix string
@@ -30,42 +36,88 @@ func WithPrefix(prefix string) *SchemaController {
 func (sc *SchemaController) Attach(router *mux.Router) {
 	r := utils.CreateSubrouter(router, sc.prefix)
 	r.Methods("GET").Path("/machine.schema.json").HandlerFunc(Schema)
+	r.Methods("GET").
+		Path(fmt.Sprintf("/schemas/v%v/{type:[a-z]+}.schema.json", machine.SchemaVersion)).
+		HandlerFunc(TypeSchema)
 	r.Methods("POST").Path("/validate").HandlerFunc(Validate)
+	r.Methods("POST").Path("/lint").HandlerFunc(Lint)
 }
 
-// If successful: 200 + machine json.
-// If the machine in request body is invalid: 422.
+// If successful: 200 + the machine, in YAML if the client accepts it (and
+// sent `Accept: application/yaml`) or JSON otherwise. The machine itself may
+// be sent as JSON or YAML.
+// If the machine in request body is invalid: 422 + a list of diagnostics, each
+// with a JSON pointer to the offending element of the machine.
+// With `?complete=true`, a DFA that is missing transitions is completed with a
+// trap state, and the completed machine is returned.
+// Documents of older versions are migrated to the current version, and the
+// schema of the returned machine is linked in a `Link` header
+// (rel="describedby").
 func Validate(rw http.ResponseWriter, r *http.Request) {
-	m, err := automata.Load(r.Body)
+	m, err := utils.LoadMachine(r)
 	if err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, "", err)
 		return
 	}
-	rw.WriteHeader(http.StatusOK)
-	_, err = rw.Write([]byte(m.Json()))
+	doc := m.JsonMap()
+	if t, ok := doc["Type"].(string); ok && machine.Schema(t) != nil {
+		rw.Header().Add("Link", fmt.Sprintf(`<%v>; rel="describedby"`, machine.SchemaPath(t)))
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, doc)
+}
+
+// If the machine loads: 200 + a (possibly empty) list of lint warnings, in
+// JSON or YAML like `Validate`.
+// If the machine in request body is invalid: 422 + a list of diagnostics.
+func Lint(rw http.ResponseWriter, r *http.Request) {
+	doc, err := utils.LoadDocument(r)
 	if err != nil {
-		panic(err)
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, "", err)
+		return
 	}
+	warnings, err := automata.Lint(doc)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, "", err)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, map[string]interface{}{
+		"Warnings": warnings,
+	})
 }
 
+// The schema of machines of every type: a `oneOf` of the schemas served by
+// `TypeSchema`
 func Schema(rw http.ResponseWriter, r *http.Request) {
+	writeSchema(rw, schemaFilename, machine.GetSchema())
+}
+
+// The schema of one type of machine, e.g. `/schemas/v1/dfa.schema.json`.
+// If the type is not known: 404.
+func TypeSchema(rw http.ResponseWriter, r *http.Request) {
+	t := strings.ToUpper(mux.Vars(r)["type"])
+	schema := machine.Schema(t)
+	if schema == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(UNKNOWN_MACHINE_TYPE_MSG))
+		return
+	}
+	writeSchema(rw, path.Base(machine.SchemaPath(t)), schema)
+}
+
+func writeSchema(rw http.ResponseWriter, filename string, schema map[string]interface{}) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+
 	rw.Header().Del("Content-Disposition")
 	rw.Header().Add(
 		"Content-Disposition",
-		fmt.Sprintf("attachment; filename=\"%s\"", schemaFilename))
+		fmt.Sprintf("attachment; filename=\"%s\"", filename))
 
 	rw.Header().Del("Content-Type")
 	rw.Header().Add("Content-Type", "application/json; charset=utf-8")
 
 	rw.WriteHeader(200)
-	rw.Write(getSchema())
-}
-
-func getSchema() []byte {
-	schema := machine.GetSchema()
-	data, err := json.Marshal(schema)
-	if err != nil {
-		panic(err)
-	}
-	return data
+	rw.Write(data)
 }
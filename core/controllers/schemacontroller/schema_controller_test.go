@@ -6,12 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
 	"github.com/flapflapio/simulator/core/simulation/machine"
 	"github.com/flapflapio/simulator/internal/simtest"
 	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
 )
 
 type assertion struct {
@@ -257,15 +259,83 @@ func TestGetMachineSchema(t *testing.T) {
 	controller := WithPrefix(prefix)
 	controller.Attach(router)
 
-	body := string(machine.SCHEMA)
+	body, err := json.Marshal(machine.GetSchema())
+	assert.NoError(t, err)
+	receive := string(body)
 	assertEndpoint(t, router, assertion{
 		method:      "GET",
 		path:        prefix + "/machine.schema.json",
 		status:      http.StatusOK,
-		receiveBody: &body,
+		receiveBody: &receive,
 	})
 }
 
+func TestGetTypeSchema(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	for _, typ := range machine.SchemaTypes() {
+		body, err := json.Marshal(machine.Schema(typ))
+		assert.NoError(t, err)
+		receive := string(body)
+		assertEndpoint(t, router, assertion{
+			method:      "GET",
+			path:        machine.SchemaPath(typ),
+			status:      http.StatusOK,
+			receiveBody: &receive,
+		})
+	}
+
+	for _, path := range []string{
+		"/schemas/v1/mealy.schema.json",
+		"/schemas/v2/dfa.schema.json",
+		"/schemas/v1/DFA.schema.json",
+	} {
+		assertEndpoint(t, router, assertion{
+			method: "GET",
+			path:   path,
+			status: http.StatusNotFound,
+		})
+	}
+}
+
+// Unversioned documents are migrated, and the response links to its schema
+func TestPostValidateMigratesDocuments(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	send := `
+	{
+		"Type": "My DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [{ "Id": "q0", "Ending": true }],
+		"Transitions": [{ "Start": "q0", "End": "q0", "Symbol": "a" }]
+	}`
+	req := httptest.NewRequest("POST", "/validate", bytes.NewBufferString(send))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `</schemas/v1/dfa.schema.json>; rel="describedby"`, recorder.Header().Get("Link"))
+	assert.JSONEq(t, `
+	{
+		"SchemaVersion": 1,
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [{ "Id": "q0", "Ending": true }],
+		"Transitions": [{ "Start": "q0", "End": "q0", "Symbol": "a" }]
+	}`, recorder.Body.String())
+
+	send = strings.Replace(send, `"Type"`, `"SchemaVersion": 7, "Type"`, 1)
+	req = httptest.NewRequest("POST", "/validate", bytes.NewBufferString(send))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), machine.CodeUnsupportedSchemaVersion)
+}
+
 func TestPostValidate(t *testing.T) {
 	prefix := "/some/path"
 	router := mux.NewRouter()
@@ -289,6 +359,210 @@ func TestPostValidate(t *testing.T) {
 	}
 }
 
+func TestPostValidateDiagnostics(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	send := `
+	{
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0", "Ending": false },
+		  { "Id": "q1", "Ending": true }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "a" },
+		  { "Start": "q0", "End": "q0", "Symbol": "b" },
+		  { "Start": "q1", "End": "q1", "Symbol": "c" },
+		  { "Start": "q1", "End": "q0", "Symbol": "a" }
+		]
+	}`
+
+	receive := `
+	{
+		"Errors": [
+			{
+				"Pointer": "/States/1",
+				"Code": "missing-transition",
+				"Severity": "error",
+				"Message": "DFA is invalid, state 'q1' is missing a transition for symbol 'b'"
+			},
+			{
+				"Pointer": "/Transitions/2/Symbol",
+				"Code": "symbol-not-in-alphabet",
+				"Severity": "error",
+				"Message": "DFA is invalid, symbol 'c' of transition q1 -> q1 is not present in the alphabet [a b]"
+			}
+		]
+	}`
+
+	assertEndpoint(t, router, assertion{
+		method:      "POST",
+		path:        "/validate",
+		status:      http.StatusUnprocessableEntity,
+		sendBody:    &send,
+		receiveBody: &receive,
+	})
+}
+
+func TestPostValidateComplete(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	send := `
+	{
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0", "Ending": true }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q0", "Symbol": "a" }
+		]
+	}`
+
+	assertEndpoint(t, router, assertion{
+		method:   "POST",
+		path:     "/validate",
+		status:   http.StatusUnprocessableEntity,
+		sendBody: &send,
+	})
+
+	receive := `
+	{
+		"SchemaVersion": 1,
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Start": "q0",
+		"Trap": "q1",
+		"States": [
+		  { "Id": "q0", "Ending": true },
+		  { "Id": "q1", "Ending": false }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q0", "Symbol": "a" },
+		  { "Start": "q0", "End": "q1", "Symbol": "b" },
+		  { "Start": "q1", "End": "q1", "Symbol": "a" },
+		  { "Start": "q1", "End": "q1", "Symbol": "b" }
+		]
+	}`
+	assertEndpoint(t, router, assertion{
+		method:      "POST",
+		path:        "/validate?complete=true",
+		status:      http.StatusOK,
+		sendBody:    &send,
+		receiveBody: &receive,
+	})
+}
+
+func TestPostValidateYaml(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	send := `
+type: DFA
+alphabet: "01"
+startingState: even
+states:
+  - { id: even, ending: true }
+  - { id: odd }
+transitions:
+  - { start: even, end: odd, symbol: 1 }
+  - { start: even, end: even, symbol: 0 }
+  - { start: odd, end: even, symbol: 1 }
+  - { start: odd, end: odd, symbol: 0 }
+`
+	req := httptest.NewRequest("POST", "/validate", bytes.NewBufferString(send))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/yaml; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `Alphabet: "01"
+SchemaVersion: 1
+Start: even
+States:
+  - Ending: true
+    Id: even
+  - Ending: false
+    Id: odd
+Transitions:
+  - End: odd
+    Start: even
+    Symbol: "1"
+  - End: even
+    Start: even
+    Symbol: "0"
+  - End: even
+    Start: odd
+    Symbol: "1"
+  - End: odd
+    Start: odd
+    Symbol: "0"
+Type: DFA
+`, recorder.Body.String())
+}
+
+func TestPostLint(t *testing.T) {
+	router := mux.NewRouter()
+	New().Attach(router)
+
+	clean := dfa.ODDA
+	noWarnings := `{"Warnings": []}`
+	assertEndpoint(t, router, assertion{
+		method:      "POST",
+		path:        "/lint",
+		status:      http.StatusOK,
+		sendBody:    &clean,
+		receiveBody: &noWarnings,
+	})
+
+	unreachable := `
+	{
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0", "Ending": true },
+		  { "Id": "q1", "Ending": true }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q0", "Symbol": "a" },
+		  { "Start": "q1", "End": "q0", "Symbol": "a" }
+		]
+	}`
+	warnings := `
+	{
+		"Warnings": [{
+			"Pointer": "/States/1",
+			"Code": "unreachable-state",
+			"Severity": "warning",
+			"Message": "state 'q1' cannot be reached from the start state",
+			"States": ["q1"]
+		}]
+	}`
+	assertEndpoint(t, router, assertion{
+		method:      "POST",
+		path:        "/lint",
+		status:      http.StatusOK,
+		sendBody:    &unreachable,
+		receiveBody: &warnings,
+	})
+
+	invalid := "{}"
+	assertEndpoint(t, router, assertion{
+		method:   "POST",
+		path:     "/lint",
+		status:   http.StatusUnprocessableEntity,
+		sendBody: &invalid,
+	})
+}
+
 func assertEndpoint(
 	t *testing.T,
 	r *mux.Router,
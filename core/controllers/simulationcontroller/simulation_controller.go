@@ -1,23 +1,32 @@
 package simulationcontroller
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/flapflapio/simulator/core/app"
 	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/services/resultcache"
 	"github.com/flapflapio/simulator/core/simulation"
 	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/machine"
 	"github.com/obonobo/mux"
 )
 
 const (
-	INVALID_MACHINE_MSG = `` +
-		`{"Err":"The machine that was sent is not ` +
-		`valid or otherwise could not be processed"}`
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
 
 	PLEASE_PROVIDE_A_TAPE_MSG = `` +
 		`{"Err":"Please provide an input with query param 'tape'"}`
@@ -30,11 +39,31 @@ const (
 
 	FAILED_TO_CREATE_A_RESPONSE = `` +
 		`{"Err":"Failed to create a response"}`
+
+	INVALID_BUDGET_MSG = `` +
+		`{"Err":"Query params 'maxSteps' and 'timeout' must be positive integers"}`
+
+	PLEASE_STREAM_A_TAPE_MSG = `` +
+		`{"Err":"Please send a multipart body with a 'machine' part followed ` +
+		`by a 'tape' part, or send the tape as the body and the id of a ` +
+		`stored machine with query param 'machine'"}`
+
+	MACHINE_CANNOT_BE_STREAMED_MSG = `` +
+		`{"Err":"This type of machine does not support streamed input"}`
+
+	FAILED_TO_READ_THE_TAPE_MSG = `` +
+		`{"Err":"Failed to read the tape"}`
+
+	MACHINE_NOT_FOUND_MSG = `` +
+		`{"Err":"No stored machine with the id in query param 'machine' was found"}`
 )
 
 type SimulationController struct {
 	prefix    string
 	simulator simulation.Simulator
+	budget    simulation.Budget
+	machines  machinestore.Store
+	cache     *resultcache.ResultCache
 }
 
 func New(simulator simulation.Simulator) *SimulationController {
@@ -48,15 +77,15 @@ func New(simulator simulation.Simulator) *SimulationController {
 func (c *SimulationController) Attach(router *mux.Router) {
 	r := utils.CreateSubrouter(router, c.prefix)
 	r.Methods("POST").Path("/simulate").HandlerFunc(c.DoSimulation)
+	r.Methods("POST").Path("/simulate/stream").HandlerFunc(c.StreamSimulation)
 	r.Methods("DELETE").Path("/simulation/{id}").HandlerFunc(c.EndSimulation)
 	r.Methods("POST").Path("/simulation/start").HandlerFunc(c.StartSimulation)
 }
 
 func (c *SimulationController) StartSimulation(rw http.ResponseWriter, r *http.Request) {
-	m, err := automata.Load(r.Body)
+	m, err := utils.LoadMachine(r)
 	if err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		rw.Write([]byte(INVALID_MACHINE_MSG))
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
 		return
 	}
 
@@ -84,6 +113,46 @@ func (c *SimulationController) WithPrefix(prefix string) *SimulationController {
 	return &SimulationController{
 		prefix:    app.Trim(prefix),
 		simulator: c.simulator,
+		budget:    c.budget,
+		machines:  c.machines,
+		cache:     c.cache,
+	}
+}
+
+// Limits every simulation run by `/simulate` to the given budget. Clients may
+// tighten the budget per request using the `maxSteps` and `timeout`
+// (milliseconds) query params, but they can never loosen it
+func (c *SimulationController) WithBudget(budget simulation.Budget) *SimulationController {
+	return &SimulationController{
+		prefix:    c.prefix,
+		simulator: c.simulator,
+		budget:    budget,
+		machines:  c.machines,
+		cache:     c.cache,
+	}
+}
+
+// Lets `/simulate` and `/simulate/stream` run stored machines: with query
+// param 'machine', the machine with that id is loaded from the store instead
+// of the body
+func (c *SimulationController) WithMachines(store machinestore.Store) *SimulationController {
+	return &SimulationController{
+		prefix:    c.prefix,
+		simulator: c.simulator,
+		budget:    c.budget,
+		machines:  store,
+		cache:     c.cache,
+	}
+}
+
+// Caches the results of `/simulate`, see `cacheLookup`
+func (c *SimulationController) WithCache(cache *resultcache.ResultCache) *SimulationController {
+	return &SimulationController{
+		prefix:    c.prefix,
+		simulator: c.simulator,
+		budget:    c.budget,
+		machines:  c.machines,
+		cache:     cache,
 	}
 }
 
@@ -115,11 +184,18 @@ func (c *SimulationController) EndSimulation(rw http.ResponseWriter, r *http.Req
 }
 
 func (c *SimulationController) DoSimulation(rw http.ResponseWriter, r *http.Request) {
-	m, err := automata.Load(r.Body)
-
+	doc, err := c.loadDocument(r)
+	if errors.Is(err, machinestore.ErrNotFound) || errors.Is(err, machinestore.ErrInvalidId) {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(MACHINE_NOT_FOUND_MSG))
+		return
+	}
+	var m simulation.Machine
+	if err == nil {
+		m, err = automata.Load(doc)
+	}
 	if err != nil {
-		rw.WriteHeader(http.StatusUnprocessableEntity)
-		rw.Write([]byte(INVALID_MACHINE_MSG))
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
 		log.Println(err)
 		return
 	}
@@ -132,22 +208,33 @@ func (c *SimulationController) DoSimulation(rw http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var sim simulation.Simulation
-
-	// Create a new simulation
-	id, err := c.simulator.Start(m, tape[0])
-	if check(err, rw, FAILED_TO_CREATE_A_NEW_SIMULATION) {
+	budget, err := c.requestBudget(r)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(INVALID_BUDGET_MSG))
 		return
 	}
 
-	// Run the simulation from start to finish
-	for sim = c.simulator.Get(id); !sim.Done(); sim.Step() {
-	}
+	cached := c.lookup(doc, tape[0], budget)
+	res, hit := cached.get()
+	if !hit {
+		// Create a new simulation
+		id, err := c.simulator.Start(m, tape[0])
+		if check(err, rw, FAILED_TO_CREATE_A_NEW_SIMULATION) {
+			return
+		}
 
-	// Grab the result of the simulation
-	res, err := sim.Result()
-	if check(err, rw, FAILED_TO_OBTAIN_RESULTS_OF_SIMULATION) {
-		return
+		// Run the simulation until it finishes, the client goes away, or it
+		// runs out of budget
+		res, err = simulation.Run(r.Context(), c.simulator.Get(id), budget)
+		if check(err, rw, FAILED_TO_OBTAIN_RESULTS_OF_SIMULATION) {
+			return
+		}
+		if res.Outcome == simulation.OutcomeCancelled {
+			log.Printf("Simulation %v cancelled: %v", id, r.Context().Err())
+		}
+		c.simulator.End(id)
+		cached.put(res)
 	}
 
 	// Serialize result
@@ -155,13 +242,253 @@ func (c *SimulationController) DoSimulation(rw http.ResponseWriter, r *http.Requ
 	if check(err, rw, FAILED_TO_CREATE_A_RESPONSE) {
 		return
 	}
+	data = append(data, '\n')
+
+	// Simulations have no side effects, so a client that already has this
+	// result is told so even though this is a POST
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+	rw.Header().Set("ETag", etag)
+	if hit {
+		rw.Header().Set("X-Cache", "HIT")
+	} else if cached != nil {
+		rw.Header().Set("X-Cache", "MISS")
+	}
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
 
 	// Write result to response body
 	rw.Header().Del("Content-Type")
 	rw.Header().Add("Content-Type", "application/json; charset=utf-8")
 	rw.WriteHeader(http.StatusOK)
+	rw.Write(data)
+}
+
+// Loads the machine document of a `/simulate` request: the stored machine with
+// the id in query param 'machine' if there is a store, otherwise the machine in
+// the body
+func (c *SimulationController) loadDocument(r *http.Request) (map[string]interface{}, error) {
+	id := r.URL.Query().Get("machine")
+	if id == "" || c.machines == nil {
+		return utils.LoadDocument(r)
+	}
+	stored, err := c.machines.Get(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	return utils.LoadDocumentFrom(r, stored.Document)
+}
+
+// A lookup of the result of a `/simulate` request in the cache. Results are
+// cached by the hash of the canonical form of the machine (see
+// `machine.Hash`), so machines that only differ in the names of their states
+// share results, and the paths of cached results use canonical state names
+type cacheLookup struct {
+	cache *resultcache.ResultCache
+	key   string
+
+	// The canonical name of each state of the machine
+	names map[string]string
+}
+
+// Looks up the result of simulating a machine over a tape. Returns nil if
+// there is no cache. Results are keyed by the hash of the machine, which keeps
+// the order of transitions that a DFA chooses between (see `machine.Canonical`)
+func (c *SimulationController) lookup(
+	doc map[string]interface{},
+	tape string,
+	budget simulation.Budget,
+) *cacheLookup {
+	if c.cache == nil {
+		return nil
+	}
+	hash, names, err := machine.HashWithNames(doc)
+	if err != nil {
+		return nil
+	}
+	return &cacheLookup{
+		cache: c.cache,
+		key:   fmt.Sprintf("%v/%v/%x", hash, budget.MaxSteps, sha256.Sum256([]byte(tape))),
+		names: names,
+	}
+}
+
+// The cached result, with the state names of the machine
+func (l *cacheLookup) get() (simulation.Result, bool) {
+	if l == nil {
+		return simulation.Result{}, false
+	}
+	res, ok := l.cache.Get(l.key)
+	if !ok {
+		return res, false
+	}
+	ids := make(map[string]string, len(l.names))
+	for id, name := range l.names {
+		ids[name] = id
+	}
+	for i, name := range res.Path {
+		res.Path[i] = ids[name]
+	}
+	return res, true
+}
+
+// Caches a result, unless it depends on more than the machine, the tape and
+// the step budget: runs that timed out or were cancelled, and runs that went
+// through states that are not in the machine, such as generated trap states
+func (l *cacheLookup) put(res simulation.Result) {
+	if l == nil {
+		return
+	}
+	switch res.Outcome {
+	case simulation.OutcomeAccepted, simulation.OutcomeRejected, simulation.OutcomeHalted:
+	default:
+		return
+	}
+	path := make([]string, len(res.Path))
+	for i, id := range res.Path {
+		name, ok := l.names[id]
+		if !ok {
+			return
+		}
+		path[i] = name
+	}
+	res.Path = path
+	l.cache.Put(l.key, res)
+}
+
+// Whether an If-None-Match header lists an entity tag
+func matchesETag(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs a simulation over a tape that is streamed in the request body, so that
+// the tape is never held in memory as a whole. The body is either multipart
+// (a 'machine' part followed by a 'tape' part), or the raw tape with the id
+// of a stored machine in query param 'machine' (see `WithMachines`). Inline
+// machines must be sent as multipart, as URLs are limited in length. The same
+// budget as `/simulate` applies
+func (c *SimulationController) StreamSimulation(rw http.ResponseWriter, r *http.Request) {
+	budget, err := c.requestBudget(r)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(INVALID_BUDGET_MSG))
+		return
+	}
+
+	doc, tape, err := c.streamedDocument(r)
+	if errors.Is(err, machinestore.ErrNotFound) || errors.Is(err, machinestore.ErrInvalidId) {
+		rw.WriteHeader(http.StatusNotFound)
+		rw.Write([]byte(MACHINE_NOT_FOUND_MSG))
+		return
+	}
+	var diags machine.Diagnostics
+	if errors.As(err, &diags) {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(PLEASE_STREAM_A_TAPE_MSG))
+		return
+	}
+
+	m, err := utils.LoadMachineFrom(r, doc)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	sm, ok := m.(simulation.StreamMachine)
+	if !ok {
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		rw.Write([]byte(MACHINE_CANNOT_BE_STREAMED_MSG))
+		return
+	}
+
+	res, err := simulation.Run(r.Context(), sm.SimulateStream(tape), budget)
+	if err != nil {
+		log.Printf("Failed to read streamed tape: %v", err)
+		rw.WriteHeader(http.StatusBadRequest)
+		rw.Write([]byte(FAILED_TO_READ_THE_TAPE_MSG))
+		return
+	}
+
+	data, err := json.Marshal(res)
+	if check(err, rw, FAILED_TO_CREATE_A_RESPONSE) {
+		return
+	}
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
 	rw.Write(append(data, '\n'))
-	c.simulator.End(id)
+}
+
+// Splits a `/simulate/stream` request into the machine document and the tape.
+// For multipart requests, the machine part is read in full (it may be YAML if
+// the part says so) but the tape part is left unread. If the machine part
+// cannot be parsed, the error is a `machine.Diagnostics`
+func (c *SimulationController) streamedDocument(r *http.Request) (interface{}, io.Reader, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		id := r.URL.Query().Get("machine")
+		if id == "" || c.machines == nil {
+			return nil, nil, errors.New("no stored machine in query param 'machine'")
+		}
+		stored, err := c.machines.Get(r.Context(), id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return stored.Document, r.Body, nil
+	}
+
+	parts, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+	part, err := parts.NextPart()
+	if err != nil || part.FormName() != "machine" {
+		return nil, nil, errors.New("the first part must be the 'machine'")
+	}
+	var doc interface{}
+	if utils.IsYaml(part.Header.Get("Content-Type")) {
+		if doc, err = machine.LoadYamlMap(part); err != nil {
+			return nil, nil, machine.DiagnosticsOf(err)
+		}
+	} else if doc, err = io.ReadAll(part); err != nil {
+		return nil, nil, err
+	}
+	part, err = parts.NextPart()
+	if err != nil || part.FormName() != "tape" {
+		return nil, nil, errors.New("the second part must be the 'tape'")
+	}
+	return doc, part, nil
+}
+
+// Combines the controller's budget with any limits requested in the query
+func (c *SimulationController) requestBudget(r *http.Request) (simulation.Budget, error) {
+	var requested simulation.Budget
+	query := r.URL.Query()
+	if s := query.Get("maxSteps"); s != "" {
+		steps, err := strconv.Atoi(s)
+		if err != nil || steps <= 0 {
+			return simulation.Budget{}, fmt.Errorf("invalid maxSteps '%v'", s)
+		}
+		requested.MaxSteps = steps
+	}
+	if s := query.Get("timeout"); s != "" {
+		ms, err := strconv.Atoi(s)
+		if err != nil || ms <= 0 {
+			return simulation.Budget{}, fmt.Errorf("invalid timeout '%v'", s)
+		}
+		requested.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	return c.budget.Min(requested), nil
 }
 
 func check(err error, rw http.ResponseWriter, msg string) bool {
@@ -2,18 +2,27 @@ package simulationcontroller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/services/resultcache"
+	"github.com/flapflapio/simulator/core/services/simulatorservice"
 	"github.com/flapflapio/simulator/core/simulation"
 	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
 	"github.com/flapflapio/simulator/internal/simtest"
 	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
 )
 
 var defaultService = func() *mockSimulatorService {
@@ -25,6 +34,7 @@ type testCaseDoSimulation struct {
 	status        int
 	method        string
 	tape          string
+	query         string
 	machine       string
 	response      string
 	service       func() *mockSimulatorService
@@ -43,9 +53,48 @@ var testCasesDoSimulation = []testCaseDoSimulation{
 		response: `{
 			"Accepted": true,
 			"Path": ["q0", "q1", "q2", "q3"],
-			"RemainingInput": ""
+			"RemainingInput": "",
+			"Outcome": "Accepted"
 		}`,
 	},
+	{
+		name:          "valid-max-steps-exhausted",
+		service:       defaultService,
+		serviceCalled: [3]int{1, 1, 1},
+		method:        "POST",
+		tape:          "aaba",
+		query:         "&maxSteps=2",
+		machine:       dfa.ODDA,
+		status:        http.StatusOK,
+		response: `{
+			"Accepted": false,
+			"Path": ["q0", "q1"],
+			"RemainingInput": "ba",
+			"Outcome": "BudgetExhausted"
+		}`,
+	},
+	{
+		name:          "invalid-max-steps",
+		service:       defaultService,
+		serviceCalled: [3]int{0, 0, 0},
+		method:        "POST",
+		tape:          "aaba",
+		query:         "&maxSteps=-3",
+		machine:       dfa.ODDA,
+		status:        http.StatusBadRequest,
+		response:      INVALID_BUDGET_MSG,
+	},
+	{
+		name:          "invalid-timeout",
+		service:       defaultService,
+		serviceCalled: [3]int{0, 0, 0},
+		method:        "POST",
+		tape:          "aaba",
+		query:         "&timeout=soon",
+		machine:       dfa.ODDA,
+		status:        http.StatusBadRequest,
+		response:      INVALID_BUDGET_MSG,
+	},
 	{
 		name:          "invalid-doesn't-load",
 		service:       defaultService,
@@ -54,7 +103,42 @@ var testCasesDoSimulation = []testCaseDoSimulation{
 		tape:          "aaba",
 		machine:       "{}",
 		status:        http.StatusUnprocessableEntity,
-		response:      INVALID_MACHINE_MSG,
+		response: `{
+			"Err": "` + INVALID_MACHINE_MSG + `",
+			"Errors": [{
+				"Pointer": "/Type",
+				"Code": "schema-required",
+				"Severity": "error",
+				"Message": "invalid document, field 'Type' is required"
+			}]
+		}`,
+	},
+	{
+		name:          "invalid-missing-transition",
+		service:       defaultService,
+		serviceCalled: [3]int{0, 0, 0},
+		method:        "POST",
+		tape:          "aaba",
+		machine: `{
+			"Type": "DFA",
+			"Alphabet": "ab",
+			"Start": "q0",
+			"States": [{ "Id": "q0", "Ending": true }],
+			"Transitions": [
+				{ "Start": "q0", "End": "q0", "Symbol": "a" },
+				{ "Start": "q0", "End": "q9", "Symbol": "b" }
+			]
+		}`,
+		status: http.StatusUnprocessableEntity,
+		response: `{
+			"Err": "` + INVALID_MACHINE_MSG + `",
+			"Errors": [{
+				"Pointer": "/Transitions/1/End",
+				"Code": "unknown-state",
+				"Severity": "error",
+				"Message": "state with id 'q9' was not found in machine"
+			}]
+		}`,
 	},
 	{
 		name:          "invalid-no-tape-provided",
@@ -105,7 +189,7 @@ func TestDoSimulation(t *testing.T) {
 
 			tt := ""
 			if tc.tape != "" {
-				tt = fmt.Sprintf("?tape=%v", tc.tape)
+				tt = fmt.Sprintf("?tape=%v%v", tc.tape, tc.query)
 			}
 
 			req := simtest.MustCreateRequest(t,
@@ -147,6 +231,252 @@ func TestWithPrefix(t *testing.T) {
 	assertStuff(t, tc, recorder, service)
 }
 
+func TestWithBudget(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	service := defaultService()
+	controller := New(service).WithBudget(simulation.Budget{MaxSteps: 3})
+	controller.Attach(router)
+	recorder := httptest.NewRecorder()
+
+	// Requesting a looser budget than the controller allows has no effect
+	req := simtest.MustCreateRequest(t,
+		"POST",
+		"/simulate?tape=aaaaa&maxSteps=100",
+		bytes.NewBufferString(dfa.ODDA))
+
+	router.ServeHTTP(recorder, req)
+	assertStatusCode(t, http.StatusOK, recorder)
+	assertResponse(t, `{
+		"Accepted": false,
+		"Path": ["q0", "q1", "q2"],
+		"RemainingInput": "aa",
+		"Outcome": "BudgetExhausted"
+	}`, recorder.Body.String())
+}
+
+func TestWithMachines(t *testing.T) {
+	t.Parallel()
+	store := machinestore.NewMemoryStore()
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(dfa.ODDA), &doc); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.Create(context.Background(), machinestore.Machine{
+		Id:       "odd-a",
+		Name:     "Odd number of a's",
+		Document: doc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		query    string
+		status   int
+		response string
+	}{
+		{
+			name:   "stored",
+			query:  "?machine=odd-a&tape=aaba",
+			status: http.StatusOK,
+			response: `{
+				"Accepted": true,
+				"Path": ["q0", "q1", "q2", "q3"],
+				"RemainingInput": "",
+				"Outcome": "Accepted"
+			}`,
+		},
+		{
+			name:     "not-found",
+			query:    "?machine=even-a&tape=aaba",
+			status:   http.StatusNotFound,
+			response: MACHINE_NOT_FOUND_MSG,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := mux.NewRouter()
+			New(defaultService()).WithMachines(store).Attach(router)
+			recorder := httptest.NewRecorder()
+			req := simtest.MustCreateRequest(t, "POST", "/simulate"+tc.query, nil)
+			router.ServeHTTP(recorder, req)
+			assertStatusCode(t, tc.status, recorder)
+			assertResponse(t, tc.response, recorder.Body.String())
+		})
+	}
+}
+
+func TestWithCache(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(simulatorservice.New()).WithCache(resultcache.New(10, time.Minute)).Attach(router)
+	renamed := strings.NewReplacer(
+		`"q0"`, `"even"`, `"q1"`, `"odd"`,
+	).Replace(dfa.ODDA)
+
+	simulate := func(machine, query, etag string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := simtest.MustCreateRequest(t, "POST", "/simulate?tape=aab"+query,
+			bytes.NewBufferString(machine))
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := simulate(dfa.ODDA, "", "")
+	assertStatusCode(t, http.StatusOK, first)
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+
+	// The same machine with other state names shares the cached result
+	second := simulate(renamed, "", "")
+	assertStatusCode(t, http.StatusOK, second)
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assertResponse(t, `{
+		"Accepted": false,
+		"Path": ["even", "odd", "even", "even"],
+		"RemainingInput": "",
+		"Outcome": "Rejected"
+	}`, second.Body.String())
+	assert.NotEqual(t, first.Header().Get("ETag"), second.Header().Get("ETag"))
+
+	// Other options are cached separately
+	assert.Equal(t, "MISS", simulate(dfa.ODDA, "&maxSteps=2", "").Header().Get("X-Cache"))
+
+	// Clients that have the result already get an empty response
+	third := simulate(dfa.ODDA, "", `"other", `+first.Header().Get("ETag"))
+	assertStatusCode(t, http.StatusNotModified, third)
+	assert.Empty(t, third.Body.String())
+	assert.Equal(t, "HIT", third.Header().Get("X-Cache"))
+}
+
+func TestStreamSimulation(t *testing.T) {
+	store := machinestore.NewMemoryStore()
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(dfa.ODDA), &doc); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.Create(context.Background(), machinestore.Machine{Id: "odd-a", Document: doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	multipartBody := func(parts ...string) (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		for i := 0; i < len(parts); i += 2 {
+			w.WriteField(parts[i], parts[i+1])
+		}
+		w.Close()
+		return &body, w.FormDataContentType()
+	}
+
+	for _, tc := range []struct {
+		name     string
+		request  func() *http.Request
+		status   int
+		response string
+	}{
+		{
+			name: "raw-body",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST", "/simulate/stream?machine=odd-a",
+					strings.NewReader("aaba"))
+			},
+			status: http.StatusOK,
+			response: `{
+				"Accepted": true,
+				"Path": ["q1"],
+				"RemainingInput": "",
+				"Outcome": "Accepted",
+				"Steps": 4
+			}`,
+		},
+		{
+			name: "raw-body-not-found",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST", "/simulate/stream?machine=even-a",
+					strings.NewReader("aaba"))
+			},
+			status:   http.StatusNotFound,
+			response: MACHINE_NOT_FOUND_MSG,
+		},
+		{
+			name: "raw-body-inline-machine",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST",
+					"/simulate/stream?machine="+url.QueryEscape(dfa.ODDA),
+					strings.NewReader("aaba"))
+			},
+			status:   http.StatusNotFound,
+			response: MACHINE_NOT_FOUND_MSG,
+		},
+		{
+			name: "multipart",
+			request: func() *http.Request {
+				body, contentType := multipartBody("machine", dfa.ODDA, "tape", "aaba")
+				req := httptest.NewRequest("POST", "/simulate/stream?maxSteps=2", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			status: http.StatusOK,
+			response: `{
+				"Accepted": false,
+				"Path": ["q0"],
+				"RemainingInput": "ba",
+				"Outcome": "BudgetExhausted",
+				"Steps": 2
+			}`,
+		},
+		{
+			name: "multipart-tape-first",
+			request: func() *http.Request {
+				body, contentType := multipartBody("tape", "aaba", "machine", dfa.ODDA)
+				req := httptest.NewRequest("POST", "/simulate/stream", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			status:   http.StatusBadRequest,
+			response: PLEASE_STREAM_A_TAPE_MSG,
+		},
+		{
+			name: "no-machine",
+			request: func() *http.Request {
+				return httptest.NewRequest("POST", "/simulate/stream",
+					strings.NewReader("aaba"))
+			},
+			status:   http.StatusBadRequest,
+			response: PLEASE_STREAM_A_TAPE_MSG,
+		},
+		{
+			name: "invalid-machine",
+			request: func() *http.Request {
+				body, contentType := multipartBody("machine", "{", "tape", "aaba")
+				req := httptest.NewRequest("POST", "/simulate/stream", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			status: http.StatusUnprocessableEntity,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			router := mux.NewRouter()
+			New(defaultService()).WithMachines(store).Attach(router)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, tc.request())
+			assertStatusCode(t, tc.status, recorder)
+			if tc.response != "" {
+				assertResponse(t, tc.response, recorder.Body.String())
+			}
+		})
+	}
+}
+
 func assertStuff(
 	t *testing.T,
 	tc testCaseDoSimulation,
@@ -266,3 +596,40 @@ func (s *mockSimulatorService) End(simulationId int) error {
 	delete(s.sims, simulationId)
 	return nil
 }
+
+// Machines that only differ in the order of two transitions for the same
+// symbol do different things, so they do not share cached results
+func TestCacheKeepsTransitionOrder(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New(simulatorservice.New()).WithCache(resultcache.New(10, time.Minute)).Attach(router)
+	machine := func(first, second string) string {
+		return `{
+			"Type": "DFA",
+			"Alphabet": "a",
+			"Start": "q0",
+			"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+			"Transitions": [
+				{ "Start": "q0", "End": "` + first + `", "Symbol": "a" },
+				{ "Start": "q0", "End": "` + second + `", "Symbol": "a" },
+				{ "Start": "q1", "End": "q1", "Symbol": "a" }
+			]
+		}`
+	}
+	simulate := func(machine string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, simtest.MustCreateRequest(t, "POST", "/simulate?tape=a",
+			bytes.NewBufferString(machine)))
+		return recorder
+	}
+
+	a := simulate(machine("q1", "q0"))
+	assertStatusCode(t, http.StatusOK, a)
+	assert.Contains(t, a.Body.String(), `"Accepted":true`)
+
+	b := simulate(machine("q0", "q1"))
+	assertStatusCode(t, http.StatusOK, b)
+	assert.Equal(t, "MISS", b.Header().Get("X-Cache"))
+	assert.Contains(t, b.Body.String(), `"Accepted":false`)
+	assert.NotEqual(t, a.Header().Get("ETag"), b.Header().Get("ETag"))
+}
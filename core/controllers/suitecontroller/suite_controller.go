@@ -0,0 +1,179 @@
+package suitecontroller
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/flapflapio/simulator/core/app"
+	"github.com/flapflapio/simulator/core/controllers/utils"
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/mutation"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+	"github.com/obonobo/mux"
+)
+
+const (
+	INVALID_MACHINE_MSG = "" +
+		"The machine that was sent is not " +
+		"valid or otherwise could not be processed"
+
+	INVALID_REQUEST_MSG = `` +
+		`{"Err":"The body must be a JSON object with the machine in 'Machine', ` +
+		`the cases in 'Cases', and optionally the name of the suite in 'Name'"}`
+
+	INVALID_WEIGHT_MSG = `{"Err":"Weights cannot be negative"}`
+
+	UNKNOWN_FORMAT_MSG = `` +
+		`{"Err":"Query param 'format' must be one of 'json', 'junit' or 'tap'"}`
+
+	FAILED_TO_RUN_MSG = `{"Err":"Failed to run the test suite"}`
+
+	INVALID_LIMIT_MSG = `{"Err":"Query param 'limit' must be a positive integer"}`
+
+	// Name of the suite in JUnit reports if the request does not name it
+	defaultName = "machine"
+
+	// Most mutants tested by a request, see `TestMutants`
+	maxMutants = 1000
+)
+
+// Runs machines against test suites: tapes with expected outcomes
+type SuiteController struct {
+	prefix string
+	budget simulation.Budget
+}
+
+// The body of a test run
+type suiteRequest struct {
+	Name    string       `json:"Name"`
+	Machine interface{}  `json:"Machine"`
+	Cases   []suite.Case `json:"Cases"`
+}
+
+func New() *SuiteController {
+	return &SuiteController{prefix: "/"}
+}
+
+func (c *SuiteController) WithPrefix(prefix string) *SuiteController {
+	return &SuiteController{prefix: app.Trim(prefix), budget: c.budget}
+}
+
+// Limits each run of the machine over the tape of a case
+func (c *SuiteController) WithBudget(budget simulation.Budget) *SuiteController {
+	return &SuiteController{prefix: c.prefix, budget: budget}
+}
+
+// Attaches this controller to the given router
+func (c *SuiteController) Attach(router *mux.Router) {
+	r := utils.CreateSubrouter(router, c.prefix)
+	r.Methods("POST").Path("/test").HandlerFunc(c.RunSuite)
+	r.Methods("POST").Path("/mutants").HandlerFunc(c.TestMutants)
+}
+
+// Runs the machine in the body over the tape of every case (see `suite.Run`),
+// and reports the results in the format given by query param 'format': 'json'
+// (the default, or YAML if the client prefers it), 'junit' (JUnit XML) or
+// 'tap'. A failing case does not fail the request, clients should check the
+// report.
+// If successful: 200 + the report.
+// If the machine is invalid: 422 + a list of diagnostics.
+func (c *SuiteController) RunSuite(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := utils.ReportFormat(r); !ok {
+		utils.WriteError(rw, http.StatusBadRequest, UNKNOWN_FORMAT_MSG)
+		return
+	}
+	var req suiteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Machine == nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	if err := suite.Validate(req.Cases); err != nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_WEIGHT_MSG)
+		return
+	}
+	m, err := utils.LoadMachineFrom(r, req.Machine)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+
+	report, err := suite.Run(r.Context(), m, req.Cases, c.budget)
+	if err != nil {
+		log.Printf("Test suite failed to run: %v", err)
+		utils.WriteError(rw, http.StatusInternalServerError, FAILED_TO_RUN_MSG)
+		return
+	}
+	if req.Name == "" {
+		req.Name = defaultName
+	}
+	utils.WriteReport(rw, r, req.Name, report)
+}
+
+// Tests the mutants of the machine in the body against the cases (see
+// `mutation.Tester`), to tell whether the cases would catch likely mistakes.
+// At most 1000 mutants are tested, or fewer with query param 'limit'. The body
+// is the same as for `RunSuite`.
+// If successful: 200 + {"Mutants", "Killed", "Survived", "Equivalent",
+// "Score", "Truncated"}, in JSON or YAML.
+// If the machine is invalid or not a DFA: 422 + a list of diagnostics.
+func (c *SuiteController) TestMutants(rw http.ResponseWriter, r *http.Request) {
+	limit, ok := limitParam(rw, r)
+	if !ok {
+		return
+	}
+	var req suiteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Machine == nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_REQUEST_MSG)
+		return
+	}
+	if err := suite.Validate(req.Cases); err != nil {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_WEIGHT_MSG)
+		return
+	}
+	m, err := utils.LoadMachineFrom(r, req.Machine)
+	if err != nil {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG, err)
+		return
+	}
+	d, ok := m.(*dfa.DFA)
+	if !ok {
+		utils.WriteDiagnostics(rw, http.StatusUnprocessableEntity, INVALID_MACHINE_MSG,
+			machine.Diagnostics{machine.Errorf(machine.Pointer("Machine", "Type"),
+				machine.CodeUnsupportedType, "only DFAs can be mutated")})
+		return
+	}
+
+	report, err := mutation.Tester{
+		Cases:      req.Cases,
+		Budget:     c.budget,
+		MaxMutants: limit,
+	}.Test(r.Context(), d)
+	if err != nil {
+		log.Printf("Mutation testing failed: %v", err)
+		utils.WriteError(rw, http.StatusInternalServerError, FAILED_TO_RUN_MSG)
+		return
+	}
+	utils.WriteDocument(rw, r, http.StatusOK, report)
+}
+
+// Reads query param 'limit', the number of mutants to test. If false is
+// returned, a response has already been written
+func limitParam(rw http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return maxMutants, true
+	}
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit < 1 {
+		utils.WriteError(rw, http.StatusBadRequest, INVALID_LIMIT_MSG)
+		return 0, false
+	}
+	if limit > maxMutants {
+		limit = maxMutants
+	}
+	return limit, true
+}
@@ -0,0 +1,130 @@
+package suitecontroller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/internal/simtest"
+	"github.com/obonobo/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+const cases = `[
+	{ "Name": "odd", "Tape": "a", "Accept": true },
+	{ "Tape": "aa", "Accept": true },
+	{ "Tape": "abbbbbbb", "Accept": true }
+]`
+
+func TestRunSuite(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().WithBudget(simulation.Budget{MaxSteps: 5}).Attach(router)
+	suite := `{"Name": "odd-a", "Machine": ` + dfa.ODDA + `, "Cases": ` + cases + `}`
+
+	res := simtest.ServeRequest(router, "POST", "/test", suite)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var report map[string]interface{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &report))
+	assert.Equal(t, 1.0, report["Passed"])
+	assert.Equal(t, 2.0, report["Failed"])
+	outcomes := []interface{}{}
+	for _, c := range report["Cases"].([]interface{}) {
+		outcomes = append(outcomes, c.(map[string]interface{})["Outcome"])
+	}
+	assert.Equal(t, []interface{}{"Accepted", "Rejected", "BudgetExhausted"}, outcomes)
+	coverage := report["Coverage"].(map[string]interface{})
+	assert.Equal(t, 100.0, coverage["States"].(map[string]interface{})["Percent"])
+	assert.Equal(t, 75.0, coverage["Transitions"].(map[string]interface{})["Percent"])
+
+	res = simtest.ServeRequest(router, "POST", "/test?format=junit", suite)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, "application/xml; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), `<testsuite name="odd-a" tests="3" failures="2">`)
+
+	res = simtest.ServeRequest(router, "POST", "/test?format=tap", `{"Machine": `+dfa.ODDA+`, "Cases": `+cases+`}`)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), "1..3\nok 1 - odd\nnot ok 2 - tape \"aa\"\n")
+	assert.Contains(t, res.Body.String(), "# transitions covered 3/4 (75%)\n")
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown format", "/test?format=html", suite, http.StatusBadRequest},
+		{"no machine", "/test", `{"Cases": []}`, http.StatusBadRequest},
+		{"not json", "/test", `Cases`, http.StatusBadRequest},
+		{"negative weight", "/test",
+			`{"Machine": ` + dfa.ODDA + `, "Cases": [{"Weight": -2}]}`,
+			http.StatusBadRequest},
+		{"invalid machine", "/test", `{"Machine": {"Type": "DFA"}}`,
+			http.StatusUnprocessableEntity},
+	} {
+		res := simtest.ServeRequest(router, "POST", tc.path, tc.body)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+	}
+}
+
+func TestMutants(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().Attach(router)
+	suite := `{"Machine": ` + dfa.ODDA + `, "Cases": [
+		{ "Tape": "a", "Accept": true },
+		{ "Tape": "b", "Accept": false }
+	]}`
+
+	res := simtest.ServeRequest(router, "POST", "/mutants", suite)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var report map[string]interface{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &report))
+	assert.Len(t, report["Mutants"], 7)
+	assert.Equal(t, 7.0,
+		report["Killed"].(float64)+report["Survived"].(float64)+report["Equivalent"].(float64))
+	assert.Nil(t, report["Truncated"])
+
+	res = simtest.ServeRequest(router, "POST", "/mutants?limit=3", suite)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	report = map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &report))
+	assert.Len(t, report["Mutants"], 3)
+	assert.Equal(t, true, report["Truncated"])
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"zero limit", "/mutants?limit=0", suite, http.StatusBadRequest},
+		{"bad limit", "/mutants?limit=many", suite, http.StatusBadRequest},
+		{"no machine", "/mutants", `{"Cases": []}`, http.StatusBadRequest},
+		{"negative weight", "/mutants",
+			`{"Machine": ` + dfa.ODDA + `, "Cases": [{"Weight": -2}]}`,
+			http.StatusBadRequest},
+		{"not a dfa", "/mutants",
+			`{"Machine": {"Type": "NFA", "Start": "q0", "States": [{"Id": "q0"}]}}`,
+			http.StatusUnprocessableEntity},
+	} {
+		res := simtest.ServeRequest(router, "POST", tc.path, tc.body)
+		assert.Equal(t, tc.status, res.Code, tc.name)
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	t.Parallel()
+	router := mux.NewRouter()
+	New().WithPrefix("/api/").Attach(router)
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/test",
+		strings.NewReader(`{"Machine": `+dfa.ODDA+`, "Cases": []}`))
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
@@ -2,9 +2,20 @@ package utils
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/machine"
 	"github.com/obonobo/mux"
+	"gopkg.in/yaml.v3"
 )
 
 func CreateSubrouter(router *mux.Router, prefix string) *mux.Router {
@@ -20,3 +31,224 @@ func Compact(data string) []byte {
 	json.Compact(&buf, []byte(data))
 	return buf.Bytes()
 }
+
+// Reads the machine document in the request body, which is JSON unless the
+// Content-Type of the request is YAML. Documents of older versions are migrated
+// to the current version (see `machine.LoadMap`). If the `complete` query param
+// is true, the document is flagged so that DFAs with missing transitions are
+// completed with a trap state instead of being rejected
+func LoadDocument(r *http.Request) (map[string]interface{}, error) {
+	if !IsYaml(r.Header.Get("Content-Type")) {
+		return LoadDocumentFrom(r, r.Body)
+	}
+	doc, err := machine.LoadYamlMap(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return LoadDocumentFrom(r, doc)
+}
+
+// Reads a machine document from `src` (anything accepted by `machine.LoadMap`)
+// instead of the request body, see `LoadDocument`
+func LoadDocumentFrom(r *http.Request, src interface{}) (map[string]interface{}, error) {
+	doc, err := machine.LoadMap(src)
+	if err != nil {
+		return nil, err
+	}
+	if complete, _ := strconv.ParseBool(r.URL.Query().Get("complete")); complete {
+		doc["Complete"] = true
+	}
+	return doc, nil
+}
+
+// Loads the machine in the request body, see `LoadDocument`
+func LoadMachine(r *http.Request) (simulation.Machine, error) {
+	doc, err := LoadDocument(r)
+	if err != nil {
+		return nil, err
+	}
+	return automata.Load(doc)
+}
+
+// Checks that a document is a valid machine. Machines of types that cannot be
+// simulated yet only need to be well formed graphs
+func CheckDocument(doc map[string]interface{}) error {
+	_, err := automata.Load(doc)
+	diags := machine.DiagnosticsOf(err)
+	if len(diags) == 1 && diags[0].Code == machine.CodeUnsupportedType {
+		_, err = machine.Load(doc)
+	}
+	return err
+}
+
+// Loads the machine in `src` instead of the request body, see
+// `LoadDocumentFrom`
+func LoadMachineFrom(r *http.Request, src interface{}) (simulation.Machine, error) {
+	doc, err := LoadDocumentFrom(r, src)
+	if err != nil {
+		return nil, err
+	}
+	return automata.Load(doc)
+}
+
+// The body of a response describing why a machine could not be loaded
+type DiagnosticsResponse struct {
+	Err    string              `json:"Err,omitempty"`
+	Errors machine.Diagnostics `json:"Errors"`
+}
+
+// Writes a JSON response listing the diagnostics carried by `err`. `msg` is an
+// optional summary placed in the "Err" field
+func WriteDiagnostics(rw http.ResponseWriter, status int, msg string, err error) {
+	data, merr := json.Marshal(DiagnosticsResponse{
+		Err:    msg,
+		Errors: machine.DiagnosticsOf(err),
+	})
+	if merr != nil {
+		panic(merr)
+	}
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+	rw.Write(append(data, '\n'))
+}
+
+// Writes a response with a body that is already JSON, such as the `{"Err"}`
+// messages of the controllers
+func WriteError(rw http.ResponseWriter, status int, msg string) {
+	rw.WriteHeader(status)
+	rw.Write([]byte(msg))
+}
+
+// A random id, for things that are stored without one
+func NewId() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Whether a Content-Type (or a media range of an Accept header) is YAML
+func IsYaml(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// Whether the client prefers YAML to JSON, according to the Accept header of
+// the request. JSON is preferred when both are equally acceptable
+func PrefersYaml(r *http.Request) bool {
+	var json, yaml float64
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case IsYaml(mediaType):
+			yaml = math.Max(yaml, q)
+		case mediaType == "application/json",
+			mediaType == "application/*",
+			mediaType == "*/*":
+			json = math.Max(json, q)
+		}
+	}
+	return yaml > json
+}
+
+// Writes `v` as the body of a response, in YAML if the client prefers it (see
+// `PrefersYaml`) or in JSON otherwise
+func WriteDocument(rw http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	contentType := "application/json; charset=utf-8"
+	data, err := json.Marshal(v)
+	if err == nil && PrefersYaml(r) {
+		contentType = "application/yaml; charset=utf-8"
+		data, err = toYaml(data)
+	}
+	if err != nil {
+		panic(err)
+	}
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", contentType)
+	rw.WriteHeader(status)
+	if data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	rw.Write(data)
+}
+
+// A test report that can also be written as JUnit XML or TAP, see
+// `WriteReport`
+type TestReport interface {
+	JUnit(name string) ([]byte, error)
+	TAP() string
+}
+
+// Content types of the formats that test reports can be written in, besides
+// JSON
+var reportFormats = map[string]string{
+	"junit": "application/xml; charset=utf-8",
+	"tap":   "text/plain; charset=utf-8",
+}
+
+// The format that query param 'format' asks test reports to be written in:
+// 'json' (the default), 'junit' or 'tap'. Returns false for other formats
+func ReportFormat(r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		return "json", true
+	}
+	_, ok := reportFormats[format]
+	return format, ok
+}
+
+// Writes a test report in the format given by `ReportFormat`, which must be
+// valid: JSON (or YAML, see `WriteDocument`), JUnit XML with a test suite
+// called `name`, or TAP
+func WriteReport(rw http.ResponseWriter, r *http.Request, name string, report TestReport) {
+	format, _ := ReportFormat(r)
+	var data []byte
+	switch format {
+	case "junit":
+		var err error
+		if data, err = report.JUnit(name); err != nil {
+			panic(err)
+		}
+	case "tap":
+		data = []byte(report.TAP())
+	default:
+		WriteDocument(rw, r, http.StatusOK, report)
+		return
+	}
+	rw.Header().Del("Content-Type")
+	rw.Header().Add("Content-Type", reportFormats[format])
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(data)
+}
+
+// Converts JSON to YAML. Going through JSON first means that `json` tags and
+// `MarshalJSON` methods are respected
+func toYaml(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	enc.Close()
+	return buf.Bytes(), nil
+}
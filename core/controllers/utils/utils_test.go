@@ -76,3 +76,45 @@ func TestCreateSubrouter(t *testing.T) {
 			`Expected route to be matched (body="good"), but got body "%v"`, b)
 	}
 }
+
+func TestPrefersYaml(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                                           false,
+		"application/json":                           false,
+		"application/yaml":                           true,
+		"text/yaml; charset=utf-8":                   true,
+		"application/json, application/yaml":         false,
+		"application/yaml, */*;q=0.8":                true,
+		"application/json;q=0.5, text/x-yaml":        true,
+		"application/yaml;q=0.2, */*;q=0.5":          false,
+		"application/yaml;q=oops, */*;q=0.5":         false,
+		"application/x-yaml, application/json;q=0.9": true,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		assert.Equal(t, expected, PrefersYaml(req), "Accept: %v", accept)
+	}
+}
+
+func TestWriteDocument(t *testing.T) {
+	doc := map[string]interface{}{"Alphabet": "01", "Symbol": "0", "Steps": 4}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	recorder := httptest.NewRecorder()
+	WriteDocument(recorder, req, http.StatusOK, doc)
+	assert.Equal(t, "application/json; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"Alphabet":"01","Symbol":"0","Steps":4}`, recorder.Body.String())
+
+	req.Header.Set("Accept", "application/yaml")
+	recorder = httptest.NewRecorder()
+	WriteDocument(recorder, req, http.StatusOK, doc)
+	assert.Equal(t, "application/yaml; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Alphabet: \"01\"\nSteps: 4\nSymbol: \"0\"\n", recorder.Body.String())
+}
+
+func TestNewId(t *testing.T) {
+	t.Parallel()
+	a, b := NewId(), NewId()
+	assert.Regexp(t, `^[0-9a-f]{12}$`, a)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,109 @@
+package exercisestore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+// A store that keeps exercises in memory, so they are lost when the server
+// stops
+type MemoryStore struct {
+	exercises map[string]Exercise
+	lock      sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{exercises: map[string]Exercise{}}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, e Exercise) (Exercise, error) {
+	if err := validate(e); err != nil {
+		return Exercise{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.exercises[e.Id]; ok {
+		return Exercise{}, ErrExists
+	}
+	e, err := clone(e)
+	if err != nil {
+		return Exercise{}, err
+	}
+	e.Created = storage.Now()
+	e.Updated = e.Created
+	s.exercises[e.Id] = e
+	return clone(e)
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (Exercise, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	e, ok := s.exercises[id]
+	if !ok {
+		return Exercise{}, ErrNotFound
+	}
+	return clone(e)
+}
+
+func (s *MemoryStore) Update(ctx context.Context, e Exercise) (Exercise, error) {
+	if err := validate(e); err != nil {
+		return Exercise{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	old, ok := s.exercises[e.Id]
+	if !ok {
+		return Exercise{}, ErrNotFound
+	}
+	e, err := clone(e)
+	if err != nil {
+		return Exercise{}, err
+	}
+	e.Created = old.Created
+	e.Updated = storage.Now()
+	s.exercises[e.Id] = e
+	return clone(e)
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.exercises[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.exercises, id)
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Exercise, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	list := make([]Exercise, 0, len(s.exercises))
+	for _, e := range s.exercises {
+		list = append(list, summary(e))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// Deep copies an exercise, so that callers never share it with the store. The
+// exercise goes through JSON, like it does in the SQLite store
+func clone(e Exercise) (Exercise, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Exercise{}, err
+	}
+	var copied Exercise
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return Exercise{}, err
+	}
+	return copied, nil
+}
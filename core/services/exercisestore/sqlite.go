@@ -0,0 +1,166 @@
+package exercisestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+// Exercises are stored as JSON, keyed by their id
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS exercises (
+	id       TEXT PRIMARY KEY,
+	exercise TEXT NOT NULL
+);
+`
+
+// A store that keeps exercises in an SQLite database file
+type SqliteStore struct {
+	db    *sql.DB
+	owned bool // Whether the store opened the database, and closes it
+}
+
+// Opens (and creates if needed) the SQLite database at `path`, for this store
+// alone. Use ":memory:" for a database that only lives as long as the store
+func OpenSqliteStore(path string) (*SqliteStore, error) {
+	db, err := storage.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSqliteStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// A store that keeps its exercises in a database opened with
+// `storage.OpenSqlite`, which other stores may share. The database is left
+// open when the store is closed
+func NewSqliteStore(db *sql.DB) (*SqliteStore, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+	return &SqliteStore{db: db}, nil
+}
+
+func (s *SqliteStore) Create(ctx context.Context, e Exercise) (Exercise, error) {
+	if err := validate(e); err != nil {
+		return Exercise{}, err
+	}
+	e.Created = storage.Now()
+	e.Updated = e.Created
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Exercise{}, err
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO exercises (id, exercise) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+		e.Id, string(data))
+	if err != nil {
+		return Exercise{}, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return Exercise{}, err
+	}
+	if n == 0 {
+		return Exercise{}, ErrExists
+	}
+	return s.Get(ctx, e.Id)
+}
+
+func (s *SqliteStore) Get(ctx context.Context, id string) (Exercise, error) {
+	return scanExercise(s.db.QueryRowContext(ctx,
+		`SELECT exercise FROM exercises WHERE id = ?`, id))
+}
+
+func (s *SqliteStore) Update(ctx context.Context, e Exercise) (Exercise, error) {
+	if err := validate(e); err != nil {
+		return Exercise{}, err
+	}
+	err := storage.Transaction(ctx, s.db, func(tx *sql.Tx) error {
+		old, err := scanExercise(tx.QueryRowContext(ctx,
+			`SELECT exercise FROM exercises WHERE id = ?`, e.Id))
+		if err != nil {
+			return err
+		}
+		e.Created = old.Created
+		e.Updated = storage.Now()
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE exercises SET exercise = ? WHERE id = ?`, string(data), e.Id)
+		return err
+	})
+	if err != nil {
+		return Exercise{}, err
+	}
+	return s.Get(ctx, e.Id)
+}
+
+func (s *SqliteStore) Delete(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM exercises WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SqliteStore) List(ctx context.Context) ([]Exercise, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT exercise FROM exercises ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Exercise{}
+	for rows.Next() {
+		e, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, summary(e))
+	}
+	return list, rows.Err()
+}
+
+func (s *SqliteStore) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExercise(row scanner) (Exercise, error) {
+	var data string
+	err := row.Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Exercise{}, ErrNotFound
+	}
+	if err != nil {
+		return Exercise{}, err
+	}
+	var e Exercise
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return Exercise{}, err
+	}
+	return e, nil
+}
@@ -0,0 +1,82 @@
+// Storage for exercises: a reference language, and the test suite that
+// submitted machines are graded with
+package exercisestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+)
+
+var (
+	ErrNotFound  = errors.New("exercise not found")
+	ErrExists    = errors.New("an exercise with this id already exists")
+	ErrInvalidId = errors.New(
+		"exercise ids must be 1 to 64 letters, digits, '-' or '_'")
+)
+
+// An exercise. `Created` is when the exercise was first stored and `Updated`
+// is when it last changed
+type Exercise struct {
+	Id          string       `json:"Id"`
+	Name        string       `json:"Name"`
+	Description string       `json:"Description,omitempty"`
+	Reference   Reference    `json:"Reference"`
+	Cases       []suite.Case `json:"Cases"`
+
+	// The weight of the equivalence check with the reference when grading,
+	// see `suite.Grader`
+	EquivalenceWeight float64 `json:"EquivalenceWeight,omitempty"`
+
+	Created time.Time `json:"Created"`
+	Updated time.Time `json:"Updated"`
+}
+
+// The language that submissions must accept, given either as a machine or as
+// a regular expression over an alphabet of single characters
+type Reference struct {
+	Machine  map[string]interface{} `json:"Machine,omitempty"`
+	Regex    string                 `json:"Regex,omitempty"`
+	Alphabet string                 `json:"Alphabet,omitempty"`
+}
+
+// A place to keep exercises. Implementations must be safe for concurrent use
+type Store interface {
+	// Stores a new exercise. Fails with `ErrExists` if the id is taken
+	Create(ctx context.Context, e Exercise) (Exercise, error)
+
+	// An exercise, or `ErrNotFound`
+	Get(ctx context.Context, id string) (Exercise, error)
+
+	// Replaces an existing exercise, or fails with `ErrNotFound`
+	Update(ctx context.Context, e Exercise) (Exercise, error)
+
+	// Deletes an exercise, or fails with `ErrNotFound`
+	Delete(ctx context.Context, id string) error
+
+	// Every exercise ordered by id, without their references and cases
+	List(ctx context.Context) ([]Exercise, error)
+
+	Close() error
+}
+
+// Checks that an exercise can be stored
+func validate(e Exercise) error {
+	if !storage.ValidId(e.Id) {
+		return ErrInvalidId
+	}
+	if (e.Reference.Machine == nil) == (e.Reference.Regex == "") {
+		return errors.New("an exercise needs either a reference machine or a regex")
+	}
+	return nil
+}
+
+// Leaves out the reference and the cases, for listings
+func summary(e Exercise) Exercise {
+	e.Reference = Reference{}
+	e.Cases = nil
+	return e
+}
@@ -0,0 +1,118 @@
+package exercisestore
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/services/machinestore"
+	"github.com/flapflapio/simulator/core/services/storage"
+	"github.com/flapflapio/simulator/core/services/storage/storagetest"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStores(t *testing.T) {
+	storagetest.Run(t,
+		func() io.Closer { return NewMemoryStore() },
+		func(db *sql.DB) (io.Closer, error) { return NewSqliteStore(db) },
+		func(t *testing.T, s io.Closer) { testStore(t, s.(Store)) })
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	exercise := Exercise{
+		Id:        "odd-a",
+		Name:      "Odd a's",
+		Reference: Reference{Regex: "b*a(b*ab*a)*b*", Alphabet: "ab"},
+		Cases: []suite.Case{
+			{Tape: "a", Accept: true, Weight: 2},
+			{Tape: "aa", Accept: false},
+		},
+	}
+
+	created, err := s.Create(ctx, exercise)
+	assert.NoError(t, err)
+	assert.False(t, created.Created.IsZero())
+	assert.Equal(t, created.Created, created.Updated)
+	assert.Equal(t, exercise.Cases, created.Cases)
+
+	// The store keeps its own copy of the cases
+	exercise.Cases[0].Tape = "aaa"
+	got, err := s.Get(ctx, "odd-a")
+	assert.NoError(t, err)
+	assert.Equal(t, created, got)
+	assert.Equal(t, "a", got.Cases[0].Tape)
+
+	_, err = s.Create(ctx, exercise)
+	assert.ErrorIs(t, err, ErrExists)
+	for _, id := range []string{"", "has space", "slash/id"} {
+		_, err = s.Create(ctx, Exercise{Id: id, Reference: exercise.Reference})
+		assert.ErrorIs(t, err, ErrInvalidId)
+	}
+	_, err = s.Create(ctx, Exercise{Id: "no-reference"})
+	assert.Error(t, err)
+
+	exercise.Reference = Reference{Machine: map[string]interface{}{"Type": "DFA"}}
+	updated, err := s.Update(ctx, exercise)
+	assert.NoError(t, err)
+	assert.Equal(t, created.Created, updated.Created)
+	assert.Equal(t, "aaa", updated.Cases[0].Tape)
+	assert.Equal(t, "DFA", updated.Reference.Machine["Type"])
+	_, err = s.Update(ctx, Exercise{Id: "missing", Reference: exercise.Reference})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = s.Create(ctx, Exercise{Id: "even-a", Reference: exercise.Reference})
+	assert.NoError(t, err)
+	list, err := s.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "even-a", list[0].Id)
+		assert.Equal(t, "odd-a", list[1].Id)
+		assert.Nil(t, list[1].Cases)
+		assert.Nil(t, list[1].Reference.Machine)
+	}
+
+	assert.NoError(t, s.Delete(ctx, "odd-a"))
+	assert.ErrorIs(t, s.Delete(ctx, "odd-a"), ErrNotFound)
+	_, err = s.Get(ctx, "odd-a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// Exercises and machines can be kept in one database, which outlives the
+// stores that share it
+func TestSqliteStoreSharesDatabase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db, err := storage.OpenSqlite(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exercises, err := NewSqliteStore(db)
+	assert.NoError(t, err)
+	machines, err := machinestore.NewSqliteStore(db)
+	assert.NoError(t, err)
+
+	_, err = exercises.Create(ctx, Exercise{
+		Id:        "odd-a",
+		Reference: Reference{Regex: "b*a(b*ab*a)*b*", Alphabet: "ab"},
+	})
+	assert.NoError(t, err)
+	_, err = machines.Create(ctx, machinestore.Machine{
+		Id:       "odd-a",
+		Document: map[string]interface{}{"Type": "DFA"},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, exercises.Close())
+	_, err = machines.Get(ctx, "odd-a")
+	assert.NoError(t, err)
+	assert.NoError(t, machines.Close())
+	exercises, err = NewSqliteStore(db)
+	assert.NoError(t, err)
+	_, err = exercises.Get(ctx, "odd-a")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,144 @@
+package machinestore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+// A store that keeps machines in memory, so they are lost when the server
+// stops
+type MemoryStore struct {
+	revisions map[string][]Machine
+	lock      sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{revisions: map[string][]Machine{}}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, m Machine) (Machine, error) {
+	if err := validate(m); err != nil {
+		return Machine{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.revisions[m.Id]; ok {
+		return Machine{}, ErrExists
+	}
+	m, err := clone(m)
+	if err != nil {
+		return Machine{}, err
+	}
+	m.Revision = 1
+	m.Created = storage.Now()
+	m.Updated = m.Created
+	s.revisions[m.Id] = []Machine{m}
+	return clone(m)
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (Machine, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	revisions, ok := s.revisions[id]
+	if !ok {
+		return Machine{}, ErrNotFound
+	}
+	return clone(revisions[len(revisions)-1])
+}
+
+func (s *MemoryStore) GetRevision(ctx context.Context, id string, revision int) (Machine, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	revisions := s.revisions[id]
+	if revision < 1 || revision > len(revisions) {
+		return Machine{}, ErrNotFound
+	}
+	return clone(revisions[revision-1])
+}
+
+func (s *MemoryStore) Revisions(ctx context.Context, id string) ([]Machine, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	revisions, ok := s.revisions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	list := make([]Machine, len(revisions))
+	for i, m := range revisions {
+		m.Document = nil
+		m.Tags = append([]string{}, m.Tags...)
+		list[i] = m
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, m Machine) (Machine, error) {
+	if err := validate(m); err != nil {
+		return Machine{}, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	revisions, ok := s.revisions[m.Id]
+	if !ok {
+		return Machine{}, ErrNotFound
+	}
+	m, err := clone(m)
+	if err != nil {
+		return Machine{}, err
+	}
+	m.Revision = len(revisions) + 1
+	m.Created = revisions[0].Created
+	m.Updated = storage.Now()
+	s.revisions[m.Id] = append(revisions, m)
+	return clone(m)
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.revisions[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.revisions, id)
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, tag string) ([]Machine, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	list := []Machine{}
+	for _, revisions := range s.revisions {
+		latest := revisions[len(revisions)-1]
+		if tag != "" && !hasTag(latest, tag) {
+			continue
+		}
+		latest.Document = nil
+		latest.Tags = append([]string{}, latest.Tags...)
+		list = append(list, latest)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	return list, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// Deep copies a machine, so that callers never share documents with the store.
+// The document goes through JSON, like it does in the SQLite store
+func clone(m Machine) (Machine, error) {
+	data, err := json.Marshal(m.Document)
+	if err != nil {
+		return Machine{}, err
+	}
+	m.Document = nil
+	if err := json.Unmarshal(data, &m.Document); err != nil {
+		return Machine{}, err
+	}
+	m.Tags = append([]string{}, m.Tags...)
+	return m, nil
+}
@@ -0,0 +1,248 @@
+package machinestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS machines (
+	id       TEXT PRIMARY KEY,
+	created  TEXT NOT NULL,
+	revision INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS revisions (
+	machine_id  TEXT NOT NULL,
+	revision    INTEGER NOT NULL,
+	name        TEXT NOT NULL,
+	description TEXT NOT NULL,
+	tags        TEXT NOT NULL,
+	document    TEXT NOT NULL,
+	updated     TEXT NOT NULL,
+	PRIMARY KEY (machine_id, revision)
+);
+`
+
+// Selects a revision joined with its machine, in the column order expected by
+// `scanMachine`
+const selectRevision = `
+SELECT m.id, r.name, r.description, r.tags, r.revision, m.created, r.updated, r.document
+FROM machines m JOIN revisions r ON r.machine_id = m.id
+`
+
+// A store that keeps machines in an SQLite database file
+type SqliteStore struct {
+	db    *sql.DB
+	owned bool // Whether the store opened the database, and closes it
+}
+
+// Opens (and creates if needed) the SQLite database at `path`, for this store
+// alone. Use ":memory:" for a database that only lives as long as the store
+func OpenSqliteStore(path string) (*SqliteStore, error) {
+	db, err := storage.OpenSqlite(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewSqliteStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.owned = true
+	return s, nil
+}
+
+// A store that keeps its machines in a database opened with
+// `storage.OpenSqlite`, which other stores may share. The database is left
+// open when the store is closed
+func NewSqliteStore(db *sql.DB) (*SqliteStore, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, err
+	}
+	return &SqliteStore{db: db}, nil
+}
+
+func (s *SqliteStore) Create(ctx context.Context, m Machine) (Machine, error) {
+	if err := validate(m); err != nil {
+		return Machine{}, err
+	}
+	m.Revision = 1
+	m.Created = storage.Now()
+	m.Updated = m.Created
+	err := storage.Transaction(ctx, s.db, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM machines WHERE id = ?`, m.Id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrExists
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO machines (id, created, revision) VALUES (?, ?, 1)`,
+			m.Id, formatTime(m.Created))
+		if err != nil {
+			return err
+		}
+		return insertRevision(ctx, tx, m)
+	})
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.GetRevision(ctx, m.Id, 1)
+}
+
+func (s *SqliteStore) Get(ctx context.Context, id string) (Machine, error) {
+	return scanMachine(s.db.QueryRowContext(ctx,
+		selectRevision+`WHERE m.id = ? AND r.revision = m.revision`, id))
+}
+
+func (s *SqliteStore) GetRevision(ctx context.Context, id string, revision int) (Machine, error) {
+	return scanMachine(s.db.QueryRowContext(ctx,
+		selectRevision+`WHERE m.id = ? AND r.revision = ?`, id, revision))
+}
+
+func (s *SqliteStore) Revisions(ctx context.Context, id string) ([]Machine, error) {
+	list, err := s.query(ctx, selectRevision+`WHERE m.id = ? ORDER BY r.revision`, id)
+	if err == nil && len(list) == 0 {
+		return nil, ErrNotFound
+	}
+	return list, err
+}
+
+func (s *SqliteStore) Update(ctx context.Context, m Machine) (Machine, error) {
+	if err := validate(m); err != nil {
+		return Machine{}, err
+	}
+	m.Updated = storage.Now()
+	err := storage.Transaction(ctx, s.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`SELECT revision + 1 FROM machines WHERE id = ?`, m.Id).Scan(&m.Revision)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE machines SET revision = ? WHERE id = ?`, m.Revision, m.Id)
+		if err != nil {
+			return err
+		}
+		return insertRevision(ctx, tx, m)
+	})
+	if err != nil {
+		return Machine{}, err
+	}
+	return s.GetRevision(ctx, m.Id, m.Revision)
+}
+
+func (s *SqliteStore) Delete(ctx context.Context, id string) error {
+	return storage.Transaction(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM machines WHERE id = ?`, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM revisions WHERE machine_id = ?`, id)
+		return err
+	})
+}
+
+func (s *SqliteStore) List(ctx context.Context, tag string) ([]Machine, error) {
+	return s.query(ctx, selectRevision+`
+		WHERE r.revision = m.revision
+		AND (? = '' OR EXISTS (SELECT 1 FROM json_each(r.tags) WHERE value = ?))
+		ORDER BY m.id`, tag, tag)
+}
+
+// Runs a query of `selectRevision`, returning the machines without their
+// documents
+func (s *SqliteStore) query(ctx context.Context, query string, args ...interface{}) ([]Machine, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Machine{}
+	for rows.Next() {
+		m, err := scanMachine(rows)
+		if err != nil {
+			return nil, err
+		}
+		m.Document = nil
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (s *SqliteStore) Close() error {
+	if !s.owned {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func insertRevision(ctx context.Context, tx *sql.Tx, m Machine) error {
+	tags, err := json.Marshal(append([]string{}, m.Tags...))
+	if err != nil {
+		return err
+	}
+	document, err := json.Marshal(m.Document)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO revisions
+		(machine_id, revision, name, description, tags, document, updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.Id, m.Revision, m.Name, m.Description,
+		string(tags), string(document), formatTime(m.Updated))
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMachine(row scanner) (Machine, error) {
+	var m Machine
+	var tags, created, updated, document string
+	err := row.Scan(&m.Id, &m.Name, &m.Description, &tags,
+		&m.Revision, &created, &updated, &document)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Machine{}, ErrNotFound
+	}
+	if err != nil {
+		return Machine{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &m.Tags); err != nil {
+		return Machine{}, err
+	}
+	if err := json.Unmarshal([]byte(document), &m.Document); err != nil {
+		return Machine{}, err
+	}
+	if m.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return Machine{}, err
+	}
+	if m.Updated, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+		return Machine{}, err
+	}
+	return m, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
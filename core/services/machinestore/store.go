@@ -0,0 +1,81 @@
+// Storage for a library of named machines. Every change to a machine is kept
+// as a new revision, numbered from 1
+package machinestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+var (
+	ErrNotFound  = errors.New("machine not found")
+	ErrExists    = errors.New("a machine with this id already exists")
+	ErrInvalidId = errors.New(
+		"machine ids must be 1 to 64 letters, digits, '-' or '_'")
+)
+
+// A stored machine, as of one of its revisions. `Created` is when the machine
+// was first stored and `Updated` is when this revision was stored
+type Machine struct {
+	Id          string                 `json:"Id"`
+	Name        string                 `json:"Name"`
+	Description string                 `json:"Description,omitempty"`
+	Tags        []string               `json:"Tags"`
+	Revision    int                    `json:"Revision"`
+	Created     time.Time              `json:"Created"`
+	Updated     time.Time              `json:"Updated"`
+	Document    map[string]interface{} `json:"Machine,omitempty"`
+}
+
+// A place to keep machines. Implementations must be safe for concurrent use
+type Store interface {
+	// Stores a new machine as revision 1. Fails with `ErrExists` if the id is
+	// taken
+	Create(ctx context.Context, m Machine) (Machine, error)
+
+	// The latest revision of a machine, or `ErrNotFound`
+	Get(ctx context.Context, id string) (Machine, error)
+
+	// A specific revision of a machine, or `ErrNotFound`
+	GetRevision(ctx context.Context, id string, revision int) (Machine, error)
+
+	// Every revision of a machine, oldest first and without their documents,
+	// or `ErrNotFound`
+	Revisions(ctx context.Context, id string) ([]Machine, error)
+
+	// Stores a new revision of an existing machine, or fails with
+	// `ErrNotFound`
+	Update(ctx context.Context, m Machine) (Machine, error)
+
+	// Deletes a machine and all of its revisions, or fails with `ErrNotFound`
+	Delete(ctx context.Context, id string) error
+
+	// The latest revision of every machine, ordered by id and without their
+	// documents. If `tag` is not empty, only machines with that tag are listed
+	List(ctx context.Context, tag string) ([]Machine, error)
+
+	Close() error
+}
+
+// Checks that a machine can be stored
+func validate(m Machine) error {
+	if !storage.ValidId(m.Id) {
+		return ErrInvalidId
+	}
+	if m.Document == nil {
+		return errors.New("machine has no document")
+	}
+	return nil
+}
+
+func hasTag(m Machine, tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
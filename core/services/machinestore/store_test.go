@@ -0,0 +1,156 @@
+package machinestore
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/services/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStores(t *testing.T) {
+	storagetest.Run(t,
+		func() io.Closer { return NewMemoryStore() },
+		func(db *sql.DB) (io.Closer, error) { return NewSqliteStore(db) },
+		func(t *testing.T, s io.Closer) { testStore(t, s.(Store)) })
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	doc := map[string]interface{}{"Type": "DFA", "Start": "q0", "Steps": 2.0}
+
+	created, err := s.Create(ctx, Machine{
+		Id:       "odd-a",
+		Name:     "Odd a's",
+		Tags:     []string{"regular", "week-1"},
+		Document: doc,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created.Revision)
+	assert.False(t, created.Created.IsZero())
+	assert.Equal(t, created.Created, created.Updated)
+	assert.Equal(t, doc, created.Document)
+
+	// The store keeps its own copy of the document
+	doc["Start"] = "q1"
+	got, err := s.Get(ctx, "odd-a")
+	assert.NoError(t, err)
+	assert.Equal(t, created, got)
+	assert.Equal(t, "q0", got.Document["Start"])
+
+	_, err = s.Create(ctx, Machine{Id: "odd-a", Document: doc})
+	assert.ErrorIs(t, err, ErrExists)
+	for _, id := range []string{"", "has space", "slash/id"} {
+		_, err = s.Create(ctx, Machine{Id: id, Document: doc})
+		assert.ErrorIs(t, err, ErrInvalidId, id)
+	}
+
+	updated, err := s.Update(ctx, Machine{
+		Id:          "odd-a",
+		Name:        "Odd number of a's",
+		Description: "Reference solution",
+		Tags:        []string{"regular"},
+		Document:    doc,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+	assert.Equal(t, created.Created, updated.Created)
+	assert.False(t, updated.Updated.Before(created.Updated))
+
+	got, err = s.Get(ctx, "odd-a")
+	assert.NoError(t, err)
+	assert.Equal(t, updated, got)
+	first, err := s.GetRevision(ctx, "odd-a", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, created, first)
+	for _, revision := range []int{0, 3} {
+		_, err = s.GetRevision(ctx, "odd-a", revision)
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	revisions, err := s.Revisions(ctx, "odd-a")
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, []int{1, 2}, []int{revisions[0].Revision, revisions[1].Revision})
+		assert.Equal(t, created.Name, revisions[0].Name)
+		assert.Nil(t, revisions[1].Document)
+	}
+	_, err = s.Revisions(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = s.Update(ctx, Machine{Id: "missing", Document: doc})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = s.Create(ctx, Machine{Id: "anbn", Name: "a^n b^n", Tags: []string{"context-free", "week-1"}, Document: doc})
+	assert.NoError(t, err)
+
+	list, err := s.List(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "anbn", list[0].Id)
+	assert.Equal(t, 2, list[1].Revision)
+	assert.Nil(t, list[1].Document)
+	for tag, ids := range map[string][]string{
+		"week-1":  {"anbn"},
+		"regular": {"odd-a"},
+		"unknown": {},
+	} {
+		list, err := s.List(ctx, tag)
+		assert.NoError(t, err)
+		listed := []string{}
+		for _, m := range list {
+			listed = append(listed, m.Id)
+		}
+		assert.Equal(t, ids, listed, tag)
+	}
+
+	assert.NoError(t, s.Delete(ctx, "odd-a"))
+	assert.ErrorIs(t, s.Delete(ctx, "odd-a"), ErrNotFound)
+	_, err = s.GetRevision(ctx, "odd-a", 1)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// A deleted id can be used again, starting over at revision 1
+	recreated, err := s.Create(ctx, Machine{Id: "odd-a", Document: doc})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, recreated.Revision)
+
+	// Concurrent updates each get a revision of their own
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Update(ctx, Machine{Id: "anbn", Document: doc})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	got, err = s.Get(ctx, "anbn")
+	assert.NoError(t, err)
+	assert.Equal(t, 11, got.Revision)
+}
+
+// Machines outlive the SQLite store that stored them
+func TestSqliteStoreIsPersistent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machines.db")
+	s, err := OpenSqliteStore(path)
+	assert.NoError(t, err)
+	created, err := s.Create(context.Background(), Machine{
+		Id:       "m",
+		Document: map[string]interface{}{"Type": "DFA"},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	s, err = OpenSqliteStore(path)
+	assert.NoError(t, err)
+	defer s.Close()
+	got, err := s.Get(context.Background(), "m")
+	assert.NoError(t, err)
+	assert.Equal(t, created, got)
+}
@@ -0,0 +1,96 @@
+package resultcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/flapflapio/simulator/core/simulation"
+)
+
+// A least recently used cache of simulation results. Entries expire after a
+// time to live, and the least recently used entry is evicted when the cache is
+// full. Safe for concurrent use
+type ResultCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+	lock    sync.Mutex
+
+	// The clock, replaced in tests
+	now func() time.Time
+}
+
+type entry struct {
+	key     string
+	result  simulation.Result
+	expires time.Time
+}
+
+// Creates a cache of at most `size` results, each kept for at most `ttl` (0
+// means forever)
+func New(size int, ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// The result stored under `key`, if it has not expired
+func (c *ResultCache) Get(key string) (simulation.Result, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return simulation.Result{}, false
+	}
+	e := el.Value.(*entry)
+	if c.ttl > 0 && !c.now().Before(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return simulation.Result{}, false
+	}
+	c.order.MoveToFront(el)
+	return copyResult(e.result), true
+}
+
+// Stores a result under `key`, evicting the least recently used result if the
+// cache is full
+func (c *ResultCache) Put(key string, result simulation.Result) {
+	if c.size <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	e := &entry{key: key, result: copyResult(result), expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+	}
+}
+
+// The number of results in the cache, including expired results that have
+// not been evicted yet
+func (c *ResultCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+// Results are copied in and out of the cache, so that callers never share
+// paths with it
+func copyResult(r simulation.Result) simulation.Result {
+	r.Path = append([]string(nil), r.Path...)
+	return r
+}
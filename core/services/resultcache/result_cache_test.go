@@ -0,0 +1,55 @@
+package resultcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultCache(t *testing.T) {
+	t.Parallel()
+	clock := time.Unix(0, 0)
+	c := New(2, time.Minute)
+	c.now = func() time.Time { return clock }
+	result := func(state string) simulation.Result {
+		return simulation.Result{Accepted: true, Path: []string{state}}
+	}
+
+	c.Put("a", result("a"))
+	c.Put("b", result("b"))
+	got, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, result("a"), got)
+
+	// "b" is the least recently used, so it is evicted
+	c.Put("c", result("c"))
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	// Results cannot be modified through the cache
+	got.Path[0] = "modified"
+	got, _ = c.Get("a")
+	assert.Equal(t, result("a"), got)
+
+	// Replacing a result restarts its time to live
+	clock = clock.Add(50 * time.Second)
+	c.Put("a", result("a2"))
+	clock = clock.Add(20 * time.Second)
+	_, ok = c.Get("c")
+	assert.False(t, ok)
+	got, ok = c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, result("a2"), got)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestResultCacheDisabled(t *testing.T) {
+	t.Parallel()
+	c := New(0, 0)
+	c.Put("a", simulation.Result{})
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,59 @@
+// What the stores of the services have in common: the ids and timestamps of
+// what they keep, and the SQLite database that they may share
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	// Pure Go SQLite driver, so that the server can still be built statically
+	_ "modernc.org/sqlite"
+)
+
+var validId = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Whether `id` can be stored: 1 to 64 letters, digits, '-' or '_'
+func ValidId(id string) bool {
+	return validId.MatchString(id)
+}
+
+// Timestamps are stored with millisecond precision, in UTC
+func Now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// Opens (and creates if needed) the SQLite database at `path`, to be shared by
+// every store that keeps its data in it. Use ":memory:" for a database that
+// only lives as long as it is open
+func OpenSqlite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time, and every connection to ":memory:"
+	// would be a database of its own. Writes wait for other processes using
+	// the same file instead of failing right away
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`PRAGMA busy_timeout = 5000`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Runs `f` in a transaction, which is rolled back if `f` fails
+func Transaction(ctx context.Context, db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
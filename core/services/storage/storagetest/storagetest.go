@@ -0,0 +1,45 @@
+// Runs the tests of a store against each of its implementations
+package storagetest
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/services/storage"
+)
+
+// Runs `test` in parallel against a store kept in memory, and against a store
+// kept in an SQLite database file
+func Run(
+	t *testing.T,
+	memory func() io.Closer,
+	sqlite func(db *sql.DB) (io.Closer, error),
+	test func(t *testing.T, store io.Closer),
+) {
+	stores := map[string]func(t *testing.T) io.Closer{
+		"memory": func(t *testing.T) io.Closer { return memory() },
+		"sqlite": func(t *testing.T) io.Closer {
+			db, err := storage.OpenSqlite(filepath.Join(t.TempDir(), "store.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			s, err := sqlite(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+	for name, open := range stores {
+		open := open
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			s := open(t)
+			defer s.Close()
+			test(t, s)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package dfa
+
+import (
+	"fmt"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+// Makes the DFA complete by routing every missing (state, symbol) pair to a
+// generated, non-ending trap state. The trap state loops back to itself on
+// every symbol. States with an "otherwise" transition are already complete.
+// Returns the transitions that were added (none if the DFA was already
+// complete, in which case no trap state is added either)
+func (d *DFA) Complete() []machine.Transition {
+	type pair struct{ state, symbol int }
+	t := compile(d)
+
+	var missing []pair
+	for state := range d.States {
+		if t.otherwise[state] != noTransition {
+			continue
+		}
+		for symbol := range d.Alphabet {
+			if t.lookup(int32(state), int32(symbol)) == noTransition {
+				missing = append(missing, pair{state, symbol})
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	trap := d.AddState(machine.State{Id: d.unusedStateId(), Ending: false})
+	d.Trap = trap.Id
+	for symbol := range d.Alphabet {
+		missing = append(missing, pair{len(d.States) - 1, symbol})
+	}
+
+	added := make([]machine.Transition, 0, len(missing))
+	for _, p := range missing {
+		added = append(added, machine.Transition{
+			Start:  &d.States[p.state],
+			End:    trap,
+			Symbol: d.Alphabet[p.symbol],
+		})
+	}
+	d.WithTransitions(added...)
+	d.compiled = nil
+	return added
+}
+
+// Picks the first id of the form "q<n>" that is not taken, starting from the
+// number of states in the machine
+func (d *DFA) unusedStateId() string {
+	for n := len(d.States); ; n++ {
+		if id := fmt.Sprintf("q%v", n); d.FindState(id) == nil {
+			return id
+		}
+	}
+}
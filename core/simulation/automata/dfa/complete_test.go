@@ -0,0 +1,72 @@
+package dfa
+
+import (
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+// Accepts strings of the form a*b, but only says so for the happy path
+const partialAStarB = `
+{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [
+	  { "Id": "q0", "Ending": false },
+	  { "Id": "q1", "Ending": true }
+	],
+	"Transitions": [
+	  { "Start": "q0", "End": "q0", "Symbol": "a" },
+	  { "Start": "q0", "End": "q1", "Symbol": "b" }
+	]
+}
+`
+
+func TestLoadPartialDFAFails(t *testing.T) {
+	_, err := Load([]byte(partialAStarB))
+	diags := machine.DiagnosticsOf(err)
+	assert.Len(t, diags, 2)
+	for _, d := range diags {
+		assert.Equal(t, machine.CodeMissingTransition, d.Code)
+		assert.Equal(t, "/States/1", d.Pointer)
+	}
+}
+
+func TestLoadPartialDFAWithComplete(t *testing.T) {
+	doc, err := machine.LoadMap([]byte(partialAStarB))
+	assert.NoError(t, err)
+	doc["Complete"] = true
+
+	m, err := Load(doc)
+	assert.NoError(t, err, machineShouldBuildOkay)
+	assert.Equal(t, "q2", m.Trap)
+	assert.Len(t, m.States, 3)
+	assert.Equal(t, machine.State{Id: "q2", Ending: false}, m.States[2])
+	assert.Len(t, m.Transitions, 6)
+	for _, tt := range m.Transitions[2:] {
+		assert.Equal(t, "q2", tt.End.Id)
+		assert.Same(t, &m.States[2], tt.End)
+	}
+	assert.Same(t, &m.States[0], m.Start)
+
+	for tape, accepted := range map[string]bool{
+		"b":    true,
+		"aaab": true,
+		"ba":   false,
+		"bb":   false,
+		"abab": false,
+	} {
+		res := simulation.ResultOf(m.Simulate(tape))
+		assert.Equal(t, accepted, res.Accepted, tape)
+	}
+}
+
+func TestCompleteIsANoOpForCompleteDFAs(t *testing.T) {
+	m := createMachine(t, ODDA).(*DFA)
+	assert.Empty(t, m.Complete())
+	assert.Equal(t, "", m.Trap)
+	assert.Len(t, m.States, 2)
+}
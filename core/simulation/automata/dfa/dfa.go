@@ -10,28 +10,80 @@ import (
 
 type DFA struct {
 	*machine.Graph
-	Alphabet string
+	Alphabet machine.Alphabet
+
+	// If set, symbols on the input tape are separated by this string instead
+	// of being split by longest match against the alphabet
+	Separator string
+
+	// Id of the trap state generated by `Complete`, if any
+	Trap string
+
+	// Transition table used by simulations, see `Compile`
+	compiled *table
 }
 
 type DFAParams struct {
 	machine.GraphParams
-	Alphabet string
+	Alphabet  machine.Alphabet
+	Separator string
 }
 
 func From(params DFAParams) *DFA {
 	return &DFA{
-		Alphabet: params.Alphabet,
-		Graph:    machine.From(params.GraphParams),
+		Alphabet:  params.Alphabet,
+		Separator: params.Separator,
+		Graph:     machine.From(params.GraphParams),
 	}
 }
 
+// Compiles the transitions of the DFA into a table indexed by state and
+// symbol, which simulations use instead of searching the transitions. Machines
+// are compiled when they are loaded. If the DFA is modified afterwards, it must
+// be compiled again (simulations of an uncompiled DFA compile it on the fly)
+func (d *DFA) Compile() {
+	d.compiled = compile(d)
+}
+
 func (d *DFA) Simulate(input string) simulation.Simulation {
+	return d.simulate(input)
+}
+
+// Simulates the DFA over `input`, recording the transitions that it takes (see
+// `simulation.TransitionTracer`)
+func (d *DFA) SimulateTraced(input string) simulation.TransitionTracer {
+	sim := d.simulate(input)
+	sim.taken = make([]bool, len(sim.table.ends))
+	return sim
+}
+
+// The index of the transition that `state` (a state of the DFA) takes on a
+// symbol of the alphabet, leaving out "otherwise" transitions. Returns false if
+// there is no such transition. Uncompiled DFAs are compiled on every call
+func (d *DFA) TransitionOn(state *machine.State, symbol string) (int, bool) {
+	t := d.table()
+	s, ok := t.states[state]
+	index, known := t.symbols[symbol]
+	if !ok || !known {
+		return 0, false
+	}
+	i := t.lookup(s, index)
+	return int(i), i != noTransition
+}
+
+func (d *DFA) simulate(input string) *DFASimulation {
+	t := d.compiled
+	if t == nil {
+		t = compile(d)
+	}
 	return &DFASimulation{
-		machine:      d,
-		currentState: d.Start,
-		input:        input,
-		path:         []string{},
-		rejected:     false,
+		machine:   d,
+		table:     t,
+		state:     t.states[d.Start],
+		input:     input,
+		path:      []string{},
+		rejected:  false,
+		tokenizer: machine.NewTokenizer(d.Alphabet, d.Separator),
 	}
 }
 
@@ -55,7 +107,14 @@ func (d *DFA) Json() string {
 
 func (d *DFA) JsonMap() map[string]interface{} {
 	g := d.Graph.JsonMap()
+	g["SchemaVersion"] = float64(machine.SchemaVersion)
 	g["Type"] = machine.DFA
-	g["Alphabet"] = d.Alphabet
+	g["Alphabet"] = d.Alphabet.JsonValue()
+	if d.Separator != "" {
+		g["Separator"] = d.Separator
+	}
+	if d.Trap != "" {
+		g["Trap"] = d.Trap
+	}
 	return g
 }
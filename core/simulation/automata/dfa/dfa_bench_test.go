@@ -0,0 +1,141 @@
+package dfa
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+)
+
+// Number of bytes on the tapes of the simulation benchmarks
+const benchmarkTapeSize = 1 << 20
+
+// Builds a DFA document with `states` states that reads numbers written with
+// the given digits (most significant first) and accepts the ones divisible by
+// `states`. Digits are separated by `separator`, if any
+func modNMachine(states int, digits []string, separator string) []byte {
+	doc := map[string]interface{}{
+		"Type":     "DFA",
+		"Alphabet": digits,
+		"Start":    "q0",
+	}
+	if separator != "" {
+		doc["Separator"] = separator
+	}
+	var ss, ts []map[string]interface{}
+	for i := 0; i < states; i++ {
+		id := fmt.Sprintf("q%v", i)
+		ss = append(ss, map[string]interface{}{"Id": id, "Ending": i == 0})
+		for d, digit := range digits {
+			ts = append(ts, map[string]interface{}{
+				"Start":  id,
+				"End":    fmt.Sprintf("q%v", (i*len(digits)+d)%states),
+				"Symbol": digit,
+			})
+		}
+	}
+	doc["States"], doc["Transitions"] = ss, ts
+	data, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// A random tape of roughly `size` bytes
+func randomTape(digits []string, separator string, size int) string {
+	r := rand.New(rand.NewSource(1))
+	var sb strings.Builder
+	for sb.Len() < size {
+		if sb.Len() > 0 {
+			sb.WriteString(separator)
+		}
+		sb.WriteString(digits[r.Intn(len(digits))])
+	}
+	return sb.String()
+}
+
+// Alphabet of `n` single-rune symbols, starting at 'a' and running into the
+// non-ASCII characters for large `n`
+func runeDigits(n int) []string {
+	digits := make([]string, n)
+	for i := range digits {
+		digits[i] = string(rune('a' + i))
+	}
+	return digits
+}
+
+func BenchmarkSimulate(b *testing.B) {
+	for _, bc := range []struct {
+		name      string
+		states    int
+		digits    []string
+		separator string
+	}{
+		{name: "binary/states=16", states: 16, digits: runeDigits(2)},
+		{name: "binary/states=5000", states: 5000, digits: runeDigits(2)},
+		{name: "ascii/states=2000", states: 2000, digits: runeDigits(26)},
+		{name: "unicode-sparse/states=1000", states: 1000, digits: runeDigits(300)},
+		{
+			name:   "multi-character/states=1000",
+			states: 1000,
+			digits: []string{"zero", "one", "two", "three"},
+		},
+		{
+			name:      "separator/states=1000",
+			states:    1000,
+			digits:    []string{"0", "1", "2"},
+			separator: " ",
+		},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			m, err := Load(modNMachine(bc.states, bc.digits, bc.separator))
+			if err != nil {
+				b.Fatal(err)
+			}
+			tape := randomTape(bc.digits, bc.separator, benchmarkTapeSize)
+			b.SetBytes(int64(len(tape)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				res := simulation.ResultOf(m.Simulate(tape))
+				if res.RemainingInput != "" {
+					b.Fatalf("simulation stopped early: %v", res.RemainingInput[:10])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSimulateStream(b *testing.B) {
+	m, err := Load(modNMachine(5000, runeDigits(2), ""))
+	if err != nil {
+		b.Fatal(err)
+	}
+	tape := randomTape(runeDigits(2), "", 16*benchmarkTapeSize)
+	b.SetBytes(int64(len(tape)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res := simulation.ResultOf(m.SimulateStream(strings.NewReader(tape)))
+		if res.Steps != len(tape) {
+			b.Fatalf("expected %v steps but got %v", len(tape), res.Steps)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for _, states := range []int{100, 1000, 5000} {
+		doc := modNMachine(states, runeDigits(10), "")
+		b.Run(fmt.Sprintf("states=%v", states), func(b *testing.B) {
+			b.SetBytes(int64(len(doc)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Load(doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -7,11 +7,14 @@ import (
 )
 
 type DFASimulation struct {
-	machine      *DFA
-	currentState *machine.State
-	input        string
-	path         []string
-	rejected     bool
+	machine   *DFA
+	table     *table
+	state     int32
+	input     string
+	path      []string
+	taken     []bool // Indexed by transition, nil unless the simulation is traced
+	rejected  bool
+	tokenizer *machine.Tokenizer
 }
 
 // Perform a transition
@@ -59,43 +62,61 @@ func (dfa *DFASimulation) takeNextTransition() {
 	if dfa.rejected {
 		return
 	}
-	next, err := dfa.nextTransition()
-	if err != nil {
+	symbol, width := dfa.nextSymbol()
+	next := dfa.table.next(dfa.state, symbol)
+	if next == noTransition {
 		dfa.rejected = true
 		return
 	}
-	dfa.takeTransition(next)
+	dfa.takeTransition(next, width)
 }
 
-func (dfa *DFASimulation) takeTransition(t machine.Transition) {
-	dfa.currentState = t.End
-	dfa.input = dfa.input[1:]
+// Reads the next symbol from the input. Returns its index in the alphabet (or
+// `noTransition` if the input does not start with a symbol of the alphabet)
+// and the number of bytes that it occupies
+func (dfa *DFASimulation) nextSymbol() (int32, int) {
+	if dfa.table.runes {
+		return dfa.table.nextRune(dfa.input)
+	}
+	symbol, width, ok := dfa.tokenizer.Next(dfa.input)
+	if !ok {
+		return noTransition, width
+	}
+	return dfa.table.symbols[symbol], width
+}
+
+// Moves to the end of transition `t`, consuming `width` bytes of input
+func (dfa *DFASimulation) takeTransition(t int32, width int) {
+	dfa.state = dfa.table.ends[t]
+	dfa.input = dfa.input[width:]
+	if dfa.taken != nil {
+		dfa.taken[t] = true
+	}
 }
 
-func (dfa *DFASimulation) nextTransition() (machine.Transition, error) {
-	for _, t := range dfa.machine.Transitions {
-		if dfa.shouldTakeTransition(t) {
-			return t, nil
+// The index of every transition taken so far, in increasing order. Empty
+// unless the simulation was created by `DFA.SimulateTraced`
+func (dfa *DFASimulation) Transitions() []int {
+	transitions := []int{}
+	for i, taken := range dfa.taken {
+		if taken {
+			transitions = append(transitions, i)
 		}
 	}
-	return machine.Transition{}, errors.ErrNoTransition
+	return transitions
 }
 
-func (dfa *DFASimulation) shouldTakeTransition(t machine.Transition) bool {
-	return !dfa.rejected &&
-		len(dfa.input) > 0 &&
-		len(t.Symbol) > 0 &&
-		dfa.currentState == t.Start &&
-		dfa.input[0] == t.Symbol[0]
+func (dfa *DFASimulation) currentState() *machine.State {
+	return &dfa.machine.States[dfa.state]
 }
 
 func (dfa *DFASimulation) isAccepted() bool {
 	return !dfa.rejected &&
 		len(dfa.input) == 0 &&
-		dfa.currentState.Ending
+		dfa.currentState().Ending
 }
 
 // Appends the current state of the DFA onto the path
 func (dfa *DFASimulation) logState() {
-	dfa.path = append(dfa.path, dfa.currentState.Id)
+	dfa.path = append(dfa.path, dfa.currentState().Id)
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -143,6 +144,274 @@ func TestMachineInvalidAlphabet(t *testing.T) {
 	}
 }
 
+// State ids are free-form, and the path is reported in the ids that were given
+func TestPathUsesStateIds(t *testing.T) {
+	m := createMachine(t, `
+	{
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Start": "even",
+		"States": [
+		  { "Id": "even", "Ending": false, "Label": "Even" },
+		  { "Id": "odd", "Ending": true, "Label": "Odd" }
+		],
+		"Transitions": [
+		  { "Start": "even", "End": "odd", "Symbol": "a" },
+		  { "Start": "even", "End": "even", "Symbol": "b" },
+		  { "Start": "odd", "End": "odd", "Symbol": "b" },
+		  { "Start": "odd", "End": "even", "Symbol": "a" }
+		]
+	}`)
+	res := simulation.ResultOf(m.Simulate("aba"))
+	assert.False(t, res.Accepted)
+	assert.Equal(t, []string{"even", "odd", "odd", "even"}, res.Path)
+}
+
+func TestSimulationsTraceTransitions(t *testing.T) {
+	m := createMachine(t, ODDA).(simulation.TracingMachine)
+	sim := m.SimulateTraced("abaa")
+	simulation.RunToCompletion(sim)
+	taken := sim.Transitions()
+	assert.Equal(t, []int{0, 2, 3}, taken)
+	taken[0] = 1
+	assert.Equal(t, []int{0, 2, 3}, sim.Transitions())
+
+	// Plain simulations do not trace
+	plain := m.Simulate("abaa")
+	simulation.RunToCompletion(plain)
+	assert.Empty(t, plain.(simulation.TransitionTracer).Transitions())
+}
+
+// Machines whose symbols are longer than a single byte
+func TestMultiCharacterSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		machine string
+		tapes   map[string]bool
+	}{
+		{
+			// Accepts strings containing at least one "10" token
+			name: "longest-match",
+			machine: `
+			{
+				"Type": "DFA",
+				"Alphabet": ["0", "1", "10"],
+				"Start": "q0",
+				"States": [
+				  { "Id": "q0", "Ending": false },
+				  { "Id": "q1", "Ending": true }
+				],
+				"Transitions": [
+				  { "Start": "q0", "End": "q0", "Symbol": "0" },
+				  { "Start": "q0", "End": "q0", "Symbol": "1" },
+				  { "Start": "q0", "End": "q1", "Symbol": "10" },
+				  { "Start": "q1", "End": "q1", "Symbol": "0" },
+				  { "Start": "q1", "End": "q1", "Symbol": "1" },
+				  { "Start": "q1", "End": "q1", "Symbol": "10" }
+				]
+			}`,
+			tapes: map[string]bool{"10": true, "0001": false, "1101": true, "11": false},
+		},
+		{
+			// Accepts an odd number of é's
+			name: "unicode",
+			machine: `
+			{
+				"Type": "DFA",
+				"Alphabet": "éa",
+				"Start": "q0",
+				"States": [
+				  { "Id": "q0", "Ending": false },
+				  { "Id": "q1", "Ending": true }
+				],
+				"Transitions": [
+				  { "Start": "q0", "End": "q1", "Symbol": "é" },
+				  { "Start": "q0", "End": "q0", "Symbol": "a" },
+				  { "Start": "q1", "End": "q0", "Symbol": "é" },
+				  { "Start": "q1", "End": "q1", "Symbol": "a" }
+				]
+			}`,
+			tapes: map[string]bool{"é": true, "aéaéé": true, "éé": false, "e": false},
+		},
+		{
+			// Accepts "if" followed by anything ending in "else"
+			name: "separator",
+			machine: `
+			{
+				"Type": "DFA",
+				"Alphabet": ["if", "else"],
+				"Separator": " ",
+				"Start": "q0",
+				"States": [
+				  { "Id": "q0", "Ending": false },
+				  { "Id": "q1", "Ending": false },
+				  { "Id": "q2", "Ending": true },
+				  { "Id": "trap", "Ending": false }
+				],
+				"Transitions": [
+				  { "Start": "q0", "End": "q1", "Symbol": "if" },
+				  { "Start": "q0", "End": "trap", "Symbol": "else" },
+				  { "Start": "q1", "End": "q1", "Symbol": "if" },
+				  { "Start": "q1", "End": "q2", "Symbol": "else" },
+				  { "Start": "q2", "End": "q1", "Symbol": "if" },
+				  { "Start": "q2", "End": "q2", "Symbol": "else" },
+				  { "Start": "trap", "End": "trap", "Symbol": "if" },
+				  { "Start": "trap", "End": "trap", "Symbol": "else" }
+				]
+			}`,
+			tapes: map[string]bool{
+				"if else":    true,
+				"if if else": true,
+				"else":       false,
+				"if elsee":   false,
+				"ifelse":     false,
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := createMachine(t, tc.machine)
+			for tape, accepted := range tc.tapes {
+				res := simulation.ResultOf(m.Simulate(tape))
+				assert.Equal(t, accepted, res.Accepted, "tape: %v", tape)
+			}
+		})
+	}
+}
+
+// A single byte of a multi-byte character should not match a transition
+func TestUnicodeIsNotMatchedBytewise(t *testing.T) {
+	m := createMachine(t, `
+	{
+		"Type": "DFA",
+		"Alphabet": "é",
+		"Start": "q0",
+		"States": [{ "Id": "q0", "Ending": true }],
+		"Transitions": [{ "Start": "q0", "End": "q0", "Symbol": "é" }]
+	}`)
+	res := simulation.ResultOf(m.Simulate("éè"))
+	assert.False(t, res.Accepted)
+	assert.Equal(t, "è", res.RemainingInput)
+}
+
+// Accepts identifiers: a letter or underscore followed by letters, digits and
+// underscores. Any other character goes to the trap state
+const identifierMachine = `
+{
+	"Type": "DFA",
+	"Start": "start",
+	"States": [
+	  { "Id": "start" },
+	  { "Id": "ident", "Ending": true },
+	  { "Id": "trap" }
+	],
+	"Transitions": [
+	  { "Start": "start", "End": "ident", "Symbol": "[a-z_]" },
+	  { "Start": "start", "End": "trap", "Otherwise": true },
+	  { "Start": "ident", "End": "ident", "Symbol": "[a-z0-9_]" },
+	  { "Start": "ident", "End": "trap", "Otherwise": true },
+	  { "Start": "trap", "End": "trap", "Otherwise": true }
+	]
+}`
+
+func TestSymbolClassesAndOtherwise(t *testing.T) {
+	m := createMachine(t, identifierMachine)
+	for tape, accepted := range map[string]bool{
+		"x":        true,
+		"_tmp1":    true,
+		"snake_42": true,
+		"1abc":     false,
+		"ab-c":     false,
+		"naïve":    false,
+		"":         false,
+	} {
+		res := simulation.ResultOf(m.Simulate(tape))
+		assert.Equal(t, accepted, res.Accepted, "tape: %v", tape)
+	}
+
+	// The alphabet is inferred from the members of the classes
+	d := m.(*DFA)
+	assert.Len(t, d.Alphabet, 37)
+
+	// Only the trap state is reported, every symbol is used
+	lint := d.Lint()
+	assert.Len(t, lint, 1)
+	assert.Equal(t, machine.CodeDeadState, lint[0].Code)
+}
+
+func TestLiteralSymbolsTakePrecedenceOverClasses(t *testing.T) {
+	m := createMachine(t, `
+	{
+		"Type": "DFA",
+		"Alphabet": ["a", "b", "a,b"],
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "a,b" },
+		  { "Start": "q0", "End": "q0", "Symbol": "[ab]" },
+		  { "Start": "q1", "End": "q1", "Otherwise": true }
+		]
+	}`)
+	assert.True(t, simulation.ResultOf(m.Simulate("aba,b")).Accepted)
+	assert.False(t, simulation.ResultOf(m.Simulate("ab")).Accepted)
+}
+
+func TestInvalidSymbolClasses(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		symbol string
+		code   string
+	}{
+		{name: "reversed-range", symbol: "[z-a]", code: machine.CodeInvalidSymbolClass},
+		{name: "no-match", symbol: "[x-z]", code: machine.CodeSymbolNotInAlphabet},
+		{name: "list-member", symbol: "a,bb", code: machine.CodeSymbolNotInAlphabet},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load([]byte(`
+			{
+				"Type": "DFA",
+				"Alphabet": "ab",
+				"Start": "q0",
+				"States": [{ "Id": "q0", "Ending": true }],
+				"Transitions": [
+				  { "Start": "q0", "End": "q0", "Symbol": "` + tc.symbol + `" },
+				  { "Start": "q0", "End": "q0", "Otherwise": true }
+				]
+			}`))
+			diags := machine.DiagnosticsOf(err)
+			assert.Len(t, diags, 1)
+			assert.Equal(t, tc.code, diags[0].Code)
+			assert.Equal(t, "/Transitions/0/Symbol", diags[0].Pointer)
+		})
+	}
+}
+
+// Machines with large alphabets use a sparse transition table, small ones a
+// dense table. Both should agree with the arithmetic
+func TestCompiledTables(t *testing.T) {
+	for _, size := range []int{3, 300} {
+		size := size
+		t.Run(fmt.Sprintf("alphabet=%v", size), func(t *testing.T) {
+			t.Parallel()
+			digits := runeDigits(size)
+			m := createMachine(t, string(modNMachine(7, digits, ""))).(*DFA)
+			assert.Equal(t, size <= denseSymbolLimit, m.compiled.dense != nil)
+			for n := 0; n < 100; n++ {
+				// Write n in base `size`
+				tape := ""
+				for rest := n; rest > 0; rest /= size {
+					tape = digits[rest%size] + tape
+				}
+				res := simulation.ResultOf(m.Simulate(tape))
+				assert.Equal(t, n%7 == 0, res.Accepted, "n: %v", n)
+			}
+		})
+	}
+}
+
 func createMachine(t *testing.T, fromString string) simulation.Machine {
 	m, err := Load([]byte(fromString))
 	assert.NoError(t, err, machineShouldBuildOkay)
@@ -0,0 +1,92 @@
+package dfa
+
+import "strings"
+
+// Searches for the shortest tape that one DFA accepts and the other does not,
+// trying symbols in alphabet order. Returns false if the DFAs accept the same
+// tapes. The DFAs are compared over the union of their alphabets, where a
+// symbol that is not in the alphabet of a DFA can only be read by an
+// "otherwise" transition. The tape is written with the separator of `a`
+func Distinguish(a, b *DFA) (string, bool) {
+	symbols, distinct := DistinguishSymbols(a, b)
+	return strings.Join(symbols, a.Separator), distinct
+}
+
+// Like `Distinguish`, but returns the symbols of the tape, so that it can be
+// written with the separator of either DFA
+func DistinguishSymbols(a, b *DFA) ([]string, bool) {
+	ta, tb := a.table(), b.table()
+	symbols := append([]string{}, a.Alphabet...)
+	for _, s := range b.Alphabet {
+		if !a.Alphabet.Contains(s) {
+			symbols = append(symbols, s)
+		}
+	}
+
+	// Breadth first search over pairs of states, where `dead` is the state of
+	// a DFA that had no transition to take
+	type pair struct{ a, b int32 }
+	type visit struct {
+		parent pair
+		symbol int
+	}
+	const dead int32 = -1
+	accepts := func(d *DFA, state int32) bool {
+		return state != dead && d.States[state].Ending
+	}
+	next := func(d *DFA, t *table, state int32, symbol string) int32 {
+		if state == dead {
+			return dead
+		}
+		index, ok := t.symbols[symbol]
+		if !ok {
+			index = noTransition
+		}
+		if i := t.next(state, index); i != noTransition {
+			return t.ends[i]
+		}
+		return dead
+	}
+
+	start := pair{dead, dead}
+	if a.Start != nil {
+		start.a = ta.states[a.Start]
+	}
+	if b.Start != nil {
+		start.b = tb.states[b.Start]
+	}
+	visited := map[pair]visit{start: {symbol: -1}}
+	for queue := []pair{start}; len(queue) > 0; queue = queue[1:] {
+		p := queue[0]
+		if accepts(a, p.a) != accepts(b, p.b) {
+			var path []string
+			for v := visited[p]; v.symbol >= 0; v = visited[p] {
+				path = append([]string{symbols[v.symbol]}, path...)
+				p = v.parent
+			}
+			return path, true
+		}
+		for i, s := range symbols {
+			n := pair{next(a, ta, p.a, s), next(b, tb, p.b, s)}
+			if _, ok := visited[n]; !ok {
+				visited[n] = visit{parent: p, symbol: i}
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil, false
+}
+
+// Whether two DFAs accept the same tapes, see `Distinguish`
+func Equivalent(a, b *DFA) bool {
+	_, distinct := Distinguish(a, b)
+	return !distinct
+}
+
+// The compiled transition table of the DFA, compiling it if needed
+func (d *DFA) table() *table {
+	if d.compiled == nil {
+		return compile(d)
+	}
+	return d.compiled
+}
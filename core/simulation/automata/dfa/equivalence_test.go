@@ -0,0 +1,58 @@
+package dfa
+
+import (
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinguish(t *testing.T) {
+	t.Parallel()
+	odda := createMachine(t, ODDA).(*DFA)
+	regex := func(pattern, alphabet string) *DFA {
+		d, err := FromRegex(pattern, machine.RunesAlphabet(alphabet))
+		assert.NoError(t, err)
+		return d
+	}
+
+	for _, tc := range []struct {
+		name     string
+		a, b     *DFA
+		tape     string
+		distinct bool
+	}{
+		{name: "same machine", a: odda, b: odda},
+		{name: "same language", a: odda, b: regex("b*a(b*ab*a)*b*", "ab")},
+		{
+			name:     "shortest counterexample",
+			a:        odda,
+			b:        regex("b*a(b*ab*a)*b*|bb", "ab"),
+			tape:     "bb",
+			distinct: true,
+		},
+		{
+			name:     "empty tape",
+			a:        odda,
+			b:        regex("(a|b)*", "ab"),
+			tape:     "",
+			distinct: true,
+		},
+		{
+			name:     "other alphabet",
+			a:        odda,
+			b:        regex("b*a(b*ab*a)*b*|c", "abc"),
+			tape:     "c",
+			distinct: true,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tape, distinct := Distinguish(tc.a, tc.b)
+			assert.Equal(t, tc.distinct, distinct)
+			assert.Equal(t, tc.tape, tape)
+			assert.Equal(t, !tc.distinct, Equivalent(tc.b, tc.a))
+		})
+	}
+}
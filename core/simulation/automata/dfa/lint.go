@@ -0,0 +1,75 @@
+package dfa
+
+import "github.com/flapflapio/simulator/core/simulation/machine"
+
+// Lints the underlying graph, and additionally warns about nondeterministic
+// choices (which the simulation resolves by taking the first matching
+// transition) and alphabet symbols that no transition uses
+func (d *DFA) Lint() machine.Diagnostics {
+	diags := d.Graph.Lint()
+	matchers := d.matchers()
+	used := make(map[string]bool, len(d.Alphabet))
+
+	// First transition leaving each state on each symbol, and the first
+	// "otherwise" transition of each state
+	type choice struct{ start, symbol string }
+	first := make(map[choice]int, len(d.Transitions))
+	otherwise := make(map[string]int)
+	check := func(key choice, i int, on string) {
+		j, seen := first[key]
+		if !seen {
+			first[key] = i
+		} else if d.Transitions[j].End.Id != d.Transitions[i].End.Id {
+			diags = append(diags, nondeterministic(d, j, i, on))
+		}
+	}
+
+	for i, t := range d.Transitions {
+		switch m := matchers[i]; {
+		case m.otherwise:
+			j, seen := otherwise[t.Start.Id]
+			if !seen {
+				otherwise[t.Start.Id] = i
+			} else if d.Transitions[j].End.Id != t.End.Id {
+				diags = append(diags, nondeterministic(d, j, i, "otherwise"))
+			}
+		case m.class != nil:
+			for _, symbol := range d.Alphabet {
+				if m.class.Contains(symbol) {
+					used[symbol] = true
+					check(choice{t.Start.Id, symbol}, i, "'"+symbol+"'")
+				}
+			}
+		default:
+			used[t.Symbol] = true
+			check(choice{t.Start.Id, t.Symbol}, i, "'"+t.Symbol+"'")
+		}
+	}
+
+	for _, symbol := range d.Alphabet {
+		if !used[symbol] {
+			diags = append(diags, machine.Warnf(
+				machine.Pointer("Alphabet"),
+				machine.CodeUnusedSymbol,
+				"symbol '%v' is part of the alphabet but no transition uses it",
+				symbol))
+		}
+	}
+
+	return diags
+}
+
+// Warns that transitions j and i (j < i) leave the same state on `on` but lead
+// to different states
+func nondeterministic(d *DFA, j, i int, on string) machine.Diagnostic {
+	t, other := d.Transitions[i], d.Transitions[j]
+	w := machine.Warnf(
+		machine.Pointer("Transitions", i),
+		machine.CodeNondeterministicChoice,
+		"state '%v' has more than one transition on %v (to '%v' and '%v'), "+
+			"which is not allowed in a DFA",
+		t.Start.Id, on, other.End.Id, t.End.Id)
+	w.States = []string{t.Start.Id}
+	w.Transitions = []int{j, i}
+	return w
+}
@@ -0,0 +1,46 @@
+package dfa
+
+import (
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintOddA(t *testing.T) {
+	m, err := Load([]byte(ODDA))
+	assert.NoError(t, err, machineShouldBuildOkay)
+	assert.Empty(t, m.Lint())
+}
+
+func TestLintNondeterministicAndUnusedSymbols(t *testing.T) {
+	m := From(DFAParams{
+		Alphabet: machine.RunesAlphabet("abc"),
+		GraphParams: machine.GraphParams{
+			Start: "q0",
+			States: []machine.State{
+				{Id: "q0", Ending: false},
+				{Id: "q1", Ending: true},
+			},
+			Transitions: []machine.TransitionParams{
+				{Start: "q0", End: "q1", Symbol: "a"},
+				{Start: "q0", End: "q0", Symbol: "b"},
+				{Start: "q1", End: "q1", Symbol: "b"},
+				{Start: "q1", End: "q0", Symbol: "a"},
+				{Start: "q0", End: "q0", Symbol: "a"},
+			},
+		},
+	})
+
+	diags := m.Lint()
+	assert.Len(t, diags, 2)
+
+	assert.Equal(t, machine.CodeNondeterministicChoice, diags[0].Code)
+	assert.Equal(t, "/Transitions/4", diags[0].Pointer)
+	assert.Equal(t, []int{0, 4}, diags[0].Transitions)
+	assert.Equal(t, []string{"q0"}, diags[0].States)
+
+	assert.Equal(t, machine.CodeUnusedSymbol, diags[1].Code)
+	assert.Equal(t, "/Alphabet", diags[1].Pointer)
+	assert.Contains(t, diags[1].Message, "'c'")
+}
@@ -1,9 +1,7 @@
 package dfa
 
 import (
-	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/flapflapio/simulator/core/simulation/machine"
 )
@@ -23,84 +21,163 @@ func LoadWithSchema(document interface{}, schema interface{}) (*DFA, error) {
 		return nil, errf(err)
 	} else if err = addAlphabet(dfa, documentMap); err != nil {
 		return nil, errf(err)
-	} else if err = checkThatStatesHaveATransitionForEverySymbol(dfa); err != nil {
-		return nil, errf(err)
-	} else if err = checkThatTransitionSymbolsMatchAlphabet(dfa); err != nil {
-		return nil, errf(err)
+	}
+
+	if complete, _ := documentMap["Complete"].(bool); complete {
+		dfa.Complete()
+	}
+	dfa.Compile()
+
+	// Report every problem with the transitions at once
+	diags := append(
+		checkThatStatesHaveATransitionForEverySymbol(dfa),
+		checkThatTransitionSymbolsMatchAlphabet(dfa)...)
+	if len(diags) > 0 {
+		return nil, errf(diags)
 	}
 
 	return dfa, nil
 }
 
-func checkThatTransitionSymbolsMatchAlphabet(dfa *DFA) error {
-	for _, t := range dfa.Transitions {
-		found := false
-		for _, s := range dfa.Alphabet {
-			if string(s) == t.Symbol {
-				found = true
-				break
-			}
+// Every transition must use a symbol of the alphabet. A symbol class must be
+// valid, and it must match at least one symbol of the alphabet. If it lists
+// its symbols, they must all be part of the alphabet
+func checkThatTransitionSymbolsMatchAlphabet(dfa *DFA) machine.Diagnostics {
+	var diags machine.Diagnostics
+	for i, t := range dfa.Transitions {
+		if _, ok := dfa.compiled.symbols[t.Symbol]; ok || t.Otherwise {
+			continue
+		}
+		pointer := machine.Pointer("Transitions", i, "Symbol")
+		if !machine.IsSymbolClass(t.Symbol) {
+			diags = append(diags, machine.Errorf(
+				pointer,
+				machine.CodeSymbolNotInAlphabet,
+				"DFA is invalid, symbol '%v' of transition %v -> %v "+
+					"is not present in the alphabet %v",
+				t.Symbol, t.Start.Id, t.End.Id, dfa.Alphabet))
+			continue
 		}
-		if !found {
-			return fmt.Errorf(
-				"DFA is invalid, %v contains a symbol not present in the alphabet", t)
+		class, err := machine.ParseSymbolClass(t.Symbol)
+		if err != nil {
+			diags = append(diags, machine.Errorf(
+				pointer, machine.CodeInvalidSymbolClass,
+				"DFA is invalid, %v", err))
+			continue
+		}
+		if d, ok := checkClassAgainstAlphabet(class, dfa.Alphabet); !ok {
+			d.Pointer = pointer
+			d.Message = fmt.Sprintf(
+				"DFA is invalid, symbol class '%v' of transition %v -> %v %v",
+				t.Symbol, t.Start.Id, t.End.Id, d.Message)
+			diags = append(diags, d)
 		}
 	}
-	return nil
+	return diags
 }
 
-func checkThatStatesHaveATransitionForEverySymbol(dfa *DFA) error {
-	for _, state := range dfa.Graph.States {
-		for _, symbol := range dfa.Alphabet {
-			sym := string(symbol)
-			found := false
-			for _, transition := range dfa.Transitions {
-				if transition.Start.Id == state.Id && transition.Symbol == sym {
-					found = true
-					break
-				}
+func checkClassAgainstAlphabet(
+	class *machine.SymbolClass,
+	alphabet machine.Alphabet,
+) (machine.Diagnostic, bool) {
+	if members, err := class.Members(); err == nil {
+		for _, m := range members {
+			if len([]rune(m)) > 1 && !alphabet.Contains(m) {
+				return machine.Errorf("", machine.CodeSymbolNotInAlphabet,
+					"lists symbol '%v', which is not present in the alphabet %v",
+					m, alphabet), false
 			}
-			if !found {
-				return fmt.Errorf(
-					"DFA is invalid, %v is missing a transition for symbol %v",
-					state, sym)
+		}
+	}
+	for _, s := range alphabet {
+		if class.Contains(s) {
+			return machine.Diagnostic{}, true
+		}
+	}
+	return machine.Errorf("", machine.CodeSymbolNotInAlphabet,
+		"does not match any symbol of the alphabet %v", alphabet), false
+}
+
+// Every state must have a transition for every symbol of the alphabet, unless
+// it has an "otherwise" transition
+func checkThatStatesHaveATransitionForEverySymbol(dfa *DFA) machine.Diagnostics {
+	var diags machine.Diagnostics
+	t := dfa.compiled
+	for i, state := range dfa.Graph.States {
+		if t.isComplete(int32(i)) {
+			continue
+		}
+		for symbol, sym := range dfa.Alphabet {
+			if t.lookup(int32(i), int32(symbol)) == noTransition {
+				diags = append(diags, machine.Errorf(
+					machine.Pointer("States", i),
+					machine.CodeMissingTransition,
+					"DFA is invalid, state '%v' is missing a transition for symbol '%v'",
+					state.Id, sym))
 			}
 		}
 	}
-	return nil
+	return diags
 }
 
 func addAlphabet(dfa *DFA, document map[string]interface{}) error {
+	if unknown, ok := document["Separator"]; ok {
+		separator, ok := unknown.(string)
+		if !ok || separator == "" {
+			return machine.Diagnostics{machine.Errorf(
+				machine.Pointer("Separator"),
+				machine.CodeInvalidDocument,
+				"'Separator' field in json document is not valid "+
+					"- it should be a non-empty string")}
+		}
+		dfa.Separator = separator
+	}
+
 	unknown, ok := document["Alphabet"]
 	if !ok {
-		inferAlphabet(dfa)
-		return nil
+		return inferAlphabet(dfa)
 	}
 
-	alphabet, ok := unknown.(string)
-	if !ok {
-		return errors.New("'Alphabet' field in json document is not valid " +
-			"- it should be a string")
+	alphabet, err := machine.ParseAlphabet(unknown)
+	if err != nil {
+		return err
 	}
 	dfa.Alphabet = alphabet
 	return nil
 }
 
-func inferAlphabet(dfa *DFA) {
-	alphabet := ""
-	appendSymbol := func(symbol string) {
-		alreadyPresent := false
-		for _, r := range alphabet {
-			if strconv.QuoteRune(r) == symbol {
-				alreadyPresent = true
-				break
-			}
-		}
-		if !alreadyPresent {
-			alphabet += symbol
+// Builds the alphabet from the symbols of the transitions, in order of
+// appearance. Symbol classes contribute all of their members
+func inferAlphabet(dfa *DFA) error {
+	dfa.Alphabet = machine.Alphabet{}
+	add := func(symbol string) {
+		if !dfa.Alphabet.Contains(symbol) {
+			dfa.Alphabet = append(dfa.Alphabet, symbol)
 		}
 	}
-	for _, t := range dfa.Graph.Transitions {
-		appendSymbol(string(t.Symbol[0]))
+	for i, t := range dfa.Graph.Transitions {
+		if t.Otherwise {
+			continue
+		}
+		if !machine.IsSymbolClass(t.Symbol) {
+			add(t.Symbol)
+			continue
+		}
+		class, err := machine.ParseSymbolClass(t.Symbol)
+		if err == nil {
+			var members []string
+			if members, err = class.Members(); err == nil {
+				for _, m := range members {
+					add(m)
+				}
+				continue
+			}
+		}
+		return machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Transitions", i, "Symbol"),
+			machine.CodeInvalidSymbolClass,
+			"cannot infer the alphabet from symbol class '%v' (%v), "+
+				"please provide an 'Alphabet'", t.Symbol, err)}
 	}
+	return nil
 }
@@ -0,0 +1,160 @@
+package dfa
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+// Limits of the DFAs built from regular expressions. The subset construction
+// can need exponentially many states, e.g. for (a|b)*a(a|b){40}
+const (
+	maxRegexStates      = 10000
+	maxRegexTransitions = 1000000
+)
+
+// Builds a DFA that accepts exactly the tapes over `alphabet` that the regular
+// expression matches in full, as if it were anchored with ^ and $. The syntax
+// is that of Go's `regexp` package, but anchors and word boundaries are not
+// supported, and every symbol of the alphabet must be a single character. The
+// DFA is complete: tapes that can no longer match end up in a trap state.
+// Expressions that need more than 10000 states (or a million transitions) are
+// rejected
+func FromRegex(pattern string, alphabet machine.Alphabet) (*DFA, error) {
+	if !alphabet.IsRunes() {
+		return nil, machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Alphabet"), machine.CodeInvalidRegex,
+			"regular expressions need an alphabet of single characters, got %v",
+			alphabet)}
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Regex"), machine.CodeInvalidRegex, "%v", err)}
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Regex"), machine.CodeInvalidRegex, "%v", err)}
+	}
+	for _, inst := range prog.Inst {
+		if inst.Op == syntax.InstEmptyWidth {
+			return nil, machine.Diagnostics{machine.Errorf(
+				machine.Pointer("Regex"), machine.CodeInvalidRegex,
+				"anchors and word boundaries are not supported, "+
+					"the expression always has to match the whole tape")}
+		}
+	}
+
+	runes := make([]rune, len(alphabet))
+	for i, s := range alphabet {
+		runes[i], _ = utf8.DecodeRuneInString(s)
+	}
+
+	// Subset construction: each state of the DFA is the set of instructions
+	// that the program can be at after reading the same input
+	var (
+		sets   [][]uint32
+		ids    = map[string]int{}
+		params = DFAParams{Alphabet: alphabet}
+	)
+	state := func(set []uint32) string {
+		key := fmt.Sprint(set)
+		i, ok := ids[key]
+		if !ok {
+			i = len(sets)
+			ids[key] = i
+			sets = append(sets, set)
+			params.States = append(params.States, machine.State{
+				Id:     fmt.Sprintf("q%v", i),
+				Ending: matches(prog, set),
+			})
+		}
+		return params.States[i].Id
+	}
+
+	params.Start = state(closure(prog, []uint32{uint32(prog.Start)}))
+	for i := 0; i < len(sets); i++ {
+		if len(sets) > maxRegexStates || len(sets)*len(runes) > maxRegexTransitions {
+			return nil, machine.Diagnostics{machine.Errorf(
+				machine.Pointer("Regex"), machine.CodeInvalidRegex,
+				"the expression needs a DFA with more than %v states or %v transitions",
+				maxRegexStates, maxRegexTransitions)}
+		}
+		for j, r := range runes {
+			params.Transitions = append(params.Transitions, machine.TransitionParams{
+				Start:  params.States[i].Id,
+				End:    state(step(prog, sets[i], r)),
+				Symbol: alphabet[j],
+			})
+		}
+	}
+
+	d := From(params)
+	d.Compile()
+	return d, nil
+}
+
+// The instructions reachable from `pcs` without reading any input, sorted
+func closure(prog *syntax.Prog, pcs []uint32) []uint32 {
+	seen := map[uint32]bool{}
+	var set []uint32
+	var visit func(pc uint32)
+	visit = func(pc uint32) {
+		if seen[pc] {
+			return
+		}
+		seen[pc] = true
+		switch inst := prog.Inst[pc]; inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			visit(inst.Out)
+			visit(inst.Arg)
+		case syntax.InstCapture, syntax.InstNop:
+			visit(inst.Out)
+		case syntax.InstFail:
+		default:
+			set = append(set, pc)
+		}
+	}
+	for _, pc := range pcs {
+		visit(pc)
+	}
+	sort.Slice(set, func(i, j int) bool { return set[i] < set[j] })
+	return set
+}
+
+// The instructions that the program can be at after reading `r` from any of
+// the instructions in `set`
+func step(prog *syntax.Prog, set []uint32, r rune) []uint32 {
+	var next []uint32
+	for _, pc := range set {
+		inst := prog.Inst[pc]
+		switch inst.Op {
+		case syntax.InstRune, syntax.InstRune1:
+			if !inst.MatchRune(r) {
+				continue
+			}
+		case syntax.InstRuneAnyNotNL:
+			if r == '\n' {
+				continue
+			}
+		case syntax.InstRuneAny:
+		default:
+			continue
+		}
+		next = append(next, inst.Out)
+	}
+	return closure(prog, next)
+}
+
+func matches(prog *syntax.Prog, set []uint32) bool {
+	for _, pc := range set {
+		if prog.Inst[pc].Op == syntax.InstMatch {
+			return true
+		}
+	}
+	return false
+}
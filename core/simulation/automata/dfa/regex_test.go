@@ -0,0 +1,95 @@
+package dfa
+
+import (
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromRegex(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		pattern  string
+		alphabet string
+		tapes    map[string]bool
+	}{
+		{
+			name:     "odd a",
+			pattern:  "b*a(b*ab*a)*b*",
+			alphabet: "ab",
+			tapes: map[string]bool{
+				"":      false,
+				"a":     true,
+				"bab":   true,
+				"aa":    false,
+				"ababa": true,
+			},
+		},
+		{
+			name:     "is anchored",
+			pattern:  "ab|c",
+			alphabet: "abc",
+			tapes: map[string]bool{
+				"ab":  true,
+				"c":   true,
+				"abc": false,
+				"cc":  false,
+			},
+		},
+		{
+			name:     "classes and repeats",
+			pattern:  "[0-9]{2,3}.",
+			alphabet: "0123x",
+			tapes: map[string]bool{
+				"12x":   true,
+				"1230":  true,
+				"1x":    false,
+				"12345": false,
+			},
+		},
+		{
+			name:     "empty language",
+			pattern:  "[^ab]",
+			alphabet: "ab",
+			tapes: map[string]bool{
+				"":  false,
+				"a": false,
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			d, err := FromRegex(tc.pattern, machine.RunesAlphabet(tc.alphabet))
+			assert.NoError(t, err)
+			assert.Empty(t, checkThatStatesHaveATransitionForEverySymbol(d))
+			for tape, accepted := range tc.tapes {
+				res := simulation.ResultOf(d.Simulate(tape))
+				assert.Equal(t, accepted, res.Accepted, tape)
+			}
+		})
+	}
+}
+
+func TestFromRegexFails(t *testing.T) {
+	t.Parallel()
+	for name, tc := range map[string]struct {
+		pattern  string
+		alphabet machine.Alphabet
+	}{
+		"syntax":        {"a(b", machine.RunesAlphabet("ab")},
+		"anchor":        {"^ab$", machine.RunesAlphabet("ab")},
+		"word boundary": {`a\b`, machine.RunesAlphabet("ab")},
+		"long symbols":  {"ab", machine.Alphabet{"ab", "c"}},
+		"too large":     {"(a|b)*a(a|b){40}", machine.RunesAlphabet("ab")},
+	} {
+		_, err := FromRegex(tc.pattern, tc.alphabet)
+		diags := machine.DiagnosticsOf(err)
+		if assert.Len(t, diags, 1, name) {
+			assert.Equal(t, machine.CodeInvalidRegex, diags[0].Code, name)
+		}
+	}
+}
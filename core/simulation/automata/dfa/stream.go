@@ -0,0 +1,194 @@
+package dfa
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/flapflapio/simulator/core/errors"
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+const (
+	// Size of the buffer that streamed input is read through
+	streamBufferSize = 64 * 1024
+
+	// The most unread input reported in the `RemainingInput` of a stream
+	maxRemainingInput = 64
+)
+
+// A simulation that reads its input from a stream. Only a small window of the
+// input is held in memory at a time, and the path is not recorded
+type DFAStreamSimulation struct {
+	machine   *DFA
+	table     *table
+	state     int32
+	input     *bufio.Reader
+	steps     int
+	rejected  bool
+	err       error
+	tokenizer *machine.Tokenizer
+
+	// Number of bytes needed to read any one symbol (and its separator)
+	lookahead int
+}
+
+func (d *DFA) SimulateStream(input io.Reader) simulation.Simulation {
+	t := d.compiled
+	if t == nil {
+		t = compile(d)
+	}
+	lookahead := utf8.UTFMax
+	for _, s := range d.Alphabet {
+		if len(s) > lookahead {
+			lookahead = len(s)
+		}
+	}
+	lookahead += len(d.Separator)
+	size := streamBufferSize
+	if lookahead > size {
+		size = lookahead
+	}
+	return &DFAStreamSimulation{
+		machine:   d,
+		table:     t,
+		state:     t.states[d.Start],
+		input:     bufio.NewReaderSize(input, size),
+		tokenizer: machine.NewTokenizer(d.Alphabet, d.Separator),
+		lookahead: lookahead,
+	}
+}
+
+// Perform a transition
+func (dfa *DFAStreamSimulation) Step() {
+	if dfa.Done() {
+		return
+	}
+	symbol, width := dfa.nextSymbol()
+	if dfa.err != nil {
+		return
+	}
+	next := dfa.table.next(dfa.state, symbol)
+	if next == noTransition {
+		dfa.rejected = true
+		return
+	}
+	dfa.state = dfa.table.ends[next]
+	dfa.steps++
+	dfa.consume(width)
+}
+
+// Get the current status (state + other info) of a simulation
+func (dfa *DFAStreamSimulation) Stat() simulation.Report {
+	return simulation.Report{Result: dfa.result()}
+}
+
+// Get the final result of your simulation.
+// Returns a SimulationIncomplete error if the simulation is not done, or the
+// error that occurred reading the input
+func (dfa *DFAStreamSimulation) Result() (simulation.Result, error) {
+	if !dfa.Done() {
+		return simulation.Result{}, errors.ErrSimulationIncomplete
+	}
+	if dfa.err != nil {
+		return simulation.Result{}, dfa.err
+	}
+	return dfa.result(), nil
+}
+
+// Check if a simulation is finished
+func (dfa *DFAStreamSimulation) Done() bool {
+	return dfa.rejected || dfa.err != nil || dfa.atEnd()
+}
+
+func (dfa *DFAStreamSimulation) result() simulation.Result {
+	return simulation.Result{
+		Accepted:       !dfa.rejected && dfa.err == nil && dfa.atEnd() && dfa.currentState().Ending,
+		Path:           []string{dfa.currentState().Id},
+		RemainingInput: dfa.remainingInput(),
+		Steps:          dfa.steps,
+	}
+}
+
+// Whether the input has been read entirely. Read errors other than EOF are
+// recorded and end the simulation
+func (dfa *DFAStreamSimulation) atEnd() bool {
+	return len(dfa.peek(1)) == 0
+}
+
+// The start of the unread input, truncated to `maxRemainingInput` bytes
+func (dfa *DFAStreamSimulation) remainingInput() string {
+	return string(dfa.peek(maxRemainingInput))
+}
+
+// Returns the next `n` bytes of input without consuming them (fewer at the
+// end of the input). Read errors other than EOF are recorded and end the
+// simulation
+func (dfa *DFAStreamSimulation) peek(n int) []byte {
+	data, err := dfa.input.Peek(n)
+	if err != nil && err != io.EOF && dfa.err == nil {
+		dfa.err = err
+	}
+	return data
+}
+
+// Reads the next symbol without consuming it. Returns its index in the
+// alphabet (or `noTransition` if the input does not start with a symbol of the
+// alphabet) and the number of bytes that it occupies. A width of -1 means
+// that the symbol is unknown and extends past the lookahead, up to the next
+// separator
+func (dfa *DFAStreamSimulation) nextSymbol() (int32, int) {
+	peek := dfa.peek(dfa.lookahead)
+	if dfa.err != nil {
+		return noTransition, 0
+	}
+	if dfa.table.runes {
+		if c := peek[0]; c < utf8.RuneSelf {
+			return dfa.table.ascii[c], 1
+		}
+		_, width := utf8.DecodeRune(peek)
+		if i, ok := dfa.table.symbols[string(peek[:width])]; ok {
+			return i, width
+		}
+		return noTransition, width
+	}
+
+	symbol, width, ok := dfa.tokenizer.Next(string(peek))
+	if ok {
+		return dfa.table.symbols[symbol], width
+	}
+	sep := []byte(dfa.machine.Separator)
+	if len(sep) > 0 && len(peek) == dfa.lookahead && !bytes.Contains(peek, sep) {
+		return noTransition, -1
+	}
+	return noTransition, width
+}
+
+// Discards `width` bytes of input, or everything up to and including the next
+// separator if `width` is -1
+func (dfa *DFAStreamSimulation) consume(width int) {
+	if width >= 0 {
+		dfa.input.Discard(width)
+		return
+	}
+	sep := []byte(dfa.machine.Separator)
+	for {
+		peek := dfa.peek(dfa.input.Size())
+		if i := bytes.Index(peek, sep); i >= 0 {
+			dfa.input.Discard(i + len(sep))
+			return
+		}
+		if len(peek) < dfa.input.Size() {
+			dfa.input.Discard(len(peek))
+			return
+		}
+		// Keep the end of the buffer, it may hold the start of the separator
+		dfa.input.Discard(len(peek) - len(sep) + 1)
+	}
+}
+
+func (dfa *DFAStreamSimulation) currentState() *machine.State {
+	return &dfa.machine.States[dfa.state]
+}
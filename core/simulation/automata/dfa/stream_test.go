@@ -0,0 +1,123 @@
+package dfa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/stretchr/testify/assert"
+)
+
+const separatedIdentifiers = `
+{
+	"Type": "DFA",
+	"Alphabet": ["if", "else"],
+	"Separator": " ",
+	"Start": "q0",
+	"States": [{ "Id": "q0", "Ending": true }, { "Id": "q1" }],
+	"Transitions": [
+	  { "Start": "q0", "End": "q0", "Symbol": "if,else" },
+	  { "Start": "q0", "End": "q1", "Otherwise": true },
+	  { "Start": "q1", "End": "q0", "Otherwise": true }
+	]
+}`
+
+// Streaming the tape should give the same result as passing it as a string
+func TestSimulateStreamMatchesSimulate(t *testing.T) {
+	long := strings.Repeat("a", 3*streamBufferSize)
+	for _, tc := range []struct {
+		name    string
+		machine string
+		tapes   []string
+	}{
+		{
+			name:    "odd-a",
+			machine: ODDA,
+			tapes:   []string{"", "a", "aaba", "abb", long, long + "a", long + "c" + long},
+		},
+		{
+			name:    "identifiers",
+			machine: identifierMachine,
+			tapes:   []string{"x", "snake_42", "1abc", "naïve", "a" + long},
+		},
+		{
+			name:    "multi-character",
+			machine: string(modNMachine(5, []string{"zero", "one", "two"}, "")),
+			tapes:   []string{"one", "onetwozero", "onetw", "twotwotwo", "thr"},
+		},
+		{
+			name:    "separator",
+			machine: separatedIdentifiers,
+			tapes: []string{
+				"if else",
+				"if x else",
+				"if " + long + " else",
+				"if " + long + " else " + long,
+				"else  if",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := createMachine(t, tc.machine).(*DFA)
+			for _, tape := range tc.tapes {
+				expected := simulation.ResultOf(m.Simulate(tape))
+				actual := simulation.ResultOf(
+					m.SimulateStream(iotest.HalfReader(strings.NewReader(tape))))
+				assertStreamResult(t, tape, *expected, actual)
+			}
+		})
+	}
+}
+
+func assertStreamResult(
+	t *testing.T,
+	tape string,
+	expected simulation.Result,
+	actual *simulation.Result,
+) {
+	t.Helper()
+	name := tape
+	if len(name) > 20 {
+		name = name[:20] + "..."
+	}
+	if !assert.NotNil(t, actual, "tape: %v", name) {
+		return
+	}
+	assert.Equal(t, expected.Accepted, actual.Accepted, "tape: %v", name)
+	if len(expected.Path) > 0 {
+		// The path of a rejected simulation ends with the final state twice
+		steps := len(expected.Path) - 1
+		if expected.RemainingInput != "" {
+			steps--
+		}
+		assert.Equal(t, expected.Path[len(expected.Path)-1], actual.Path[0], "tape: %v", name)
+		assert.Equal(t, steps, actual.Steps, "tape: %v", name)
+	}
+	assert.True(t,
+		strings.HasPrefix(expected.RemainingInput, actual.RemainingInput),
+		"tape: %v", name)
+	assert.LessOrEqual(t, len(actual.RemainingInput), maxRemainingInput)
+	assert.Equal(t,
+		expected.RemainingInput == "",
+		actual.RemainingInput == "",
+		"tape: %v", name)
+}
+
+func TestSimulateStreamReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	m := createMachine(t, ODDA).(*DFA)
+	sim := m.SimulateStream(iotest.TimeoutReader(
+		iotest.OneByteReader(strings.NewReader("aab"))))
+	simulation.RunToCompletion(sim)
+	_, err := sim.Result()
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+
+	sim = m.SimulateStream(iotest.ErrReader(readErr))
+	simulation.RunToCompletion(sim)
+	_, err = sim.Result()
+	assert.ErrorIs(t, err, readErr)
+}
@@ -0,0 +1,48 @@
+package dfa
+
+import "github.com/flapflapio/simulator/core/simulation/machine"
+
+// Decides which symbols a transition accepts. A transition's symbol is a
+// literal if it is part of the alphabet, otherwise it may be a symbol class
+type symbolMatcher struct {
+	literal   string
+	class     *machine.SymbolClass
+	otherwise bool
+}
+
+// Whether the transition accepts `symbol` (a symbol of the alphabet).
+// "Otherwise" transitions never match, they are only taken as a fallback
+func (m symbolMatcher) matches(symbol string) bool {
+	if m.otherwise {
+		return false
+	}
+	if m.class != nil {
+		return m.class.Contains(symbol)
+	}
+	return m.literal == symbol
+}
+
+// Creates a matcher for each transition of the DFA, in order. Symbols that are
+// neither in the alphabet nor valid classes are matched literally (the
+// alphabet check reports them)
+func (d *DFA) matchers() []symbolMatcher {
+	alphabet := make(map[string]bool, len(d.Alphabet))
+	for _, s := range d.Alphabet {
+		alphabet[s] = true
+	}
+	matchers := make([]symbolMatcher, len(d.Transitions))
+	for i, t := range d.Transitions {
+		matchers[i] = matcherFor(t, alphabet)
+	}
+	return matchers
+}
+
+func matcherFor(t machine.Transition, alphabet map[string]bool) symbolMatcher {
+	m := symbolMatcher{literal: t.Symbol, otherwise: t.Otherwise}
+	if !t.Otherwise && !alphabet[t.Symbol] && machine.IsSymbolClass(t.Symbol) {
+		if class, err := machine.ParseSymbolClass(t.Symbol); err == nil {
+			m.class = class
+		}
+	}
+	return m
+}
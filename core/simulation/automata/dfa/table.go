@@ -0,0 +1,159 @@
+package dfa
+
+import (
+	"unicode/utf8"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+const (
+	// Tables with at most this many symbols and cells are stored as a dense
+	// state × symbol array, larger ones as a map
+	denseSymbolLimit = 256
+	denseCellLimit   = 1 << 22
+
+	noTransition int32 = -1
+)
+
+// A transition table compiled from a DFA. States and symbols are numbered by
+// their position in the DFA, and each (state, symbol) cell holds the index of
+// the first transition that matches it. "Otherwise" transitions are kept in a
+// separate column
+type table struct {
+	states    map[*machine.State]int32
+	symbols   map[string]int32
+	nsymbols  int32
+	dense     []int32
+	sparse    map[cell]int32
+	otherwise []int32
+
+	// State that each transition leads to
+	ends []int32
+
+	// Set if every symbol is a single rune and there is no separator, in
+	// which case symbols can be read one rune at a time. ASCII symbols are
+	// looked up in `ascii` instead of the `symbols` map
+	runes bool
+	ascii [utf8.RuneSelf]int32
+}
+
+type cell struct{ state, symbol int32 }
+
+func compile(d *DFA) *table {
+	t := &table{
+		states:    make(map[*machine.State]int32, len(d.States)),
+		symbols:   make(map[string]int32, len(d.Alphabet)),
+		nsymbols:  int32(len(d.Alphabet)),
+		otherwise: make([]int32, len(d.States)),
+		ends:      make([]int32, len(d.Transitions)),
+		runes:     d.Separator == "" && d.Alphabet.IsRunes(),
+	}
+	for i := range d.States {
+		t.states[&d.States[i]] = int32(i)
+		t.otherwise[i] = noTransition
+	}
+	for i := range t.ascii {
+		t.ascii[i] = noTransition
+	}
+	for i, s := range d.Alphabet {
+		t.symbols[s] = int32(i)
+		if len(s) == 1 && s[0] < utf8.RuneSelf {
+			t.ascii[s[0]] = int32(i)
+		}
+	}
+
+	cells := len(d.States) * len(d.Alphabet)
+	if len(d.Alphabet) <= denseSymbolLimit && cells <= denseCellLimit {
+		t.dense = make([]int32, cells)
+		for i := range t.dense {
+			t.dense[i] = noTransition
+		}
+	} else {
+		t.sparse = map[cell]int32{}
+	}
+
+	for i, m := range d.matchers() {
+		tr := d.Transitions[i]
+		state := t.states[tr.Start]
+		t.ends[i] = t.states[tr.End]
+		switch {
+		case m.otherwise:
+			if t.otherwise[state] == noTransition {
+				t.otherwise[state] = int32(i)
+			}
+		case m.class != nil:
+			for symbol, s := range d.Alphabet {
+				if m.class.Contains(s) {
+					t.set(state, int32(symbol), int32(i))
+				}
+			}
+		default:
+			if symbol, ok := t.symbols[m.literal]; ok {
+				t.set(state, symbol, int32(i))
+			}
+		}
+	}
+	return t
+}
+
+// Records transition `i` for the cell, unless an earlier transition matches
+// it already
+func (t *table) set(state, symbol, i int32) {
+	if t.lookup(state, symbol) == noTransition {
+		if t.dense != nil {
+			t.dense[state*t.nsymbols+symbol] = i
+		} else {
+			t.sparse[cell{state, symbol}] = i
+		}
+	}
+}
+
+// The index of the transition matching the symbol from the state, ignoring
+// "otherwise" transitions
+func (t *table) lookup(state, symbol int32) int32 {
+	if t.dense != nil {
+		return t.dense[state*t.nsymbols+symbol]
+	}
+	if i, ok := t.sparse[cell{state, symbol}]; ok {
+		return i
+	}
+	return noTransition
+}
+
+// The index of the transition to take from the state on the symbol. Pass a
+// negative symbol for input that is not part of the alphabet, which can only
+// be consumed by an "otherwise" transition
+func (t *table) next(state, symbol int32) int32 {
+	if symbol >= 0 {
+		if i := t.lookup(state, symbol); i != noTransition {
+			return i
+		}
+	}
+	return t.otherwise[state]
+}
+
+// Whether the state has a transition (possibly "otherwise") for every symbol
+func (t *table) isComplete(state int32) bool {
+	if t.otherwise[state] != noTransition {
+		return true
+	}
+	for symbol := int32(0); symbol < t.nsymbols; symbol++ {
+		if t.lookup(state, symbol) == noTransition {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads the next symbol from the front of `input` using the table's fast path.
+// Only valid if `t.runes` is set
+func (t *table) nextRune(input string) (symbol int32, width int) {
+	if c := input[0]; c < utf8.RuneSelf {
+		return t.ascii[c], 1
+	}
+	_, width = utf8.DecodeRuneInString(input)
+	if i, ok := t.symbols[input[:width]]; ok {
+		return i, width
+	}
+	return noTransition, width
+}
@@ -1,8 +1,6 @@
 package automata
 
 import (
-	"errors"
-
 	"github.com/flapflapio/simulator/core/simulation"
 	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
 	"github.com/flapflapio/simulator/core/simulation/machine"
@@ -29,6 +27,19 @@ func LoadWithSchema(
 	return createMachineOfType(t, documentMap, schema)
 }
 
+// Loads a machine from the given `document` and lints it. An error is returned
+// only if the machine cannot be loaded
+func Lint(document interface{}) (machine.Diagnostics, error) {
+	m, err := Load(document)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := m.(simulation.Linter); ok {
+		return l.Lint(), nil
+	}
+	return machine.Diagnostics{}, nil
+}
+
 func Dump(mach simulation.Machine) string {
 	return mach.Json()
 }
@@ -45,18 +56,26 @@ func createMachineOfType(
 	case machine.PDA:
 	case machine.TM:
 	}
-	return nil, errors.New(
-		"machine was not able to be created, unrecognized machine type")
+	return nil, machine.Diagnostics{machine.Errorf(
+		machine.Pointer("Type"),
+		machine.CodeUnsupportedType,
+		"machine was not able to be created, unrecognized machine type")}
 }
 
 func extractType(document map[string]interface{}) (string, error) {
 	unknown, ok := document["Type"]
 	if !ok {
-		return "", errors.New("invalid document, field 'Type' is required")
+		return "", machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Type"),
+			machine.SchemaCode("required"),
+			"invalid document, field 'Type' is required")}
 	}
 	t, ok := unknown.(string)
 	if !ok {
-		return "", errors.New("invalid document, field 'Type' should be a string")
+		return "", machine.Diagnostics{machine.Errorf(
+			machine.Pointer("Type"),
+			machine.SchemaCode("invalid_type"),
+			"invalid document, field 'Type' should be a string")}
 	}
 	return machine.ParseMachineType(t), nil
 }
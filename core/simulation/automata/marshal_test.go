@@ -21,7 +21,7 @@ var machineStrings = map[string]TestCaseDFAMarshaling{
 		success:   true,
 		marshaled: dfa.ODDA,
 		unmarshaled: dfa.From(dfa.DFAParams{
-			Alphabet: "ab",
+			Alphabet: machine.RunesAlphabet("ab"),
 			GraphParams: machine.GraphParams{
 				Start: "q0",
 				States: []machine.State{
@@ -63,3 +63,25 @@ func TestMarshaling(t *testing.T) {
 func assertMachinesEqual(t *testing.T, m1, m2 simulation.Machine) {
 	assert.Equal(t, m1.Json(), m2.Json(), "json value of maps should be equal")
 }
+
+func TestMetaIsPreserved(t *testing.T) {
+	doc := `
+	{
+		"SchemaVersion": 1,
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Meta": { "Comment": "Accepts an odd number of a's" },
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0", "Ending": false, "Meta": { "X": 100, "Y": 50, "Color": "#ff0000" } },
+		  { "Id": "q1", "Ending": true, "Meta": { "X": 250, "Y": 50 } }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "a", "Meta": { "Comment": "first" } },
+		  { "Start": "q1", "End": "q0", "Symbol": "a" }
+		]
+	}`
+	m, err := Load([]byte(doc))
+	assert.NoError(t, err)
+	assert.JSONEq(t, doc, m.Json())
+}
@@ -0,0 +1,345 @@
+// Random machines and tapes, for sets of practice problems and for testing
+// operations on machines against many inputs
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+const (
+	// Tries at generating a minimal DFA before giving up
+	maxAttempts = 1000
+
+	// Cells of the transition function (states × symbols) over all the tries
+	// at generating a minimal DFA, so that larger DFAs get fewer tries
+	maxAttemptCells = 1000000
+)
+
+var (
+	ErrNoMinimalDFA = errors.New(
+		"no minimal DFA was found with the given parameters")
+
+	ErrMinimalNFA = errors.New(
+		"only DFAs can be generated minimal")
+)
+
+// The shape of generated machines. States are named "q0", "q1", ... and "q0"
+// is the start state
+type Params struct {
+	States   int
+	Alphabet machine.Alphabet
+
+	// For DFAs, the chance that a state has a transition for a symbol. The
+	// missing transitions go to a trap state (see `dfa.DFA.Complete`), so DFAs
+	// with a density below 1 may have one more state. For NFAs, the chance of
+	// a transition from a state to another (or the same) state for a symbol
+	Density float64
+
+	// The fraction of the states that are ending states, rounded to the
+	// nearest number of states
+	Accepting float64
+
+	// NFAs only, the chance of a transition that reads nothing from a state to
+	// another state
+	Epsilon float64
+
+	// Adds transitions before any other so that every state can be reached
+	// from the start state. The other transitions are then added with a
+	// chance of `Density`
+	Connected bool
+
+	// DFAs only, implies `Connected`: no two states accept the same tapes, the
+	// trap state included, so every other state can reach an ending state. Such
+	// DFAs are generated by trial and error, so parameters that can never give
+	// one (e.g. no ending states) fail with `ErrNoMinimalDFA`
+	Minimal bool
+}
+
+// Checks that machines can be generated with the parameters
+func (p Params) Validate() error {
+	switch {
+	case p.States < 1:
+		return errors.New("machines need at least one state")
+	case len(p.Alphabet) == 0:
+		return errors.New("the alphabet cannot be empty")
+	case !isFraction(p.Density):
+		return fmt.Errorf("density %v is not between 0 and 1", p.Density)
+	case !isFraction(p.Accepting):
+		return fmt.Errorf("accepting ratio %v is not between 0 and 1", p.Accepting)
+	case !isFraction(p.Epsilon):
+		return fmt.Errorf("epsilon ratio %v is not between 0 and 1", p.Epsilon)
+	}
+	seen := make(map[string]bool, len(p.Alphabet))
+	for _, s := range p.Alphabet {
+		if s == "" || seen[s] {
+			return fmt.Errorf("symbol '%v' is empty or appears twice in the alphabet", s)
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+func isFraction(f float64) bool {
+	return f >= 0 && f <= 1
+}
+
+// Generates a random DFA. Minimal DFAs take several tries, and the context is
+// checked between them
+func DFA(ctx context.Context, r *rand.Rand, p Params) (*dfa.DFA, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	attempts := 1
+	if p.Minimal {
+		p.Connected = true
+		attempts = maxAttemptCells / (p.States * len(p.Alphabet))
+		if attempts > maxAttempts {
+			attempts = maxAttempts
+		}
+		if attempts < 1 {
+			attempts = 1
+		}
+	}
+	for i := 0; i < attempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		delta := p.deterministic(r)
+		ending := p.ending(r)
+		if p.Minimal && !minimal(delta, ending) {
+			continue
+		}
+
+		var transitions []machine.TransitionParams
+		for s, row := range delta {
+			for a, e := range row {
+				if e != missing {
+					transitions = append(transitions, machine.TransitionParams{
+						Start:  stateId(s),
+						End:    stateId(e),
+						Symbol: p.Alphabet[a],
+					})
+				}
+			}
+		}
+		d := dfa.From(dfa.DFAParams{
+			GraphParams: machine.GraphParams{
+				Start:       stateId(0),
+				States:      states(ending),
+				Transitions: transitions,
+			},
+			Alphabet: p.Alphabet,
+		})
+		d.Complete()
+		d.Compile()
+		return d, nil
+	}
+	return nil, ErrNoMinimalDFA
+}
+
+// Generates a random NFA. NFAs cannot be loaded yet, so the NFA is generated as
+// a machine document
+func NFA(r *rand.Rand, p Params) (map[string]interface{}, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if p.Minimal {
+		return nil, ErrMinimalNFA
+	}
+
+	// `edges[s][a][e]` is set if there is a transition from state s to state e
+	// for symbol a, where a == len(Alphabet) is the empty symbol
+	n, symbols := p.States, len(p.Alphabet)
+	edges := make([][][]bool, n)
+	for s := range edges {
+		edges[s] = make([][]bool, symbols+1)
+		for a := range edges[s] {
+			edges[s][a] = make([]bool, n)
+		}
+	}
+	if p.Connected {
+		for e := 1; e < n; e++ {
+			edges[r.Intn(e)][r.Intn(symbols)][e] = true
+		}
+	}
+	for s := range edges {
+		for a := range edges[s] {
+			chance := p.Density
+			if a == symbols {
+				chance = p.Epsilon
+			}
+			for e := range edges[s][a] {
+				if a == symbols && s == e {
+					continue
+				}
+				if !edges[s][a][e] && r.Float64() < chance {
+					edges[s][a][e] = true
+				}
+			}
+		}
+	}
+
+	g := machine.NewBlankGraph().WithStates(states(p.ending(r))...)
+	g.Start = &g.States[0]
+	for s := range edges {
+		for a := range edges[s] {
+			symbol := ""
+			if a < symbols {
+				symbol = p.Alphabet[a]
+			}
+			for e, ok := range edges[s][a] {
+				if ok {
+					g.Transitions = append(g.Transitions, machine.Transition{
+						Start:  &g.States[s],
+						End:    &g.States[e],
+						Symbol: symbol,
+					})
+				}
+			}
+		}
+	}
+	doc := g.JsonMap()
+	doc["SchemaVersion"] = float64(machine.SchemaVersion)
+	doc["Type"] = machine.NFA
+	doc["Alphabet"] = p.Alphabet.JsonValue()
+	return doc, nil
+}
+
+// A state that has no transition for a symbol
+const missing = -1
+
+// A random transition function, where `delta[s][a]` is the state that state s
+// goes to for symbol a
+func (p Params) deterministic(r *rand.Rand) [][]int {
+	delta := make([][]int, p.States)
+	for s := range delta {
+		delta[s] = make([]int, len(p.Alphabet))
+		for a := range delta[s] {
+			delta[s][a] = missing
+		}
+	}
+
+	// Every state is reached from one of the states before it, which always
+	// has a free symbol: the state just before has no transitions yet
+	if p.Connected {
+		type free struct{ s, a int }
+		for e := 1; e < p.States; e++ {
+			var candidates []free
+			for s := 0; s < e; s++ {
+				for a, next := range delta[s] {
+					if next == missing {
+						candidates = append(candidates, free{s, a})
+					}
+				}
+			}
+			c := candidates[r.Intn(len(candidates))]
+			delta[c.s][c.a] = e
+		}
+	}
+
+	for s := range delta {
+		for a := range delta[s] {
+			if delta[s][a] == missing && r.Float64() < p.Density {
+				delta[s][a] = r.Intn(p.States)
+			}
+		}
+	}
+	return delta
+}
+
+// Which states are ending states, chosen at random
+func (p Params) ending(r *rand.Rand) []bool {
+	ending := make([]bool, p.States)
+	count := int(math.Round(p.Accepting * float64(p.States)))
+	for _, s := range r.Perm(p.States)[:count] {
+		ending[s] = true
+	}
+	return ending
+}
+
+// Whether a DFA with the transition function and ending states is minimal:
+// every state can be reached, and no two states (counting a dead state that
+// missing transitions go to) accept the same tapes. The states are split into
+// classes by whether they are ending states, and then split further by the
+// classes that their transitions go to until no class splits
+func minimal(delta [][]int, ending []bool) bool {
+	n := len(delta)
+	reached := make([]bool, n)
+	reached[0] = true
+	for queue := []int{0}; len(queue) > 0; queue = queue[1:] {
+		for _, e := range delta[queue[0]] {
+			if e != missing && !reached[e] {
+				reached[e] = true
+				queue = append(queue, e)
+			}
+		}
+	}
+
+	// The dead state is numbered n, and only counted if a transition is missing
+	total := n
+	for s := range delta {
+		if !reached[s] {
+			return false
+		}
+		for _, e := range delta[s] {
+			if e == missing {
+				total = n + 1
+			}
+		}
+	}
+
+	class := make([]int, total)
+	for s := 0; s < n; s++ {
+		if ending[s] {
+			class[s] = 1
+		}
+	}
+	next := func(s, a int) int {
+		if s == n || delta[s][a] == missing {
+			return n
+		}
+		return delta[s][a]
+	}
+
+	// Each round splits the classes by the class that each symbol leads to,
+	// one symbol at a time: a state's new class is numbered by the pair of its
+	// class so far and the class of where the symbol takes it
+	for classes := 0; ; {
+		refined := append([]int{}, class...)
+		split := 0
+		for a := range delta[0] {
+			pairs := map[[2]int]int{}
+			for s := range refined {
+				key := [2]int{refined[s], class[next(s, a)]}
+				if _, ok := pairs[key]; !ok {
+					pairs[key] = len(pairs)
+				}
+				refined[s] = pairs[key]
+			}
+			split = len(pairs)
+		}
+		if split == classes {
+			return classes == total
+		}
+		classes, class = split, refined
+	}
+}
+
+func states(ending []bool) []machine.State {
+	states := make([]machine.State, len(ending))
+	for s := range states {
+		states[s] = machine.State{Id: stateId(s), Ending: ending[s]}
+	}
+	return states
+}
+
+func stateId(s int) string {
+	return fmt.Sprintf("q%v", s)
+}
@@ -0,0 +1,176 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDFA(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name   string
+		params Params
+	}{
+		{"sparse", Params{States: 6, Alphabet: machine.RunesAlphabet("ab"), Density: 0.3, Accepting: 0.5}},
+		{"complete", Params{States: 5, Alphabet: machine.RunesAlphabet("01"), Density: 1, Accepting: 0.4}},
+		{"connected", Params{States: 8, Alphabet: machine.RunesAlphabet("a"), Accepting: 0.25, Connected: true}},
+		{"minimal", Params{States: 5, Alphabet: machine.RunesAlphabet("ab"), Density: 0.8, Accepting: 0.4, Minimal: true}},
+		{"minimal complete", Params{States: 4, Alphabet: machine.Alphabet{"if", "else"}, Density: 1, Accepting: 0.5, Minimal: true}},
+	} {
+		for seed := int64(0); seed < 20; seed++ {
+			d, err := DFA(context.Background(), rand.New(rand.NewSource(seed)), tc.params)
+			if !assert.NoError(t, err, tc.name) {
+				continue
+			}
+			p := tc.params
+			states := p.States
+			if d.Trap != "" {
+				states++
+			}
+			assert.Len(t, d.States, states, tc.name)
+			assert.Len(t, d.Transitions, states*len(p.Alphabet), tc.name)
+			assert.Equal(t, "q0", d.Start.Id, tc.name)
+			ending := 0
+			for _, s := range d.States {
+				if s.Ending {
+					ending++
+				}
+			}
+			assert.Equal(t, int(p.Accepting*float64(p.States)+0.5), ending, tc.name)
+			if p.Density == 1 {
+				assert.Empty(t, d.Trap, tc.name)
+			}
+			if p.Connected || p.Minimal {
+				assert.Len(t, reachable(d), states, tc.name)
+			}
+			if p.Minimal {
+				for i := range d.States {
+					for j := i + 1; j < len(d.States); j++ {
+						assert.False(t, dfa.Equivalent(from(d, i), from(d, j)),
+							"%v (seed %v): q%v and q%v are equivalent", tc.name, seed, i, j)
+					}
+				}
+			}
+
+			// The DFA is a valid machine document
+			loaded, err := dfa.Load([]byte(d.Json()))
+			if assert.NoError(t, err, tc.name) {
+				assert.True(t, dfa.Equivalent(d, loaded), tc.name)
+			}
+		}
+	}
+}
+
+func TestDFAIsCancelled(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := Params{States: 5, Alphabet: machine.RunesAlphabet("ab"), Accepting: 0.5, Minimal: true}
+	_, err := DFA(ctx, rand.New(rand.NewSource(0)), p)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDFAIsReproducible(t *testing.T) {
+	t.Parallel()
+	p := Params{States: 10, Alphabet: machine.RunesAlphabet("abc"), Density: 0.7, Accepting: 0.3}
+	a, err := DFA(context.Background(), rand.New(rand.NewSource(42)), p)
+	assert.NoError(t, err)
+	b, err := DFA(context.Background(), rand.New(rand.NewSource(42)), p)
+	assert.NoError(t, err)
+	assert.Equal(t, a.Json(), b.Json())
+}
+
+func TestNFA(t *testing.T) {
+	t.Parallel()
+	p := Params{
+		States:    4,
+		Alphabet:  machine.RunesAlphabet("ab"),
+		Density:   0.3,
+		Accepting: 0.5,
+		Epsilon:   1,
+		Connected: true,
+	}
+	doc, err := NFA(rand.New(rand.NewSource(1)), p)
+	assert.NoError(t, err)
+	assert.Equal(t, machine.NFA, doc["Type"])
+	data, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	g, err := machine.Load(data)
+	assert.NoError(t, err)
+	assert.Len(t, g.States, 4)
+
+	// Every state has a transition that reads nothing to every other state
+	epsilon := 0
+	for _, tr := range g.Transitions {
+		if tr.Symbol == "" {
+			epsilon++
+			assert.NotEqual(t, tr.Start.Id, tr.End.Id)
+		}
+	}
+	assert.Equal(t, 4*3, epsilon)
+
+	p.Minimal = true
+	_, err = NFA(rand.New(rand.NewSource(1)), p)
+	assert.ErrorIs(t, err, ErrMinimalNFA)
+}
+
+func TestInvalidParams(t *testing.T) {
+	t.Parallel()
+	valid := Params{States: 3, Alphabet: machine.RunesAlphabet("ab"), Density: 0.5, Accepting: 0.5}
+	assert.NoError(t, valid.Validate())
+	for _, tc := range []struct {
+		name   string
+		change func(p *Params)
+	}{
+		{"no states", func(p *Params) { p.States = 0 }},
+		{"no alphabet", func(p *Params) { p.Alphabet = nil }},
+		{"repeated symbol", func(p *Params) { p.Alphabet = machine.Alphabet{"a", "a"} }},
+		{"empty symbol", func(p *Params) { p.Alphabet = machine.Alphabet{""} }},
+		{"density", func(p *Params) { p.Density = 1.5 }},
+		{"accepting", func(p *Params) { p.Accepting = -0.1 }},
+		{"epsilon", func(p *Params) { p.Epsilon = 2 }},
+	} {
+		p := valid
+		tc.change(&p)
+		assert.Error(t, p.Validate(), tc.name)
+		_, err := DFA(context.Background(), rand.New(rand.NewSource(0)), p)
+		assert.Error(t, err, tc.name)
+	}
+
+	// Without ending states, every state accepts nothing
+	valid.Accepting, valid.Minimal = 0, true
+	_, err := DFA(context.Background(), rand.New(rand.NewSource(0)), valid)
+	assert.ErrorIs(t, err, ErrNoMinimalDFA)
+}
+
+// The DFA, starting in its i-th state
+func from(d *dfa.DFA, i int) *dfa.DFA {
+	return &dfa.DFA{
+		Graph: &machine.Graph{
+			Start:       &d.States[i],
+			States:      d.States,
+			Transitions: d.Transitions,
+		},
+		Alphabet: d.Alphabet,
+	}
+}
+
+// The ids of the states that can be reached from the start state
+func reachable(d *dfa.DFA) map[string]bool {
+	reached := map[string]bool{d.Start.Id: true}
+	for queue := []string{d.Start.Id}; len(queue) > 0; queue = queue[1:] {
+		for _, tr := range d.Transitions {
+			if tr.Start.Id == queue[0] && !reached[tr.End.Id] {
+				reached[tr.End.Id] = true
+				queue = append(queue, tr.End.Id)
+			}
+		}
+	}
+	return reached
+}
@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+)
+
+// Tries at drawing each tape before giving up, as tapes that were already
+// drawn are drawn again
+const drawsPerTape = 10
+
+// Generates up to `count` distinct tapes for a DFA, as cases that expect what
+// the DFA does with them. Tapes are biased towards the boundaries of what the
+// DFA accepts: the empty tape, the shortest accepted and rejected tapes, and
+// near misses that are a single edit (a symbol removed, added or replaced)
+// away from another tape but have the other outcome. Up to half of the tapes
+// are near misses, and the rest are random tapes of at most `maxLength`
+// symbols. Fewer tapes are returned if the DFA has too few distinct tapes
+func Tapes(r *rand.Rand, d *dfa.DFA, count, maxLength int) []suite.Case {
+	g := tapes{
+		r:         r,
+		d:         d,
+		count:     count,
+		tokenizer: machine.NewTokenizer(d.Alphabet, d.Separator),
+		seen:      map[string]bool{},
+	}
+	g.add("empty tape", nil)
+	if tape, ok := shortest(d, true); ok {
+		g.add("shortest accepted tape", g.split(tape))
+	}
+	if tape, ok := shortest(d, false); ok {
+		g.add("shortest rejected tape", g.split(tape))
+	}
+	if len(d.Alphabet) == 0 {
+		return g.cases
+	}
+
+	// Near misses of the tapes so far, and of random tapes
+	for draws := 0; len(g.cases) < count/2 && draws < drawsPerTape*count; draws++ {
+		i := r.Intn(len(g.symbols) + 1)
+		seed := g.random(maxLength)
+		if i < len(g.symbols) {
+			seed = g.symbols[i]
+		}
+		miss := g.edit(seed)
+		if g.accepts(miss) != g.accepts(seed) {
+			g.add(fmt.Sprintf("near miss of %q", g.join(seed)), miss)
+		}
+	}
+
+	for draws := 0; len(g.cases) < count && draws < drawsPerTape*count; draws++ {
+		g.add("random tape", g.random(maxLength))
+	}
+	return g.cases
+}
+
+// The state of `Tapes`, where `symbols[i]` are the symbols of the tape of
+// `cases[i]`
+type tapes struct {
+	r         *rand.Rand
+	d         *dfa.DFA
+	count     int
+	tokenizer *machine.Tokenizer
+	seen      map[string]bool
+	cases     []suite.Case
+	symbols   [][]string
+}
+
+// Adds a case for a tape, unless there are enough or it was already added
+func (g *tapes) add(name string, symbols []string) {
+	tape := g.join(symbols)
+	if len(g.cases) >= g.count || g.seen[tape] {
+		return
+	}
+	g.seen[tape] = true
+	g.cases = append(g.cases, suite.Case{
+		Name:   name,
+		Tape:   tape,
+		Accept: g.accepts(symbols),
+	})
+	g.symbols = append(g.symbols, symbols)
+}
+
+func (g *tapes) accepts(symbols []string) bool {
+	res := simulation.ResultOf(g.d.Simulate(g.join(symbols)))
+	return res != nil && res.Accepted
+}
+
+func (g *tapes) join(symbols []string) string {
+	return strings.Join(symbols, g.d.Separator)
+}
+
+func (g *tapes) split(tape string) []string {
+	var symbols []string
+	for tape != "" {
+		symbol, width, _ := g.tokenizer.Next(tape)
+		symbols = append(symbols, symbol)
+		tape = tape[width:]
+	}
+	return symbols
+}
+
+func (g *tapes) random(maxLength int) []string {
+	symbols := make([]string, g.r.Intn(maxLength+1))
+	for i := range symbols {
+		symbols[i] = g.symbol()
+	}
+	return symbols
+}
+
+func (g *tapes) symbol() string {
+	return g.d.Alphabet[g.r.Intn(len(g.d.Alphabet))]
+}
+
+// A copy of the tape with a symbol removed, added or replaced at random
+func (g *tapes) edit(symbols []string) []string {
+	edited := append([]string{}, symbols...)
+	op := g.r.Intn(3)
+	if len(symbols) == 0 {
+		op = 1
+	}
+	switch op {
+	case 0:
+		i := g.r.Intn(len(edited))
+		edited = append(edited[:i], edited[i+1:]...)
+	case 1:
+		i := g.r.Intn(len(edited) + 1)
+		edited = append(edited[:i], append([]string{g.symbol()}, edited[i:]...)...)
+	case 2:
+		edited[g.r.Intn(len(edited))] = g.symbol()
+	}
+	return edited
+}
+
+// The shortest tape that the DFA accepts, or rejects if `accepted` is false.
+// This is the shortest tape that tells the DFA apart from a DFA that accepts
+// no tape, or every tape
+func shortest(d *dfa.DFA, accepted bool) (string, bool) {
+	other := dfa.From(dfa.DFAParams{
+		GraphParams: machine.GraphParams{
+			Start:  "q0",
+			States: []machine.State{{Id: "q0", Ending: !accepted}},
+			Transitions: []machine.TransitionParams{
+				{Start: "q0", End: "q0", Otherwise: true},
+			},
+		},
+		Alphabet:  d.Alphabet,
+		Separator: d.Separator,
+	})
+	return dfa.Distinguish(d, other)
+}
@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTapes(t *testing.T) {
+	t.Parallel()
+	odda, err := dfa.Load([]byte(dfa.ODDA))
+	assert.NoError(t, err)
+
+	cases := Tapes(rand.New(rand.NewSource(3)), odda, 20, 6)
+	assert.Len(t, cases, 20)
+	assert.Equal(t, "empty tape", cases[0].Name)
+	assert.Equal(t, "", cases[0].Tape)
+	assert.False(t, cases[0].Accept)
+	assert.Equal(t, "shortest accepted tape", cases[1].Name)
+	assert.Equal(t, "a", cases[1].Tape)
+	assert.True(t, cases[1].Accept)
+
+	seen := map[string]bool{}
+	misses := 0
+	for _, c := range cases {
+		assert.False(t, seen[c.Tape], "%q appears twice", c.Tape)
+		seen[c.Tape] = true
+		res := simulation.ResultOf(odda.Simulate(c.Tape))
+		assert.Equal(t, res.Accepted, c.Accept, c.Tape)
+		if strings.HasPrefix(c.Name, "near miss") {
+			misses++
+		}
+	}
+	assert.Equal(t, 10-2, misses)
+}
+
+func TestTapesOfSmallLanguages(t *testing.T) {
+	t.Parallel()
+
+	// Only the empty tape is accepted, and tapes have at most 2 symbols
+	d, err := dfa.FromRegex("", machine.RunesAlphabet("a"))
+	assert.NoError(t, err)
+	cases := Tapes(rand.New(rand.NewSource(0)), d, 10, 2)
+	tapes := map[string]bool{}
+	for _, c := range cases {
+		tapes[c.Tape] = c.Accept
+	}
+	assert.Equal(t, map[string]bool{"": true, "a": false, "aa": false}, tapes)
+	assert.Equal(t, "shortest rejected tape", cases[1].Name)
+
+	// Symbols are separated on the tape
+	d.Separator = " "
+	d.Alphabet = machine.Alphabet{"a"}
+	for _, c := range Tapes(rand.New(rand.NewSource(0)), d, 3, 3) {
+		assert.NotContains(t, c.Tape, "aa")
+	}
+}
@@ -0,0 +1,180 @@
+package jflap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+// Layout of the states that have no position, in a grid
+const (
+	gridColumns = 6
+	gridSpacing = 150.0
+	gridMargin  = 100.0
+)
+
+// Converts a loaded machine to a JFLAP file, see `ExportDocument`
+func Export(m simulation.Machine) ([]byte, error) {
+	return ExportDocument(m.JsonMap())
+}
+
+// Converts a machine document to a JFLAP file. JFLAP has no equivalent of
+// symbol classes and "otherwise" transitions, so they are expanded into one
+// transition per symbol of the alphabet. DFAs with symbols of more than one
+// character cannot be exported, because JFLAP would read those symbols one
+// character at a time. States are placed at the position in their 'Meta' (see
+// `Import`), or on a grid if they have none
+func ExportDocument(doc map[string]interface{}) ([]byte, error) {
+	s := structure{Automaton: &automaton{}}
+	machineType, _ := doc["Type"].(string)
+	switch machine.ParseMachineType(machineType) {
+	case machine.DFA, machine.NFA:
+		s.Type = typeFA
+	case machine.PDA:
+		s.Type = typePDA
+	case machine.TM:
+		s.Type = typeTuring
+	default:
+		return nil, fmt.Errorf("%w: machine type '%v'", ErrUnsupported, machineType)
+	}
+
+	var alphabet machine.Alphabet
+	if unknown, ok := doc["Alphabet"]; ok {
+		var err error
+		if alphabet, err = machine.ParseAlphabet(unknown); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := map[string]string{}
+	start, _ := doc["Start"].(string)
+	for i, st := range machine.ObjectList(doc["States"]) {
+		id, _ := st["Id"].(string)
+		ids[id] = strconv.Itoa(i)
+		js := state{Id: ids[id], Name: id}
+		js.Label, _ = st["Label"].(string)
+		if ending, _ := st["Ending"].(bool); ending {
+			js.Final = &struct{}{}
+		}
+		if id == start {
+			js.Initial = &struct{}{}
+		}
+		js.X, js.Y = position(st, i)
+		s.Automaton.States = append(s.Automaton.States, js)
+	}
+
+	transitions := machine.ObjectList(doc["Transitions"])
+	reads, err := expandSymbols(transitions, alphabet)
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range transitions {
+		from, _ := t["Start"].(string)
+		to, _ := t["End"].(string)
+		if _, ok := ids[from]; !ok {
+			return nil, fmt.Errorf("transition %v uses unknown state '%v'", i, from)
+		}
+		if _, ok := ids[to]; !ok {
+			return nil, fmt.Errorf("transition %v uses unknown state '%v'", i, to)
+		}
+		for _, read := range reads[i] {
+			if s.Type == typeFA && machineType == machine.DFA &&
+				utf8.RuneCountInString(read) > 1 {
+				return nil, fmt.Errorf("%w: symbol '%v' has more than one character",
+					ErrUnsupported, read)
+			}
+			s.Automaton.Transitions = append(s.Automaton.Transitions, transition{
+				From:  ids[from],
+				To:    ids[to],
+				Read:  read,
+				Pop:   optionalString(t, "Pop"),
+				Push:  optionalString(t, "Push"),
+				Write: optionalString(t, "Write"),
+				Move:  optionalString(t, "Move"),
+			})
+		}
+	}
+
+	data, err := xml.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	header := `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n"
+	return append(append([]byte(header), data...), '\n'), nil
+}
+
+// The symbols that each transition reads, with symbol classes and "otherwise"
+// transitions expanded over the alphabet
+func expandSymbols(
+	transitions []map[string]interface{},
+	alphabet machine.Alphabet,
+) ([][]string, error) {
+	reads := make([][]string, len(transitions))
+	covered := map[string]map[string]bool{}
+	for i, t := range transitions {
+		if otherwise, _ := t["Otherwise"].(bool); otherwise {
+			continue
+		}
+		symbol, _ := t["Symbol"].(string)
+		reads[i] = []string{symbol}
+		if len(alphabet) > 0 && !alphabet.Contains(symbol) && machine.IsSymbolClass(symbol) {
+			class, err := machine.ParseSymbolClass(symbol)
+			if err != nil {
+				return nil, err
+			}
+			reads[i] = nil
+			for _, s := range alphabet {
+				if class.Contains(s) {
+					reads[i] = append(reads[i], s)
+				}
+			}
+		}
+		from, _ := t["Start"].(string)
+		if covered[from] == nil {
+			covered[from] = map[string]bool{}
+		}
+		for _, s := range reads[i] {
+			covered[from][s] = true
+		}
+	}
+
+	for i, t := range transitions {
+		if otherwise, _ := t["Otherwise"].(bool); !otherwise {
+			continue
+		}
+		if len(alphabet) == 0 {
+			return nil, fmt.Errorf(
+				"%w: 'otherwise' transition %v in a machine without an alphabet",
+				ErrUnsupported, i)
+		}
+		from, _ := t["Start"].(string)
+		for _, s := range alphabet {
+			if !covered[from][s] {
+				reads[i] = append(reads[i], s)
+			}
+		}
+	}
+	return reads, nil
+}
+
+func position(st map[string]interface{}, i int) (*float64, *float64) {
+	meta, _ := st["Meta"].(map[string]interface{})
+	x, okx := meta["X"].(float64)
+	y, oky := meta["Y"].(float64)
+	if !okx || !oky {
+		x = gridMargin + gridSpacing*float64(i%gridColumns)
+		y = gridMargin + gridSpacing*float64(i/gridColumns)
+	}
+	return &x, &y
+}
+
+func optionalString(m map[string]interface{}, key string) *string {
+	if s, ok := m[key].(string); ok {
+		return &s
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Conversion between JFLAP files (.jff) and machine documents
+package jflap
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+// JFLAP structure types and the machine types that they convert to
+const (
+	typeFA     = "fa"
+	typePDA    = "pda"
+	typeTuring = "turing"
+)
+
+var ErrUnsupported = errors.New("unsupported JFLAP file")
+
+// The root element of a .jff file. Files written by JFLAP 6 put the states and
+// transitions directly under the structure, later versions use <automaton>
+type structure struct {
+	XMLName     xml.Name     `xml:"structure"`
+	Type        string       `xml:"type"`
+	Tapes       int          `xml:"tapes,omitempty"`
+	Automaton   *automaton   `xml:"automaton"`
+	States      []state      `xml:"state"`
+	Transitions []transition `xml:"transition"`
+	Blocks      []struct{}   `xml:"block"`
+}
+
+type automaton struct {
+	States      []state      `xml:"state"`
+	Transitions []transition `xml:"transition"`
+	Blocks      []struct{}   `xml:"block"`
+}
+
+type state struct {
+	Id      string    `xml:"id,attr"`
+	Name    string    `xml:"name,attr"`
+	X       *float64  `xml:"x"`
+	Y       *float64  `xml:"y"`
+	Label   string    `xml:"label,omitempty"`
+	Initial *struct{} `xml:"initial"`
+	Final   *struct{} `xml:"final"`
+}
+
+type transition struct {
+	From  string  `xml:"from"`
+	To    string  `xml:"to"`
+	Read  string  `xml:"read"`
+	Pop   *string `xml:"pop"`
+	Push  *string `xml:"push"`
+	Write *string `xml:"write"`
+	Move  *string `xml:"move"`
+}
+
+// Converts a JFLAP file into a machine document. Finite automata become DFAs
+// if they are deterministic (no empty or multi-character reads and at most one
+// transition per state and symbol) and NFAs otherwise. Pushdown automata and
+// single tape Turing machines become PDAs and TMs, with the extra fields of
+// their transitions kept as 'Pop'/'Push' and 'Write'/'Move'. State positions
+// are kept in the 'Meta' object of each state, as 'X' and 'Y'
+func Import(r io.Reader) (map[string]interface{}, error) {
+	var s structure
+	if err := xml.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid JFLAP file: %w", err)
+	}
+	if s.Automaton != nil {
+		s.States = append(s.States, s.Automaton.States...)
+		s.Transitions = append(s.Transitions, s.Automaton.Transitions...)
+		s.Blocks = append(s.Blocks, s.Automaton.Blocks...)
+	}
+
+	var machineType string
+	switch s.Type {
+	case typeFA:
+		machineType = finiteAutomatonType(s.Transitions)
+	case typePDA:
+		machineType = machine.PDA
+	case typeTuring:
+		machineType = machine.TM
+		if s.Tapes > 1 {
+			return nil, fmt.Errorf("%w: Turing machines with %v tapes",
+				ErrUnsupported, s.Tapes)
+		}
+	default:
+		return nil, fmt.Errorf("%w: type '%v'", ErrUnsupported, s.Type)
+	}
+	if len(s.Blocks) > 0 {
+		return nil, fmt.Errorf("%w: building blocks", ErrUnsupported)
+	}
+
+	ids := stateIds(s.States)
+	doc := map[string]interface{}{
+		"SchemaVersion": float64(machine.SchemaVersion),
+		"Type":          machineType,
+	}
+	states := make([]interface{}, 0, len(s.States))
+	for _, st := range s.States {
+		m := map[string]interface{}{
+			"Id":     ids[st.Id],
+			"Ending": st.Final != nil,
+		}
+		if st.Label != "" {
+			m["Label"] = st.Label
+		}
+		if st.X != nil && st.Y != nil {
+			m["Meta"] = map[string]interface{}{"X": *st.X, "Y": *st.Y}
+		}
+		if st.Initial != nil {
+			if _, ok := doc["Start"]; ok {
+				return nil, errors.New("invalid JFLAP file: more than one initial state")
+			}
+			doc["Start"] = ids[st.Id]
+		}
+		states = append(states, m)
+	}
+	if _, ok := doc["Start"]; !ok {
+		return nil, errors.New("invalid JFLAP file: there is no initial state")
+	}
+
+	var alphabet machine.Alphabet
+	transitions := make([]interface{}, 0, len(s.Transitions))
+	for _, t := range s.Transitions {
+		from, ok := ids[t.From]
+		to, ok2 := ids[t.To]
+		if !ok || !ok2 {
+			return nil, fmt.Errorf(
+				"invalid JFLAP file: transition %v -> %v uses an unknown state",
+				t.From, t.To)
+		}
+		m := map[string]interface{}{"Start": from, "End": to, "Symbol": t.Read}
+		optional(m, "Pop", t.Pop)
+		optional(m, "Push", t.Push)
+		optional(m, "Write", t.Write)
+		optional(m, "Move", t.Move)
+		transitions = append(transitions, m)
+		for _, r := range t.Read {
+			if !alphabet.Contains(string(r)) {
+				alphabet = append(alphabet, string(r))
+			}
+		}
+	}
+
+	doc["States"] = states
+	doc["Transitions"] = transitions
+	if machineType != machine.TM && len(alphabet) > 0 {
+		doc["Alphabet"] = alphabet.JsonValue()
+	}
+	return doc, nil
+}
+
+// A finite automaton is a DFA if it never reads nothing or more than one
+// character at a time, and never has two transitions from a state on the same
+// character. JFLAP DFAs may be partial, which can be fixed on load with the
+// 'Complete' flag
+func finiteAutomatonType(transitions []transition) string {
+	type choice struct{ from, read string }
+	seen := make(map[choice]bool, len(transitions))
+	for _, t := range transitions {
+		c := choice{t.From, t.Read}
+		if utf8.RuneCountInString(t.Read) != 1 || seen[c] {
+			return machine.NFA
+		}
+		seen[c] = true
+	}
+	return machine.DFA
+}
+
+// Picks an id for each JFLAP state. The names of the states are used, unless
+// two states have the same name, in which case the numeric ids are used
+func stateIds(states []state) map[string]string {
+	ids := make(map[string]string, len(states))
+	names := make(map[string]bool, len(states))
+	unique := true
+	for _, s := range states {
+		if s.Name == "" || names[s.Name] {
+			unique = false
+		}
+		names[s.Name] = true
+	}
+	for _, s := range states {
+		if unique {
+			ids[s.Id] = s.Name
+		} else {
+			ids[s.Id] = "q" + s.Id
+		}
+	}
+	return ids
+}
+
+func optional(m map[string]interface{}, key string, value *string) {
+	if value != nil {
+		m[key] = *value
+	}
+}
@@ -0,0 +1,205 @@
+package jflap
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleFiles = []string{
+	"testdata/odd-a.jff",
+	"testdata/ends-with-ab.jff",
+	"testdata/anbn.jff",
+	"testdata/flip-bits.jff",
+}
+
+func TestImportDFA(t *testing.T) {
+	doc := mustImport(t, "testdata/odd-a.jff")
+	assert.Equal(t, map[string]interface{}{
+		"SchemaVersion": 1.0,
+		"Type":          "DFA",
+		"Alphabet":      "ab",
+		"Start":         "even",
+		"States": []interface{}{
+			map[string]interface{}{
+				"Id":     "even",
+				"Ending": false,
+				"Meta":   map[string]interface{}{"X": 84.0, "Y": 122.0},
+			},
+			map[string]interface{}{
+				"Id":     "odd",
+				"Ending": true,
+				"Label":  "odd number of a's",
+				"Meta":   map[string]interface{}{"X": 222.0, "Y": 122.0},
+			},
+		},
+		"Transitions": []interface{}{
+			map[string]interface{}{"Start": "even", "End": "odd", "Symbol": "a"},
+			map[string]interface{}{"Start": "even", "End": "even", "Symbol": "b"},
+			map[string]interface{}{"Start": "odd", "End": "even", "Symbol": "a"},
+			map[string]interface{}{"Start": "odd", "End": "odd", "Symbol": "b"},
+		},
+	}, doc)
+
+	m, err := automata.Load(doc)
+	assert.NoError(t, err)
+	assert.True(t, simulation.ResultOf(m.Simulate("bab")).Accepted)
+	assert.False(t, simulation.ResultOf(m.Simulate("aba")).Accepted)
+}
+
+func TestImportOtherTypes(t *testing.T) {
+	for _, tc := range []struct {
+		file       string
+		typ        string
+		transition map[string]interface{}
+	}{
+		{
+			file: "testdata/ends-with-ab.jff",
+			typ:  machine.NFA,
+			transition: map[string]interface{}{
+				"Start": "q0", "End": "q1", "Symbol": "",
+			},
+		},
+		{
+			file: "testdata/anbn.jff",
+			typ:  machine.PDA,
+			transition: map[string]interface{}{
+				"Start": "q0", "End": "q0", "Symbol": "a", "Pop": "", "Push": "A",
+			},
+		},
+		{
+			file: "testdata/flip-bits.jff",
+			typ:  machine.TM,
+			transition: map[string]interface{}{
+				"Start": "scan", "End": "scan", "Symbol": "0", "Write": "1", "Move": "R",
+			},
+		},
+	} {
+		doc := mustImport(t, tc.file)
+		assert.Equal(t, tc.typ, doc["Type"], tc.file)
+		assert.Contains(t, doc["Transitions"], tc.transition, tc.file)
+	}
+}
+
+// Exporting an imported file and importing it again gives the same document
+func TestRoundTrip(t *testing.T) {
+	for _, file := range sampleFiles {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			t.Parallel()
+			doc := mustImport(t, file)
+			data, err := ExportDocument(doc)
+			assert.NoError(t, err)
+			again, err := Import(bytes.NewReader(data))
+			assert.NoError(t, err)
+			assert.Equal(t, doc, again)
+		})
+	}
+}
+
+// Symbol classes and "otherwise" transitions are expanded, and states without
+// positions are laid out on a grid
+func TestExportLoadedMachine(t *testing.T) {
+	m, err := automata.Load([]byte(`
+	{
+		"Type": "DFA",
+		"Alphabet": "abc1",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }, { "Id": "trap" }],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "[a-c]" },
+		  { "Start": "q0", "End": "trap", "Otherwise": true },
+		  { "Start": "q1", "End": "q1", "Symbol": "[a-c],1" },
+		  { "Start": "trap", "End": "trap", "Otherwise": true }
+		]
+	}`))
+	assert.NoError(t, err)
+
+	data, err := Export(m)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "<x>400</x>")
+
+	doc, err := Import(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, machine.DFA, doc["Type"])
+	assert.Len(t, doc["Transitions"], 12)
+
+	imported, err := automata.Load(doc)
+	assert.NoError(t, err)
+	for _, tape := range []string{"", "a", "c1b", "1a", "ab1c"} {
+		assert.Equal(t,
+			simulation.ResultOf(m.Simulate(tape)).Accepted,
+			simulation.ResultOf(imported.Simulate(tape)).Accepted,
+			"tape: %v", tape)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unknown-type": `{"Type": "Mealy", "Start": "q0", "States": [], "Transitions": []}`,
+		"multi-character-symbol": `{
+			"Type": "DFA",
+			"Alphabet": ["ab"],
+			"Start": "q0",
+			"States": [{ "Id": "q0" }],
+			"Transitions": [{ "Start": "q0", "End": "q0", "Symbol": "ab" }]
+		}`,
+		"otherwise-without-alphabet": `{
+			"Type": "NFA",
+			"Start": "q0",
+			"States": [{ "Id": "q0" }],
+			"Transitions": [{ "Start": "q0", "End": "q0", "Otherwise": true }]
+		}`,
+	} {
+		m, err := machine.LoadMap([]byte(doc))
+		assert.NoError(t, err, name)
+		_, err = ExportDocument(m)
+		assert.True(t, errors.Is(err, ErrUnsupported), "%v: %v", name, err)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	for name, file := range map[string]string{
+		"not-xml":      "{}",
+		"mealy":        "<structure><type>mealy</type></structure>",
+		"multi-tape":   "<structure><type>turing</type><tapes>2</tapes></structure>",
+		"no-initial":   `<structure><type>fa</type><state id="0" name="q0"/></structure>`,
+		"blocks":       `<structure><type>turing</type><automaton><block id="0"/></automaton></structure>`,
+		"unknown-from": `<structure><type>fa</type><state id="0" name="q0"><initial/></state><transition><from>1</from><to>0</to><read>a</read></transition></structure>`,
+	} {
+		_, err := Import(strings.NewReader(file))
+		assert.Error(t, err, name)
+	}
+}
+
+func mustImport(t *testing.T, file string) map[string]interface{} {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := Import(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// Positions survive loading the imported document as a machine
+func TestExportKeepsPositionsOfLoadedMachines(t *testing.T) {
+	doc := mustImport(t, "testdata/odd-a.jff")
+	m, err := automata.Load(doc)
+	assert.NoError(t, err)
+	data, err := Export(m)
+	assert.NoError(t, err)
+	again, err := Import(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, doc["States"], again["States"])
+}
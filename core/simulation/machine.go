@@ -1,8 +1,43 @@
 package simulation
 
-import "github.com/flapflapio/simulator/core/simulation/machine"
+import (
+	"io"
+
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
 
 type Machine interface {
 	machine.Marshalable
 	Simulate(input string) Simulation
 }
+
+// Machines that can check themselves for likely mistakes
+type Linter interface {
+	Lint() machine.Diagnostics
+}
+
+// Simulations that record the transitions they take, so that test suites can
+// tell which parts of a machine they exercise
+type TransitionTracer interface {
+	Simulation
+
+	// The index (in the machine) of every transition taken so far, each listed
+	// once, in increasing order
+	Transitions() []int
+}
+
+// Machines whose simulations can record the transitions they take. Plain
+// simulations do not, as recording adds to the cost of every step
+type TracingMachine interface {
+	Machine
+	SimulateTraced(input string) TransitionTracer
+}
+
+// Machines that can read their input incrementally, so that inputs too large
+// to hold in memory can be simulated. Simulations of a stream do not record
+// the full path, their results hold only the final state and the number of
+// symbols that were read (see `Result.Steps`)
+type StreamMachine interface {
+	Machine
+	SimulateStream(input io.Reader) Simulation
+}
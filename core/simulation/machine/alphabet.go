@@ -0,0 +1,154 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The symbols accepted by a machine. A symbol is any non-empty string, so
+// symbols may span several characters e.g. ["0", "1", "10"] or ["if", "else"]
+type Alphabet []string
+
+// Creates an alphabet where every character (rune) of `s` is a symbol
+func RunesAlphabet(s string) Alphabet {
+	a := make(Alphabet, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		a = append(a, string(r))
+	}
+	return a
+}
+
+// Parses the 'Alphabet' field of a machine document, which is either a string
+// (every character is a symbol) or a list of symbols
+func ParseAlphabet(unknown interface{}) (Alphabet, error) {
+	var a Alphabet
+	switch v := unknown.(type) {
+	case string:
+		a = RunesAlphabet(v)
+	case []interface{}:
+		var diags Diagnostics
+		for i, s := range v {
+			symbol, ok := s.(string)
+			if !ok || symbol == "" {
+				diags = append(diags, Errorf(
+					Pointer("Alphabet", i), CodeInvalidDocument,
+					"alphabet symbols must be non-empty strings, got '%v'", s))
+				continue
+			}
+			a = append(a, symbol)
+		}
+		if len(diags) > 0 {
+			return nil, diags
+		}
+	default:
+		return nil, Diagnostics{Errorf(
+			Pointer("Alphabet"), CodeInvalidDocument,
+			"'Alphabet' field in json document is not valid "+
+				"- it should be a string or a list of strings")}
+	}
+
+	seen := make(map[string]bool, len(a))
+	for i, s := range a {
+		if seen[s] {
+			return nil, Diagnostics{Errorf(
+				Pointer("Alphabet", i), CodeInvalidDocument,
+				"symbol '%v' appears more than once in the alphabet", s)}
+		}
+		seen[s] = true
+	}
+	return a, nil
+}
+
+func (a Alphabet) Contains(symbol string) bool {
+	for _, s := range a {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether every symbol of the alphabet is a single character
+func (a Alphabet) IsRunes() bool {
+	for _, s := range a {
+		if utf8.RuneCountInString(s) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// The value of the 'Alphabet' field of a machine document: a string if every
+// symbol is a single character, otherwise a list of symbols
+func (a Alphabet) JsonValue() interface{} {
+	if a.IsRunes() {
+		return strings.Join(a, "")
+	}
+	symbols := make([]interface{}, len(a))
+	for i, s := range a {
+		symbols[i] = s
+	}
+	return symbols
+}
+
+func (a Alphabet) String() string {
+	return fmt.Sprintf("%v", []string(a))
+}
+
+// Splits an input tape into the symbols of an alphabet. Without a separator,
+// the tape is tokenized by longest match against the alphabet. With a
+// separator, every symbol on the tape is followed by the separator (except
+// maybe the last one) e.g. "if else if" with separator " "
+type Tokenizer struct {
+	symbols   map[string]bool
+	maxLen    int
+	separator string
+}
+
+func NewTokenizer(alphabet Alphabet, separator string) *Tokenizer {
+	t := &Tokenizer{
+		symbols:   make(map[string]bool, len(alphabet)),
+		separator: separator,
+	}
+	for _, s := range alphabet {
+		t.symbols[s] = true
+		if len(s) > t.maxLen {
+			t.maxLen = len(s)
+		}
+	}
+	return t
+}
+
+// Reads the next symbol from the front of `input`. Returns the symbol and the
+// number of bytes of `input` that it (and its separator) occupies. If `input`
+// does not start with a symbol of the alphabet, then `ok` is false and the
+// returned symbol is the unrecognized token (the first character of `input`
+// when there is no separator)
+func (t *Tokenizer) Next(input string) (symbol string, width int, ok bool) {
+	if input == "" {
+		return "", 0, false
+	}
+
+	if t.separator != "" {
+		symbol = input
+		width = len(input)
+		if i := strings.Index(input, t.separator); i >= 0 {
+			symbol = input[:i]
+			width = i + len(t.separator)
+		}
+		return symbol, width, t.symbols[symbol]
+	}
+
+	l := t.maxLen
+	if l > len(input) {
+		l = len(input)
+	}
+	for ; l > 0; l-- {
+		if t.symbols[input[:l]] {
+			return input[:l], l, true
+		}
+	}
+	_, size := utf8.DecodeRuneInString(input)
+	return input[:size], size, false
+}
@@ -0,0 +1,114 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAlphabet(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		unknown  interface{}
+		expected Alphabet
+		pointer  string
+	}{
+		{name: "string", unknown: "ab", expected: Alphabet{"a", "b"}},
+		{name: "unicode-string", unknown: "éa€", expected: Alphabet{"é", "a", "€"}},
+		{
+			name:     "list",
+			unknown:  []interface{}{"if", "else", "x"},
+			expected: Alphabet{"if", "else", "x"},
+		},
+		{name: "empty-symbol", unknown: []interface{}{"a", ""}, pointer: "/Alphabet/1"},
+		{name: "not-a-string", unknown: []interface{}{"a", 2.0}, pointer: "/Alphabet/1"},
+		{name: "duplicates", unknown: "aba", pointer: "/Alphabet/2"},
+		{name: "wrong-type", unknown: 25.0, pointer: "/Alphabet"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			a, err := ParseAlphabet(tc.unknown)
+			if tc.pointer != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.pointer, DiagnosticsOf(err)[0].Pointer)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, a)
+		})
+	}
+}
+
+func TestAlphabetJsonValue(t *testing.T) {
+	assert.Equal(t, "aé", RunesAlphabet("aé").JsonValue())
+	assert.Equal(t,
+		[]interface{}{"0", "1", "10"},
+		Alphabet{"0", "1", "10"}.JsonValue())
+}
+
+func TestTokenizer(t *testing.T) {
+	type token struct {
+		symbol string
+		width  int
+		ok     bool
+	}
+	for _, tc := range []struct {
+		name      string
+		alphabet  Alphabet
+		separator string
+		input     string
+		expected  []token
+	}{
+		{
+			name:     "longest-match",
+			alphabet: Alphabet{"0", "1", "10"},
+			input:    "1001",
+			expected: []token{{"10", 2, true}, {"0", 1, true}, {"1", 1, true}},
+		},
+		{
+			name:     "unicode",
+			alphabet: RunesAlphabet("éa"),
+			input:    "aéx",
+			expected: []token{{"a", 1, true}, {"é", 2, true}, {"x", 1, false}},
+		},
+		{
+			name:     "unknown-multibyte-character",
+			alphabet: RunesAlphabet("a"),
+			input:    "€a",
+			expected: []token{{"€", 3, false}},
+		},
+		{
+			name:      "separator",
+			alphabet:  Alphabet{"if", "else", "x"},
+			separator: " ",
+			input:     "if x else x",
+			expected: []token{
+				{"if", 3, true}, {"x", 2, true}, {"else", 5, true}, {"x", 1, true},
+			},
+		},
+		{
+			name:      "separator-unknown-token",
+			alphabet:  Alphabet{"if", "else"},
+			separator: ",",
+			input:     "if,iff",
+			expected:  []token{{"if", 3, true}, {"iff", 3, false}},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tk := NewTokenizer(tc.alphabet, tc.separator)
+			input := tc.input
+			for _, expected := range tc.expected {
+				symbol, width, ok := tk.Next(input)
+				assert.Equal(t, expected, token{symbol, width, ok})
+				if !ok {
+					return
+				}
+				input = input[width:]
+			}
+			assert.Equal(t, "", input)
+		})
+	}
+}
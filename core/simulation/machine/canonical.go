@@ -0,0 +1,479 @@
+package machine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Fields that do not change what a machine does, and are left out of its
+// canonical form
+var nonCanonicalFields = []string{"Meta", "Trap"}
+
+// A copy of a machine document where everything that does not change what the
+// machine does is normalized away, so that machines that only differ in the
+// names of their states have the same canonical form:
+//
+//   - States are renamed "q0", "q1", ... in breadth first order from the start
+//     state, following the transitions leaving each state ordered by what
+//     they read and do. States that cannot be reached come last, numbered in
+//     the same way from roots that are chosen by what can be reached from
+//     them rather than by their names
+//   - Transitions are sorted, and fields with default values are left out
+//   - In DFAs, where a state takes the first of its transitions that reads a
+//     symbol, transitions that read a symbol that another transition of their
+//     state also reads get a 'Precedence': their order in the document among
+//     such transitions of their state
+//   - Alphabets are sorted lists of symbols
+//   - 'Meta', 'Label' and 'Trap' are left out
+//
+// The breadth first order is only independent of the names of states when no
+// two transitions leaving a state are alike but for where they go, which the
+// precedence ensures for DFAs. Use `Isomorphic` to compare other machines
+func Canonical(document map[string]interface{}) (map[string]interface{}, error) {
+	canonical, _, err := canonicalize(document)
+	return canonical, err
+}
+
+// The name that each state of a machine has in its canonical form (see
+// `Canonical`), so that results about the canonical machine can be translated
+// back to the machine
+func CanonicalNames(document map[string]interface{}) (map[string]string, error) {
+	_, names, err := canonicalize(document)
+	return names, err
+}
+
+func canonicalize(document map[string]interface{}) (map[string]interface{}, map[string]string, error) {
+	doc, err := LoadMap(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := Load(doc); err != nil {
+		return nil, nil, err
+	}
+
+	canonical := map[string]interface{}{}
+	for k, v := range doc {
+		canonical[k] = copyValue(v)
+	}
+	for _, f := range nonCanonicalFields {
+		delete(canonical, f)
+	}
+	for field := range alphabetFields {
+		if unknown, ok := canonical[field]; ok {
+			a, err := ParseAlphabet(unknown)
+			if err != nil {
+				return nil, nil, err
+			}
+			symbols := append([]string{}, a...)
+			sort.Strings(symbols)
+			list := make([]interface{}, len(symbols))
+			for i, s := range symbols {
+				list[i] = s
+			}
+			canonical[field] = list
+		}
+	}
+
+	states := ObjectList(doc["States"])
+	transitions := make([]map[string]interface{}, 0)
+	for _, t := range ObjectList(doc["Transitions"]) {
+		transitions = append(transitions, canonicalTransition(t))
+	}
+	if doc["Type"] == DFA {
+		addPrecedence(doc, transitions)
+	}
+	names := renameStates(doc["Start"].(string), states, transitions)
+
+	canonicalStates := make([]interface{}, len(states))
+	for _, s := range states {
+		id := s["Id"].(string)
+		state := map[string]interface{}{"Id": stateName(names[id])}
+		if s["Ending"] == true {
+			state["Ending"] = true
+		}
+		canonicalStates[names[id]] = state
+	}
+	numbered := make([]numberedTransition, len(transitions))
+	for i, t := range transitions {
+		numbered[i] = numberedTransition{
+			start:      names[t["Start"].(string)],
+			end:        names[t["End"].(string)],
+			label:      transitionLabel(t),
+			transition: t,
+		}
+		t["Start"] = stateName(numbered[i].start)
+		t["End"] = stateName(numbered[i].end)
+	}
+	sort.SliceStable(numbered, func(i, j int) bool {
+		return numbered[i].less(numbered[j])
+	})
+	canonicalTransitions := make([]interface{}, len(numbered))
+	for i, t := range numbered {
+		canonicalTransitions[i] = t.transition
+	}
+
+	canonical["Start"] = stateName(0)
+	canonical["States"] = canonicalStates
+	canonical["Transitions"] = canonicalTransitions
+
+	renamed := make(map[string]string, len(names))
+	for id, i := range names {
+		renamed[id] = stateName(i)
+	}
+	return canonical, renamed, nil
+}
+
+// A hash of the canonical form of a machine (see `Canonical`), as a hex
+// encoded SHA-256 digest. Machines that only differ in the names or layout of
+// their states have the same hash
+func Hash(document map[string]interface{}) (string, error) {
+	hash, _, err := HashWithNames(document)
+	return hash, err
+}
+
+// The hash of a machine and the canonical names of its states (see `Hash` and
+// `CanonicalNames`), from a single canonicalization of the machine
+func HashWithNames(document map[string]interface{}) (string, map[string]string, error) {
+	canonical, names, err := canonicalize(document)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := json.Marshal(canonical) // Map keys are sorted
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), names, nil
+}
+
+// Whether two machines are the same up to the names of their states: there is
+// a one to one mapping between their states that maps start state to start
+// state, ending states to ending states, and the transitions of one machine
+// onto those of the other
+func Isomorphic(a, b map[string]interface{}) (bool, error) {
+	ca, err := Canonical(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := Canonical(b)
+	if err != nil {
+		return false, err
+	}
+	if reflect.DeepEqual(ca, cb) {
+		return true, nil
+	}
+
+	// Everything but the states and transitions must be the same
+	for k := range ca {
+		if k != "States" && k != "Transitions" && !reflect.DeepEqual(ca[k], cb[k]) {
+			return false, nil
+		}
+	}
+	for k := range cb {
+		if _, ok := ca[k]; !ok {
+			return false, nil
+		}
+	}
+	return newMatcher(ca, cb).match(), nil
+}
+
+// Leaves out 'Meta' and the fields of a transition that have default values
+func canonicalTransition(t map[string]interface{}) map[string]interface{} {
+	c := withoutMeta(t)
+	for k, v := range c {
+		if v == false || (v == "" && k != "Symbol") {
+			delete(c, k)
+		}
+	}
+	if c["Otherwise"] == true && c["Symbol"] == "" {
+		delete(c, "Symbol")
+	}
+	return c
+}
+
+// Numbers the transitions of a DFA that read a symbol that another transition
+// of their state also reads (see `Canonical`), counting "otherwise"
+// transitions as reading the same symbols as each other and none of the
+// symbols of the other transitions
+func addPrecedence(doc map[string]interface{}, transitions []map[string]interface{}) {
+	alphabet, _ := ParseAlphabet(doc["Alphabet"])
+	reads := make([]map[string]bool, len(transitions))
+	byState := map[string][]int{}
+	for i, t := range transitions {
+		reads[i] = symbolsRead(t, alphabet)
+		id := t["Start"].(string)
+		byState[id] = append(byState[id], i)
+	}
+
+	for _, indices := range byState {
+		// How many transitions of the state read each symbol, where "otherwise"
+		// transitions are counted under the empty symbol
+		readers := map[string]int{}
+		for _, i := range indices {
+			if reads[i] == nil {
+				readers[""]++
+			}
+			for s := range reads[i] {
+				readers[s]++
+			}
+		}
+		precedence := 0
+		for _, i := range indices {
+			overlaps := reads[i] == nil && readers[""] > 1
+			for s := range reads[i] {
+				overlaps = overlaps || readers[s] > 1
+			}
+			if overlaps {
+				transitions[i]["Precedence"] = float64(precedence)
+				precedence++
+			}
+		}
+	}
+}
+
+// The symbols of the alphabet that a DFA transition reads, or nil for an
+// "otherwise" transition. Symbols that are neither in the alphabet nor valid
+// classes are read literally, like `dfa.DFA` does
+func symbolsRead(t map[string]interface{}, alphabet Alphabet) map[string]bool {
+	if t["Otherwise"] == true {
+		return nil
+	}
+	symbol, _ := t["Symbol"].(string)
+	if !alphabet.Contains(symbol) && IsSymbolClass(symbol) {
+		if class, err := ParseSymbolClass(symbol); err == nil {
+			read := map[string]bool{}
+			for _, s := range alphabet {
+				if class.Contains(s) {
+					read[s] = true
+				}
+			}
+			return read
+		}
+	}
+	return map[string]bool{symbol: true}
+}
+
+// What a transition reads and does, ignoring where it starts and ends
+func transitionLabel(t map[string]interface{}) string {
+	return transitionKey(t, []string{"Start", "End"})
+}
+
+// Numbers the states in breadth first order from the start state. The states
+// that cannot be reached are then numbered in the same way, from one root at
+// a time: the root whose search (see `search`) comes first, so that the
+// numbers do not depend on the names or the order of the states
+func renameStates(
+	start string,
+	states []map[string]interface{},
+	transitions []map[string]interface{},
+) map[string]int {
+	outgoing := map[string][]map[string]interface{}{}
+	for _, t := range transitions {
+		id := t["Start"].(string)
+		outgoing[id] = append(outgoing[id], t)
+	}
+	for _, ts := range outgoing {
+		sort.SliceStable(ts, func(i, j int) bool {
+			return transitionLabel(ts[i]) < transitionLabel(ts[j])
+		})
+	}
+	ending := make(map[string]bool, len(states))
+	for _, s := range states {
+		ending[s["Id"].(string)] = s["Ending"] == true
+	}
+
+	names := map[string]int{}
+	number := func(order []string) {
+		for _, id := range order {
+			names[id] = len(names)
+		}
+	}
+	_, order := search(start, names, outgoing, ending)
+	number(order)
+	for len(names) < len(states) {
+		var bestKey string
+		var best []string
+		for _, s := range states {
+			id := s["Id"].(string)
+			if _, ok := names[id]; ok {
+				continue
+			}
+			if key, order := search(id, names, outgoing, ending); best == nil || key < bestKey {
+				bestKey, best = key, order
+			}
+		}
+		number(best)
+	}
+	return names
+}
+
+// A breadth first search from `root` over the states that have no number yet.
+// Returns the states in the order they were found, and a key that describes
+// what was found without the names of the states: whether each state is an
+// ending state, and where its transitions go, as a number for states that have
+// one and otherwise as the position in the search
+func search(
+	root string,
+	names map[string]int,
+	outgoing map[string][]map[string]interface{},
+	ending map[string]bool,
+) (string, []string) {
+	var key strings.Builder
+	found := map[string]int{root: 0}
+	order := []string{root}
+	for i := 0; i < len(order); i++ {
+		fmt.Fprintf(&key, "%v:", ending[order[i]])
+		for _, t := range outgoing[order[i]] {
+			end := t["End"].(string)
+			if n, ok := names[end]; ok {
+				fmt.Fprintf(&key, "%q=%v,", transitionLabel(t), n)
+				continue
+			}
+			if _, ok := found[end]; !ok {
+				found[end] = len(order)
+				order = append(order, end)
+			}
+			fmt.Fprintf(&key, "%q~%v,", transitionLabel(t), found[end])
+		}
+		key.WriteString(";")
+	}
+	return key.String(), order
+}
+
+func stateName(i int) string {
+	return fmt.Sprintf("q%v", i)
+}
+
+// A canonical transition, with the numbers of the states it starts and ends at
+type numberedTransition struct {
+	start, end int
+	label      string
+	transition map[string]interface{}
+}
+
+// Orders canonical transitions by start state, end state and label
+func (a numberedTransition) less(b numberedTransition) bool {
+	if a.start != b.start {
+		return a.start < b.start
+	}
+	if a.end != b.end {
+		return a.end < b.end
+	}
+	return a.label < b.label
+}
+
+// Searches for a mapping between the states of two canonical machines, see
+// `Isomorphic`
+type matcher struct {
+	a, b             canonicalGraph
+	mapping, reverse []int
+}
+
+// The states of a canonical machine are numbered by their names, and
+// `edges[{from, to}][label]` counts the transitions between two states
+type canonicalGraph struct {
+	ending    []bool
+	edges     map[[2]int]map[string]int
+	signature []string
+}
+
+func newCanonicalGraph(doc map[string]interface{}) canonicalGraph {
+	states := doc["States"].([]interface{})
+	g := canonicalGraph{
+		ending:    make([]bool, len(states)),
+		edges:     map[[2]int]map[string]int{},
+		signature: make([]string, len(states)),
+	}
+	for i, s := range states {
+		g.ending[i] = s.(map[string]interface{})["Ending"] == true
+	}
+	out := make([][]string, len(states))
+	in := make([][]string, len(states))
+	for _, unknown := range doc["Transitions"].([]interface{}) {
+		t := unknown.(map[string]interface{})
+		var from, to int
+		fmt.Sscanf(t["Start"].(string), "q%d", &from)
+		fmt.Sscanf(t["End"].(string), "q%d", &to)
+		label := transitionLabel(t)
+		if g.edges[[2]int{from, to}] == nil {
+			g.edges[[2]int{from, to}] = map[string]int{}
+		}
+		g.edges[[2]int{from, to}][label]++
+		out[from] = append(out[from], label)
+		in[to] = append(in[to], label)
+	}
+
+	// States can only be mapped onto states with the same signature
+	for i := range states {
+		sort.Strings(out[i])
+		sort.Strings(in[i])
+		data, _ := json.Marshal([]interface{}{g.ending[i], out[i], in[i]})
+		g.signature[i] = string(data)
+	}
+	return g
+}
+
+func newMatcher(a, b map[string]interface{}) *matcher {
+	m := &matcher{a: newCanonicalGraph(a), b: newCanonicalGraph(b)}
+	m.mapping = make([]int, len(m.a.ending))
+	m.reverse = make([]int, len(m.b.ending))
+	for i := range m.mapping {
+		m.mapping[i] = -1
+	}
+	for i := range m.reverse {
+		m.reverse[i] = -1
+	}
+	return m
+}
+
+// Maps the states of `a` in order, start state first, backtracking when a
+// mapping turns out to be inconsistent
+func (m *matcher) match() bool {
+	if len(m.a.ending) != len(m.b.ending) {
+		return false
+	}
+	if len(m.a.ending) == 0 {
+		return true
+	}
+	return m.assign(0)
+}
+
+func (m *matcher) assign(state int) bool {
+	if state == len(m.mapping) {
+		return true
+	}
+	for candidate := range m.reverse {
+		if m.reverse[candidate] >= 0 || (state == 0) != (candidate == 0) {
+			continue
+		}
+		if m.a.signature[state] != m.b.signature[candidate] {
+			continue
+		}
+		m.mapping[state], m.reverse[candidate] = candidate, state
+		if m.consistent(state) && m.assign(state+1) {
+			return true
+		}
+		m.mapping[state], m.reverse[candidate] = -1, -1
+	}
+	return false
+}
+
+// Whether the transitions between `state` and the states mapped so far are
+// mapped onto transitions of `b`
+func (m *matcher) consistent(state int) bool {
+	for other := 0; other <= state; other++ {
+		pairs := [][2]int{{state, other}, {other, state}}
+		for _, p := range pairs {
+			mapped := [2]int{m.mapping[p[0]], m.mapping[p[1]]}
+			if !reflect.DeepEqual(m.a.edges[p], m.b.edges[mapped]) {
+				return false
+			}
+		}
+	}
+	return true
+}
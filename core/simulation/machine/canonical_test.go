@@ -0,0 +1,300 @@
+package machine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const oddA = `{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [{ "Id": "q0", "Ending": false }, { "Id": "q1", "Ending": true }],
+	"Transitions": [
+		{ "Start": "q0", "End": "q1", "Symbol": "a" },
+		{ "Start": "q0", "End": "q0", "Symbol": "b" },
+		{ "Start": "q1", "End": "q1", "Symbol": "b" },
+		{ "Start": "q1", "End": "q0", "Symbol": "a" }
+	]
+}`
+
+// The same machine as `oddA`, with other names, labels and layout
+const oddARenamed = `{
+	"Type": "DFA",
+	"Alphabet": ["b", "a"],
+	"Meta": { "Author": "someone" },
+	"Start": "even",
+	"States": [
+		{ "Id": "odd", "Ending": true, "Label": "Odd", "Meta": { "X": 10 } },
+		{ "Id": "even" }
+	],
+	"Transitions": [
+		{ "Start": "odd", "End": "even", "Symbol": "a" },
+		{ "Start": "even", "End": "even", "Symbol": "b", "Otherwise": false },
+		{ "Start": "even", "End": "odd", "Symbol": "a", "Meta": { "Bend": 2 } },
+		{ "Start": "odd", "End": "odd", "Symbol": "b" }
+	]
+}`
+
+// Accepts an even number of a's
+const evenA = `{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [{ "Id": "q0", "Ending": true }, { "Id": "q1" }],
+	"Transitions": [
+		{ "Start": "q0", "End": "q1", "Symbol": "a" },
+		{ "Start": "q0", "End": "q0", "Symbol": "b" },
+		{ "Start": "q1", "End": "q1", "Symbol": "b" },
+		{ "Start": "q1", "End": "q0", "Symbol": "a" }
+	]
+}`
+
+// Ties between the transitions leaving the start state, so that the breadth
+// first order depends on the names of the states
+const nfaTie = `{
+	"Type": "NFA",
+	"Start": "s",
+	"States": [{ "Id": "s" }, { "Id": "x" }, { "Id": "y", "Ending": true }],
+	"Transitions": [
+		{ "Start": "s", "End": "x", "Symbol": "a" },
+		{ "Start": "s", "End": "y", "Symbol": "a" },
+		{ "Start": "y", "End": "y", "Symbol": "" }
+	]
+}`
+
+const nfaTieSwapped = `{
+	"Type": "NFA",
+	"Start": "s",
+	"States": [{ "Id": "s" }, { "Id": "x", "Ending": true }, { "Id": "y" }],
+	"Transitions": [
+		{ "Start": "s", "End": "x", "Symbol": "a" },
+		{ "Start": "s", "End": "y", "Symbol": "a" },
+		{ "Start": "x", "End": "x", "Symbol": "" }
+	]
+}`
+
+// States that cannot be reached, in two orders and with two sets of names
+const unreachable = `{
+	"Type": "DFA",
+	"Alphabet": "a",
+	"Start": "s",
+	"States": [{ "Id": "s" }, { "Id": "x", "Ending": true }, { "Id": "y" }],
+	"Transitions": [
+		{ "Start": "s", "End": "s", "Symbol": "a" },
+		{ "Start": "x", "End": "y", "Symbol": "a" },
+		{ "Start": "y", "End": "y", "Symbol": "a" }
+	]
+}`
+
+const unreachableReordered = `{
+	"Type": "DFA",
+	"Alphabet": "a",
+	"Start": "start",
+	"States": [{ "Id": "b" }, { "Id": "start" }, { "Id": "a", "Ending": true }],
+	"Transitions": [
+		{ "Start": "b", "End": "b", "Symbol": "a" },
+		{ "Start": "start", "End": "start", "Symbol": "a" },
+		{ "Start": "a", "End": "b", "Symbol": "a" }
+	]
+}`
+
+func mustParse(t *testing.T, doc string) map[string]interface{} {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCanonical(t *testing.T) {
+	canonical, err := Canonical(mustParse(t, oddARenamed))
+	assert.NoError(t, err)
+	actual, _ := json.Marshal(canonical)
+	assert.JSONEq(t, `{
+		"SchemaVersion": 1,
+		"Type": "DFA",
+		"Alphabet": ["a", "b"],
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+			{ "Start": "q0", "End": "q0", "Symbol": "b" },
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q1", "End": "q0", "Symbol": "a" },
+			{ "Start": "q1", "End": "q1", "Symbol": "b" }
+		]
+	}`, string(actual))
+
+	names, err := CanonicalNames(mustParse(t, oddARenamed))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"even": "q0", "odd": "q1"}, names)
+
+	_, err = Canonical(mustParse(t, `{ "Type": "DFA", "Start": "q9", "States": [], "Transitions": [] }`))
+	assert.Error(t, err)
+}
+
+func TestHash(t *testing.T) {
+	hash := func(doc string) string {
+		h, err := Hash(mustParse(t, doc))
+		assert.NoError(t, err)
+		return h
+	}
+	assert.Regexp(t, "^[0-9a-f]{64}$", hash(oddA))
+	assert.Equal(t, hash(oddA), hash(oddARenamed))
+	assert.NotEqual(t, hash(oddA), hash(evenA))
+	assert.Equal(t, hash(unreachable), hash(unreachableReordered))
+
+	h, names, err := HashWithNames(mustParse(t, unreachableReordered))
+	assert.NoError(t, err)
+	assert.Equal(t, hash(unreachable), h)
+	assert.Equal(t, map[string]string{"start": "q0", "b": "q1", "a": "q2"}, names)
+}
+
+func TestIsomorphic(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		a, b       string
+		isomorphic bool
+	}{
+		{"renamed", oddA, oddARenamed, true},
+		{"different-ending-states", oddA, evenA, false},
+		{"nfa-tie", nfaTie, nfaTieSwapped, true},
+		{"different-types", nfaTie, oddA, false},
+		{
+			name: "different-alphabets",
+			a:    oddA,
+			b: `{
+				"Type": "DFA",
+				"Alphabet": "abc",
+				"Complete": true,
+				"Start": "q0",
+				"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+				"Transitions": [
+					{ "Start": "q0", "End": "q1", "Symbol": "a" },
+					{ "Start": "q0", "End": "q0", "Symbol": "b" },
+					{ "Start": "q1", "End": "q1", "Symbol": "b" },
+					{ "Start": "q1", "End": "q0", "Symbol": "a" }
+				]
+			}`,
+			isomorphic: false,
+		},
+		{
+			name: "same-signatures-different-wiring",
+			a: `{
+				"Type": "NFA",
+				"Start": "s",
+				"States": [{ "Id": "s" }, { "Id": "x" }, { "Id": "y" }, { "Id": "z" }],
+				"Transitions": [
+					{ "Start": "s", "End": "x", "Symbol": "a" },
+					{ "Start": "s", "End": "y", "Symbol": "a" },
+					{ "Start": "x", "End": "z", "Symbol": "b" },
+					{ "Start": "y", "End": "y", "Symbol": "b" }
+				]
+			}`,
+			b: `{
+				"Type": "NFA",
+				"Start": "s",
+				"States": [{ "Id": "s" }, { "Id": "x" }, { "Id": "y" }, { "Id": "z" }],
+				"Transitions": [
+					{ "Start": "s", "End": "x", "Symbol": "a" },
+					{ "Start": "s", "End": "y", "Symbol": "a" },
+					{ "Start": "x", "End": "y", "Symbol": "b" },
+					{ "Start": "z", "End": "z", "Symbol": "b" }
+				]
+			}`,
+			isomorphic: false,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := Isomorphic(mustParse(t, tc.a), mustParse(t, tc.b))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.isomorphic, actual)
+			reversed, err := Isomorphic(mustParse(t, tc.b), mustParse(t, tc.a))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.isomorphic, reversed)
+		})
+	}
+}
+
+// A DFA that takes the first of two transitions for the same symbol, and the
+// same DFA with the two transitions the other way around
+const (
+	firstMatch = `{
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q0", "End": "q0", "Symbol": "a" },
+			{ "Start": "q1", "End": "q1", "Symbol": "a" }
+		]
+	}`
+	firstMatchSwapped = `{
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+			{ "Start": "q0", "End": "q0", "Symbol": "a" },
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q1", "End": "q1", "Symbol": "a" }
+		]
+	}`
+)
+
+func TestCanonicalKeepsFirstMatch(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+	}{
+		{"same symbol", firstMatch, firstMatchSwapped},
+		{
+			name: "class and symbol",
+			a: `{
+				"Type": "DFA",
+				"Alphabet": "ab",
+				"Complete": true,
+				"Start": "q0",
+				"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+				"Transitions": [
+					{ "Start": "q0", "End": "q1", "Symbol": "[ab]" },
+					{ "Start": "q0", "End": "q0", "Symbol": "a" }
+				]
+			}`,
+			b: `{
+				"Type": "DFA",
+				"Alphabet": "ab",
+				"Complete": true,
+				"Start": "q0",
+				"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+				"Transitions": [
+					{ "Start": "q0", "End": "q0", "Symbol": "a" },
+					{ "Start": "q0", "End": "q1", "Symbol": "[ab]" }
+				]
+			}`,
+		},
+	} {
+		a, err := Hash(mustParse(t, tc.a))
+		assert.NoError(t, err, tc.name)
+		b, err := Hash(mustParse(t, tc.b))
+		assert.NoError(t, err, tc.name)
+		assert.NotEqual(t, a, b, tc.name)
+		isomorphic, err := Isomorphic(mustParse(t, tc.a), mustParse(t, tc.b))
+		assert.NoError(t, err, tc.name)
+		assert.False(t, isomorphic, tc.name)
+	}
+
+	canonical, err := Canonical(mustParse(t, firstMatchSwapped))
+	assert.NoError(t, err)
+	actual, _ := json.Marshal(canonical["Transitions"])
+	assert.JSONEq(t, `[
+		{ "Start": "q0", "End": "q0", "Symbol": "a", "Precedence": 0 },
+		{ "Start": "q0", "End": "q1", "Symbol": "a", "Precedence": 1 },
+		{ "Start": "q1", "End": "q1", "Symbol": "a" }
+	]`, string(actual))
+}
@@ -0,0 +1,143 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Diagnostic severities
+const (
+	// The document cannot be loaded
+	SeverityError = "error"
+
+	// The document can be loaded, but it is probably not what the author meant
+	SeverityWarning = "warning"
+)
+
+// Diagnostic codes that are not produced by the JSON schema. Schema failures
+// use the code "schema-" followed by the failing keyword, see `SchemaCode`
+const (
+	CodeInvalidDocument     = "invalid-document"
+	CodeUnsupportedType     = "unsupported-type"
+	CodeUnknownState        = "unknown-state"
+	CodeDuplicateState      = "duplicate-state"
+	CodeMissingTransition   = "missing-transition"
+	CodeSymbolNotInAlphabet = "symbol-not-in-alphabet"
+	CodeInvalidSymbolClass  = "invalid-symbol-class"
+
+	CodeUnsupportedSchemaVersion = "unsupported-schema-version"
+	CodeInvalidPatch             = "invalid-patch"
+	CodeInvalidRegex             = "invalid-regex"
+
+	// Lint warnings
+	CodeUnreachableState       = "unreachable-state"
+	CodeDeadState              = "dead-state"
+	CodeNoOutgoingTransitions  = "no-outgoing-transitions"
+	CodeDuplicateTransition    = "duplicate-transition"
+	CodeNondeterministicChoice = "nondeterministic-choice"
+	CodeUnusedSymbol           = "unused-symbol"
+)
+
+// A problem with a specific element of a machine document. `Pointer` is a JSON
+// pointer (RFC 6901) to the offending element e.g. "/Transitions/3/End".
+// `States` and `Transitions` optionally list every state id and transition
+// index involved in the problem, so that a UI can decorate them
+type Diagnostic struct {
+	Pointer     string   `json:"Pointer"`
+	Code        string   `json:"Code"`
+	Severity    string   `json:"Severity"`
+	Message     string   `json:"Message"`
+	States      []string `json:"States,omitempty"`
+	Transitions []int    `json:"Transitions,omitempty"`
+}
+
+// A list of diagnostics, which doubles as the error returned when a machine
+// document fails to load
+type Diagnostics []Diagnostic
+
+func (d Diagnostic) String() string {
+	if d.Pointer == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%v: %v", d.Pointer, d.Message)
+}
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, len(d))
+	for i, dd := range d {
+		msgs[i] = dd.String()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Shorthand for creating a diagnostic with severity "error"
+func Errorf(pointer, code, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{
+		Pointer:  pointer,
+		Code:     code,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Shorthand for creating a diagnostic with severity "warning"
+func Warnf(pointer, code, format string, args ...interface{}) Diagnostic {
+	d := Errorf(pointer, code, format, args...)
+	d.Severity = SeverityWarning
+	return d
+}
+
+// Extracts the diagnostics from an error. Errors that do not wrap Diagnostics
+// are reported as a single "invalid-document" diagnostic pointing at the root
+// of the document
+func DiagnosticsOf(err error) Diagnostics {
+	if err == nil {
+		return nil
+	}
+	var diags Diagnostics
+	if errors.As(err, &diags) {
+		return diags
+	}
+	return Diagnostics{Errorf("", CodeInvalidDocument, "%v", err)}
+}
+
+// Builds a JSON pointer from a list of reference tokens e.g.
+// Pointer("Transitions", 3, "End") == "/Transitions/3/End"
+func Pointer(tokens ...interface{}) string {
+	var b strings.Builder
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(escaper.Replace(fmt.Sprintf("%v", t)))
+	}
+	return b.String()
+}
+
+// The code of a diagnostic for a failure of the given schema keyword, e.g.
+// "schema-required"
+func SchemaCode(keyword string) string {
+	return "schema-" + keyword
+}
+
+func schemaDiagnostics(errors []gojsonschema.ResultError) Diagnostics {
+	diags := make(Diagnostics, 0, len(errors))
+	for _, e := range errors {
+		var tokens []interface{}
+		if field := e.Field(); field != gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+			for _, t := range strings.Split(field, ".") {
+				tokens = append(tokens, t)
+			}
+		}
+		if property, ok := e.Details()["property"]; ok && e.Type() == "required" {
+			tokens = append(tokens, property)
+		}
+		diags = append(diags, Errorf(
+			Pointer(tokens...),
+			SchemaCode(e.Type()),
+			"%v", e.Description()))
+	}
+	return diags
+}
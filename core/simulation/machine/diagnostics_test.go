@@ -0,0 +1,94 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPointer(t *testing.T) {
+	assert.Equal(t, "", Pointer())
+	assert.Equal(t, "/Transitions/3/End", Pointer("Transitions", 3, "End"))
+	assert.Equal(t, "/a~1b/c~0d", Pointer("a/b", "c~d"))
+}
+
+func TestDiagnosticsOf(t *testing.T) {
+	assert.Nil(t, DiagnosticsOf(nil))
+
+	diags := Diagnostics{Errorf("/Start", CodeUnknownState, "oops")}
+	assert.Equal(t, diags, DiagnosticsOf(fmt.Errorf("wrapped: %w", diags)))
+
+	assert.Equal(t,
+		Diagnostics{Errorf("", CodeInvalidDocument, "bad")},
+		DiagnosticsOf(errors.New("bad")))
+}
+
+var testCasesLoadDiagnostics = []struct {
+	name     string
+	document string
+	expected []struct{ pointer, code string }
+}{
+	{
+		name: "schema-errors",
+		document: `{
+			"Type": "DFA",
+			"States": [{ "Id": "", "Ending": false }],
+			"Transitions": []
+		}`,
+		expected: []struct{ pointer, code string }{
+			{"/Start", "schema-required"},
+			{"/States/0/Id", "schema-string_gte"},
+		},
+	},
+	{
+		name: "unknown-states",
+		document: `{
+			"Type": "DFA",
+			"Start": "q3",
+			"States": [{ "Id": "q0", "Ending": false }],
+			"Transitions": [
+				{ "Start": "q0", "End": "q0", "Symbol": "a" },
+				{ "Start": "q1", "End": "q2", "Symbol": "a" }
+			]
+		}`,
+		expected: []struct{ pointer, code string }{
+			{"/Transitions/1/Start", CodeUnknownState},
+			{"/Transitions/1/End", CodeUnknownState},
+			{"/Start", CodeUnknownState},
+		},
+	},
+	{
+		name: "duplicate-states",
+		document: `{
+			"Type": "DFA",
+			"Start": "q0",
+			"States": [
+				{ "Id": "q0", "Ending": false },
+				{ "Id": "q0", "Ending": true }
+			],
+			"Transitions": []
+		}`,
+		expected: []struct{ pointer, code string }{
+			{"/States/1/Id", CodeDuplicateState},
+		},
+	},
+}
+
+func TestLoadDiagnostics(t *testing.T) {
+	for _, tc := range testCasesLoadDiagnostics {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load([]byte(tc.document))
+			diags := DiagnosticsOf(err)
+			assert.Len(t, diags, len(tc.expected))
+			for i, d := range diags {
+				assert.Equal(t, tc.expected[i].pointer, d.Pointer)
+				assert.Equal(t, tc.expected[i].code, d.Code)
+				assert.Equal(t, SeverityError, d.Severity)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package machine
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// A value that is different in two versions of a machine
+type Change struct {
+	From interface{} `json:"From"`
+	To   interface{} `json:"To"`
+}
+
+// A state that is in both versions of a machine, but whose ending flag or
+// label changed
+type StateChange struct {
+	Id     string  `json:"Id"`
+	Ending *Change `json:"Ending,omitempty"`
+	Label  *Change `json:"Label,omitempty"`
+}
+
+// A transition that reads the same thing in both versions of a machine (the
+// same start state, symbol and for PDAs the same popped symbol) but does
+// something else, like going to another state
+type TransitionChange struct {
+	From map[string]interface{} `json:"From"`
+	To   map[string]interface{} `json:"To"`
+}
+
+// Symbols added to and removed from an alphabet
+type AlphabetChange struct {
+	Added   []string `json:"Added,omitempty"`
+	Removed []string `json:"Removed,omitempty"`
+}
+
+// The structural differences between two versions of a machine. States are
+// matched by id, transitions by value and alphabets are compared as sets of
+// symbols, so the order of elements does not matter. Changes to 'Meta' are
+// left out, since they do not change what the machine does
+type Changes struct {
+	Start              *Change                   `json:"Start,omitempty"`
+	Alphabets          map[string]AlphabetChange `json:"Alphabets,omitempty"`
+	Fields             map[string]Change         `json:"Fields,omitempty"`
+	StatesAdded        []string                  `json:"StatesAdded,omitempty"`
+	StatesRemoved      []string                  `json:"StatesRemoved,omitempty"`
+	StatesChanged      []StateChange             `json:"StatesChanged,omitempty"`
+	TransitionsAdded   []map[string]interface{}  `json:"TransitionsAdded,omitempty"`
+	TransitionsRemoved []map[string]interface{}  `json:"TransitionsRemoved,omitempty"`
+	TransitionsChanged []TransitionChange        `json:"TransitionsChanged,omitempty"`
+}
+
+// Fields of a document that hold alphabets
+var alphabetFields = map[string]bool{
+	"Alphabet":      true,
+	"StackAlphabet": true,
+	"TapeAlphabet":  true,
+}
+
+// Fields of a transition that describe what it does rather than what it reads
+var transitionEffects = []string{"End", "Push", "Write", "Move"}
+
+// Compares two machine documents of the same version, see `Migrate`
+func Diff(from, to map[string]interface{}) Changes {
+	var c Changes
+	if !reflect.DeepEqual(from["Start"], to["Start"]) {
+		c.Start = &Change{From: from["Start"], To: to["Start"]}
+	}
+
+	keys := map[string]bool{}
+	for k := range from {
+		keys[k] = true
+	}
+	for k := range to {
+		keys[k] = true
+	}
+	for k := range keys {
+		switch k {
+		case "Start", "States", "Transitions", "Meta", "SchemaVersion":
+			continue
+		}
+		if alphabetFields[k] && c.diffAlphabet(k, from[k], to[k]) {
+			continue
+		}
+		if !reflect.DeepEqual(from[k], to[k]) {
+			if c.Fields == nil {
+				c.Fields = map[string]Change{}
+			}
+			c.Fields[k] = Change{From: from[k], To: to[k]}
+		}
+	}
+
+	c.diffStates(ObjectList(from["States"]), ObjectList(to["States"]))
+	c.diffTransitions(ObjectList(from["Transitions"]), ObjectList(to["Transitions"]))
+	return c
+}
+
+// Whether the two versions do the same thing
+func (c Changes) Empty() bool {
+	return reflect.DeepEqual(c, Changes{})
+}
+
+// Compares two alphabets as sets of symbols. Returns false if either is not a
+// valid alphabet
+func (c *Changes) diffAlphabet(field string, from, to interface{}) bool {
+	symbols := func(unknown interface{}) (map[string]bool, bool) {
+		set := map[string]bool{}
+		if unknown == nil {
+			return set, true
+		}
+		a, err := ParseAlphabet(unknown)
+		for _, s := range a {
+			set[s] = true
+		}
+		return set, err == nil
+	}
+	before, ok := symbols(from)
+	if !ok {
+		return false
+	}
+	after, ok := symbols(to)
+	if !ok {
+		return false
+	}
+
+	var change AlphabetChange
+	for s := range after {
+		if !before[s] {
+			change.Added = append(change.Added, s)
+		}
+	}
+	for s := range before {
+		if !after[s] {
+			change.Removed = append(change.Removed, s)
+		}
+	}
+	if change.Added == nil && change.Removed == nil {
+		return true
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	if c.Alphabets == nil {
+		c.Alphabets = map[string]AlphabetChange{}
+	}
+	c.Alphabets[field] = change
+	return true
+}
+
+func (c *Changes) diffStates(from, to []map[string]interface{}) {
+	old := map[string]map[string]interface{}{}
+	for _, s := range from {
+		if id, ok := s["Id"].(string); ok {
+			old[id] = s
+		}
+	}
+	seen := map[string]bool{}
+	for _, s := range to {
+		id, ok := s["Id"].(string)
+		if !ok {
+			continue
+		}
+		seen[id] = true
+		o, ok := old[id]
+		if !ok {
+			c.StatesAdded = append(c.StatesAdded, id)
+			continue
+		}
+		change := StateChange{Id: id}
+		if ending, was := s["Ending"] == true, o["Ending"] == true; ending != was {
+			change.Ending = &Change{From: was, To: ending}
+		}
+		if label, was := stringField(s, "Label"), stringField(o, "Label"); label != was {
+			change.Label = &Change{From: was, To: label}
+		}
+		if change.Ending != nil || change.Label != nil {
+			c.StatesChanged = append(c.StatesChanged, change)
+		}
+	}
+	for _, s := range from {
+		if id, ok := s["Id"].(string); ok && !seen[id] {
+			c.StatesRemoved = append(c.StatesRemoved, id)
+		}
+	}
+}
+
+// Transitions are matched by value, ignoring 'Meta'. A removed and an added
+// transition that read the same thing are reported as one changed transition
+func (c *Changes) diffTransitions(from, to []map[string]interface{}) {
+	unmatched := map[string]int{}
+	for _, t := range to {
+		unmatched[transitionKey(t, nil)]++
+	}
+	var removed []map[string]interface{}
+	for _, t := range from {
+		if key := transitionKey(t, nil); unmatched[key] > 0 {
+			unmatched[key]--
+		} else {
+			removed = append(removed, withoutMeta(t))
+		}
+	}
+	var added []map[string]interface{}
+	for _, t := range to {
+		if key := transitionKey(t, nil); unmatched[key] > 0 {
+			unmatched[key]--
+			added = append(added, withoutMeta(t))
+		}
+	}
+
+	// Pair the removed and added transitions that read the same thing, in
+	// document order
+	reads := map[string][]int{}
+	for i, t := range added {
+		key := transitionKey(t, transitionEffects)
+		reads[key] = append(reads[key], i)
+	}
+	paired := map[int]bool{}
+	for _, t := range removed {
+		key := transitionKey(t, transitionEffects)
+		if candidates := reads[key]; len(candidates) > 0 {
+			reads[key] = candidates[1:]
+			paired[candidates[0]] = true
+			c.TransitionsChanged = append(c.TransitionsChanged,
+				TransitionChange{From: t, To: added[candidates[0]]})
+		} else {
+			c.TransitionsRemoved = append(c.TransitionsRemoved, t)
+		}
+	}
+	for i, t := range added {
+		if !paired[i] {
+			c.TransitionsAdded = append(c.TransitionsAdded, t)
+		}
+	}
+}
+
+// Identifies a transition by its fields, leaving out 'Meta', the given fields
+// and fields that have their default value
+func transitionKey(t map[string]interface{}, ignore []string) string {
+	fields := withoutMeta(t)
+	for _, f := range ignore {
+		delete(fields, f)
+	}
+	for k, v := range fields {
+		if v == false || v == "" {
+			delete(fields, k)
+		}
+	}
+	data, _ := json.Marshal(fields) // Map keys are sorted
+	return string(data)
+}
+
+func withoutMeta(o map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(o))
+	for k, v := range o {
+		if k != "Meta" {
+			copied[k] = v
+		}
+	}
+	return copied
+}
+
+func stringField(o map[string]interface{}, field string) string {
+	s, _ := o[field].(string)
+	return s
+}
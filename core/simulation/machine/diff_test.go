@@ -0,0 +1,152 @@
+package machine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	const base = `{
+		"Type": "DFA",
+		"Start": "q0",
+		"States": [
+			{ "Id": "q0" },
+			{ "Id": "q1", "Ending": true, "Meta": { "X": 10 } }
+		],
+		"Transitions": [
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q1", "End": "q0", "Symbol": "a" },
+			{ "Start": "q0", "End": "q0", "Symbol": "b" },
+			{ "Start": "q1", "End": "q1", "Symbol": "b" }
+		]
+	}`
+
+	for _, tc := range []struct {
+		name     string
+		from, to string
+		expected string
+	}{
+		{
+			name:     "same",
+			from:     base,
+			to:       base,
+			expected: `{}`,
+		},
+		{
+			name: "meta-and-order-are-ignored",
+			from: base,
+			to: `{
+				"Type": "DFA",
+				"Start": "q0",
+				"States": [
+					{ "Id": "q1", "Ending": true, "Meta": { "X": 50 } },
+					{ "Id": "q0", "Ending": false }
+				],
+				"Transitions": [
+					{ "Start": "q1", "End": "q1", "Symbol": "b" },
+					{ "Start": "q0", "End": "q1", "Symbol": "a", "Meta": { "Bend": 1 } },
+					{ "Start": "q1", "End": "q0", "Symbol": "a" },
+					{ "Start": "q0", "End": "q0", "Symbol": "b", "Otherwise": false }
+				]
+			}`,
+			expected: `{}`,
+		},
+		{
+			name:     "alphabets-are-sets",
+			from:     `{ "Type": "DFA", "Alphabet": "ab", "States": [] }`,
+			to:       `{ "Type": "DFA", "Alphabet": ["b", "a"], "States": [] }`,
+			expected: `{}`,
+		},
+		{
+			name: "alphabet-and-fields",
+			from: `{ "Type": "TM", "Alphabet": "ab", "Blank": "_" }`,
+			to:   `{ "Type": "TM", "Alphabet": ["b", "cd"], "Blank": "#" }`,
+			expected: `{
+				"Alphabets": { "Alphabet": { "Added": ["cd"], "Removed": ["a"] } },
+				"Fields": { "Blank": { "From": "_", "To": "#" } }
+			}`,
+		},
+		{
+			name: "changes",
+			from: base,
+			to: `{
+				"Type": "DFA",
+				"Alphabet": "abc",
+				"Start": "q1",
+				"States": [
+					{ "Id": "q0", "Ending": true },
+					{ "Id": "q1", "Label": "odd" },
+					{ "Id": "q2" }
+				],
+				"Transitions": [
+					{ "Start": "q0", "End": "q1", "Symbol": "a" },
+					{ "Start": "q1", "End": "q2", "Symbol": "a" },
+					{ "Start": "q0", "End": "q0", "Symbol": "b" },
+					{ "Start": "q2", "End": "q2", "Otherwise": true }
+				]
+			}`,
+			expected: `{
+				"Start": { "From": "q0", "To": "q1" },
+				"Alphabets": { "Alphabet": { "Added": ["a", "b", "c"] } },
+				"StatesAdded": ["q2"],
+				"StatesChanged": [
+					{ "Id": "q0", "Ending": { "From": false, "To": true } },
+					{
+						"Id": "q1",
+						"Ending": { "From": true, "To": false },
+						"Label": { "From": "", "To": "odd" }
+					}
+				],
+				"TransitionsAdded": [{ "Start": "q2", "End": "q2", "Otherwise": true }],
+				"TransitionsRemoved": [{ "Start": "q1", "End": "q1", "Symbol": "b" }],
+				"TransitionsChanged": [{
+					"From": { "Start": "q1", "End": "q0", "Symbol": "a" },
+					"To": { "Start": "q1", "End": "q2", "Symbol": "a" }
+				}]
+			}`,
+		},
+		{
+			name: "pda-push-changed",
+			from: `{
+				"Type": "PDA",
+				"Start": "q0",
+				"States": [{ "Id": "q0" }],
+				"Transitions": [
+					{ "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "Z", "Push": "AZ" }
+				]
+			}`,
+			to: `{
+				"Type": "PDA",
+				"Start": "q0",
+				"States": [],
+				"Transitions": [
+					{ "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "Z", "Push": "AAZ" },
+					{ "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "A" }
+				]
+			}`,
+			expected: `{
+				"StatesRemoved": ["q0"],
+				"TransitionsAdded": [{ "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "A" }],
+				"TransitionsChanged": [{
+					"From": { "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "Z", "Push": "AZ" },
+					"To": { "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "Z", "Push": "AAZ" }
+				}]
+			}`,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var from, to map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tc.from), &from))
+			assert.NoError(t, json.Unmarshal([]byte(tc.to), &to))
+			changes := Diff(from, to)
+			actual, err := json.Marshal(changes)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(actual))
+			assert.Equal(t, tc.expected == `{}`, changes.Empty())
+		})
+	}
+}
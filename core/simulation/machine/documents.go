@@ -0,0 +1,146 @@
+package machine
+
+// The document format of each type of machine. The JSON schemas of the
+// machines are generated from these types (see `Schema`): a field is
+// required unless its json tag has 'omitempty', the 'description' tag
+// describes it and the 'schema' tag adds keywords e.g.
+// `schema:"minLength=1,enum=L|R|S"`
+
+// Fields shared by the documents of every type of machine
+type Document struct {
+	SchemaVersion int `json:"SchemaVersion,omitempty" schema:"minimum=0" description:"The version of the document format. Documents without a version are from before versioning (version 0), and are migrated when they are loaded"`
+
+	Type string `json:"Type" description:"What type of state machine this is. Must be one of: 'DFA', 'NFA', 'PDA', or 'TM'"`
+
+	Meta Meta `json:"Meta,omitempty" description:"Free-form data about the machine that is not used in simulation, such as comments or editor settings. It is kept as it is when the machine is loaded and dumped"`
+
+	Start string `json:"Start" schema:"minLength=1" description:"The 'Id' field for the starting state of the machine"`
+
+	States []StateDocument `json:"States" schema:"uniqueItems" description:"The collection of states that are part of the machine"`
+}
+
+type StateDocument struct {
+	Id string `json:"Id" schema:"minLength=1" description:"The id (unique) of the state e.g. 'q0', 'even', 's_a'. Any non-empty string is allowed."`
+
+	Label string `json:"Label,omitempty" description:"An optional name for the state to display instead of its id"`
+
+	Ending bool `json:"Ending,omitempty" description:"Whether or not this state is an ending state. If absent, this value should be considered 'false'"`
+
+	Meta Meta `json:"Meta,omitempty" description:"Free-form data about the state that is not used in simulation, such as its position ('X' and 'Y') or color in an editor"`
+}
+
+// Fields shared by the transitions of every type of machine
+type TransitionDocument struct {
+	Start string `json:"Start" schema:"minLength=1" description:"The 'Id' field for the starting state of the transition"`
+
+	End string `json:"End" schema:"minLength=1" description:"The 'Id' field for the ending state of the transition"`
+
+	Symbol string `json:"Symbol" description:"The symbol(s) that is consumed from the input tape in order to traverse this transition"`
+
+	Meta Meta `json:"Meta,omitempty" description:"Free-form data about the transition that is not used in simulation, such as how it is drawn in an editor"`
+}
+
+type DFADocument struct {
+	Document
+
+	Alphabet Alphabet `json:"Alphabet,omitempty" description:"The symbols that are accepted by the machine. This is either a string where every character is a valid symbol accepted by the machine, or a list of symbols where each symbol may be several characters long e.g. [\"if\", \"else\"]. If this field is omitted, then the alphabet will be inferred from the Transitions field."`
+
+	Separator string `json:"Separator,omitempty" schema:"minLength=1" description:"If present, the symbols on the input tape are separated by this string. Otherwise the tape is split into symbols by longest match against the alphabet."`
+
+	Complete bool `json:"Complete,omitempty" description:"If true, every state that is missing a transition for a symbol of the alphabet gets one to a generated, non-ending trap state"`
+
+	Trap string `json:"Trap,omitempty" description:"The id of the trap state that was generated when the machine was completed. Informational only"`
+
+	Transitions []FiniteTransitionDocument `json:"Transitions" schema:"uniqueItems" description:"The collection of transitions that are part of the machine"`
+}
+
+type NFADocument struct {
+	Document
+
+	Alphabet Alphabet `json:"Alphabet,omitempty" description:"The symbols that are accepted by the machine, as a string of single character symbols or a list of symbols. If this field is omitted, then the alphabet will be inferred from the Transitions field."`
+
+	Transitions []FiniteTransitionDocument `json:"Transitions" schema:"uniqueItems" description:"The collection of transitions that are part of the machine. A transition with an empty symbol is taken without reading the tape"`
+}
+
+type PDADocument struct {
+	Document
+
+	Alphabet Alphabet `json:"Alphabet,omitempty" description:"The symbols that may appear on the input tape. If this field is omitted, then the alphabet will be inferred from the Transitions field."`
+
+	StackAlphabet Alphabet `json:"StackAlphabet,omitempty" description:"The symbols that may be pushed onto the stack. If this field is omitted, then the stack alphabet will be inferred from the Transitions field."`
+
+	InitialStackSymbol string `json:"InitialStackSymbol,omitempty" schema:"minLength=1" description:"The symbol on the stack when the machine starts. If absent, this value should be considered 'Z'"`
+
+	Transitions []PushdownTransitionDocument `json:"Transitions" schema:"uniqueItems" description:"The collection of transitions that are part of the machine"`
+}
+
+type TMDocument struct {
+	Document
+
+	Alphabet Alphabet `json:"Alphabet,omitempty" description:"The symbols that may appear on the input tape. If this field is omitted, then the alphabet will be inferred from the Transitions field."`
+
+	TapeAlphabet Alphabet `json:"TapeAlphabet,omitempty" description:"The symbols that the machine may write on the tape, in addition to the input symbols"`
+
+	Blank string `json:"Blank,omitempty" description:"The symbol of blank cells of the tape. If absent, blank cells are empty strings"`
+
+	Transitions []TuringTransitionDocument `json:"Transitions" schema:"uniqueItems" description:"The collection of transitions that are part of the machine"`
+}
+
+// A transition of a DFA or NFA
+type FiniteTransitionDocument struct {
+	TransitionDocument
+
+	Otherwise bool `json:"Otherwise,omitempty" description:"If true, this transition is taken when no other transition leaving the same state matches the next symbol, including symbols that are not part of the alphabet. 'Symbol' may be omitted"`
+}
+
+// A transition of a PDA, which reads 'Symbol' from the tape (nothing if it is
+// empty), pops 'Pop' and pushes 'Push'
+type PushdownTransitionDocument struct {
+	TransitionDocument
+
+	Pop string `json:"Pop,omitempty" description:"The symbol(s) popped from the top of the stack in order to traverse this transition. If absent or empty, nothing is popped"`
+
+	Push string `json:"Push,omitempty" description:"The symbol(s) pushed onto the stack when this transition is traversed, the first symbol ending up on top. If absent or empty, nothing is pushed"`
+}
+
+// A transition of a TM, which reads 'Symbol' under the head, writes 'Write'
+// in its place and moves the head
+type TuringTransitionDocument struct {
+	TransitionDocument
+
+	Write string `json:"Write,omitempty" description:"The symbol written in place of the symbol that was read. If absent or empty, a blank is written"`
+
+	Move string `json:"Move,omitempty" schema:"enum=L|R|S" description:"Where the head moves after writing: one cell left ('L'), one cell right ('R') or nowhere ('S'). If absent, this value should be considered 'S'"`
+}
+
+// Symbols of finite automata may be symbol classes, and may be omitted by
+// "otherwise" transitions
+func (FiniteTransitionDocument) extendSchema(schema map[string]interface{}) {
+	props := schema["properties"].(map[string]interface{})
+	symbol := props["Symbol"].(map[string]interface{})
+	symbol["description"] = "The symbol(s) that is consumed from the input " +
+		"tape in order to traverse this transition. A symbol that is not part " +
+		"of the alphabet may be a class of symbols: a bracket class of " +
+		"characters and ranges like '[a-z0-9_]', or a comma separated list " +
+		"like 'a,b,c'"
+	schema["required"] = []interface{}{"Start", "End"}
+	schema["anyOf"] = []interface{}{
+		map[string]interface{}{"required": []interface{}{"Symbol"}},
+		map[string]interface{}{"required": []interface{}{"Otherwise"}},
+	}
+}
+
+// An alphabet is either a string of single character symbols, or a list of
+// symbols
+func (Alphabet) jsonSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string"},
+			map[string]interface{}{
+				"type":        "array",
+				"uniqueItems": true,
+				"items":       map[string]interface{}{"type": "string", "minLength": 1.0},
+			},
+		},
+	}
+}
@@ -9,18 +9,22 @@ type Graph struct {
 	Start       *State       `json:"Start"`
 	States      []State      `json:"States"`
 	Transitions []Transition `json:"Transitions"`
+	Meta        Meta         `json:"Meta,omitempty"`
 }
 
 type GraphParams struct {
 	Start       string
 	States      []State
 	Transitions []TransitionParams
+	Meta        Meta `json:",omitempty"`
 }
 
 type TransitionParams struct {
-	Start  string
-	End    string
-	Symbol string
+	Start     string
+	End       string
+	Symbol    string
+	Otherwise bool `json:",omitempty"`
+	Meta      Meta `json:",omitempty"`
 }
 
 func From(params GraphParams) *Graph {
@@ -30,16 +34,20 @@ func From(params GraphParams) *Graph {
 	}
 	g := Graph{
 		States: params.States,
+		Meta:   params.Meta,
 	}
 	if g.States == nil {
 		g.States = []State{}
 	}
-	g.Start = g.FindState(params.Start)
+	index := stateIndex(&g)
+	g.Start = index[params.Start]
 	for _, t := range params.Transitions {
 		g.Transitions = append(g.Transitions, Transition{
-			Start:  g.FindState(t.Start),
-			End:    g.FindState(t.End),
-			Symbol: t.Symbol,
+			Start:     index[t.Start],
+			End:       index[t.End],
+			Symbol:    t.Symbol,
+			Otherwise: t.Otherwise,
+			Meta:      t.Meta,
 		})
 	}
 	return &g
@@ -60,6 +68,7 @@ func NewBlankGraph() *Graph {
 	}
 }
 
+// Finds a state by id. This is a linear search, avoid calling it in a loop
 func (g *Graph) FindState(id string) *State {
 	for i, s := range g.States {
 		if s.Id == id {
@@ -69,6 +78,32 @@ func (g *Graph) FindState(id string) *State {
 	return nil
 }
 
+// Appends a state to the graph and returns a pointer to it. Unlike `WithState`,
+// the graph's Start and Transitions are kept pointing into the graph's own
+// state slice if appending moves it
+func (g *Graph) AddState(state State) *State {
+	old := g.States
+	g.States = append(g.States, state)
+	if len(old) > 0 && &old[0] != &g.States[0] {
+		moved := make(map[*State]*State, len(old))
+		for i := range old {
+			moved[&old[i]] = &g.States[i]
+		}
+		if s, ok := moved[g.Start]; ok {
+			g.Start = s
+		}
+		for i, t := range g.Transitions {
+			if s, ok := moved[t.Start]; ok {
+				g.Transitions[i].Start = s
+			}
+			if s, ok := moved[t.End]; ok {
+				g.Transitions[i].End = s
+			}
+		}
+	}
+	return &g.States[len(g.States)-1]
+}
+
 func (g *Graph) WithStates(states ...State) *Graph {
 	g.States = append(g.States, states...)
 	return g
@@ -119,6 +154,9 @@ func (g *Graph) JsonMap() map[string]interface{} {
 		res["Transitions"] = append(
 			res["Transitions"].([]map[string]interface{}), t.JsonMap())
 	}
+	if g.Meta != nil {
+		res["Meta"] = g.Meta.JsonValue()
+	}
 	return res
 }
 
@@ -127,10 +165,11 @@ func (g *Graph) Copy() *Graph {
 	gg := Graph{
 		States:      []State{},
 		Transitions: []Transition{},
+		Meta:        g.Meta.Copy(),
 	}
 
 	copyTransition := func(t Transition) Transition {
-		tt := Transition{Symbol: t.Symbol}
+		tt := Transition{Symbol: t.Symbol, Otherwise: t.Otherwise, Meta: t.Meta.Copy()}
 		for i, s := range gg.States {
 			if s.Id == t.Start.Id {
 				tt.Start = &gg.States[i]
@@ -168,14 +207,17 @@ func (g *Graph) Copy() *Graph {
 	return &gg
 }
 
+// Graph params are not a complete machine document (they have no 'Type'), so
+// only the structure of the graph is validated
 func validateParams(params GraphParams) error {
 	d, err := json.Marshal(params)
 	if err != nil {
 		return err
 	}
-	_, err = Load(d)
+	m, err := readMap(d)
 	if err != nil {
 		return err
 	}
-	return nil
+	_, err = createGraph(m)
+	return err
 }
@@ -0,0 +1,129 @@
+package machine
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Types that provide their own schema instead of the one generated from their
+// Go type
+type schemaProvider interface {
+	jsonSchema() map[string]interface{}
+}
+
+// Types that add to the schema generated from their fields
+type schemaExtender interface {
+	extendSchema(schema map[string]interface{})
+}
+
+var (
+	providerType = reflect.TypeOf((*schemaProvider)(nil)).Elem()
+	extenderType = reflect.TypeOf((*schemaExtender)(nil)).Elem()
+)
+
+// Generates the JSON schema of the documents that `encoding/json` produces
+// from values of type `t`. Embedded structs have their fields promoted, like
+// they are by `encoding/json`
+func generateSchema(t reflect.Type) map[string]interface{} {
+	if t.Implements(providerType) {
+		return reflect.Zero(t).Interface().(schemaProvider).jsonSchema()
+	}
+
+	var schema map[string]interface{}
+	switch t.Kind() {
+	case reflect.String:
+		schema = map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema = map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		schema = map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		schema = map[string]interface{}{
+			"type":  "array",
+			"items": generateSchema(t.Elem()),
+		}
+	case reflect.Map:
+		schema = map[string]interface{}{"type": "object"}
+	case reflect.Ptr:
+		return generateSchema(t.Elem())
+	case reflect.Struct:
+		schema = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		}
+		required := []interface{}{}
+		addProperties(t, schema["properties"].(map[string]interface{}), &required)
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	default:
+		panic(fmt.Sprintf("cannot generate the schema of type %v", t))
+	}
+
+	if t.Implements(extenderType) {
+		reflect.Zero(t).Interface().(schemaExtender).extendSchema(schema)
+	}
+	return schema
+}
+
+func addProperties(t reflect.Type, props map[string]interface{}, required *[]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			addProperties(f.Type, props, required)
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := generateSchema(f.Type)
+		if description := f.Tag.Get("description"); description != "" {
+			schema["description"] = description
+		}
+		for _, keyword := range strings.Split(f.Tag.Get("schema"), ",") {
+			addKeyword(schema, keyword)
+		}
+		props[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Adds a keyword from a 'schema' struct tag: a flag like "uniqueItems", or a
+// "keyword=value" pair where the value is a number, or a list of strings
+// separated by '|' for "enum"
+func addKeyword(schema map[string]interface{}, keyword string) {
+	if keyword == "" {
+		return
+	}
+	key, value, ok := strings.Cut(keyword, "=")
+	if !ok {
+		schema[key] = true
+		return
+	}
+	if key == "enum" {
+		enum := []interface{}{}
+		for _, v := range strings.Split(value, "|") {
+			enum = append(enum, v)
+		}
+		schema[key] = enum
+		return
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic(fmt.Sprintf("schema keyword '%v' needs a number", keyword))
+	}
+	schema[key] = n
+}
@@ -0,0 +1,114 @@
+package machine
+
+// Checks the graph for problems that do not prevent it from being loaded, but
+// that are probably mistakes: states that cannot be reached from the start
+// state, states from which no ending state can be reached, states without
+// outgoing transitions, and duplicate transitions
+func (g *Graph) Lint() Diagnostics {
+	diags := Diagnostics{}
+	reachable := g.reachableFrom(g.Start)
+	productive := g.productiveStates()
+	outgoing := make(map[string]int, len(g.States))
+	for _, t := range g.Transitions {
+		outgoing[t.Start.Id]++
+	}
+
+	for i, s := range g.States {
+		if !reachable[s.Id] {
+			diags = append(diags, withState(Warnf(
+				Pointer("States", i), CodeUnreachableState,
+				"state '%v' cannot be reached from the start state", s.Id), s.Id))
+		}
+		if !productive[s.Id] {
+			diags = append(diags, withState(Warnf(
+				Pointer("States", i), CodeDeadState,
+				"no ending state can be reached from state '%v'", s.Id), s.Id))
+		}
+		if outgoing[s.Id] == 0 {
+			diags = append(diags, withState(Warnf(
+				Pointer("States", i), CodeNoOutgoingTransitions,
+				"state '%v' has no outgoing transitions", s.Id), s.Id))
+		}
+	}
+
+	type triple struct {
+		start, end, symbol string
+		otherwise          bool
+	}
+	first := make(map[triple]int, len(g.Transitions))
+	for i, t := range g.Transitions {
+		key := triple{t.Start.Id, t.End.Id, t.Symbol, t.Otherwise}
+		j, seen := first[key]
+		if !seen {
+			first[key] = i
+			continue
+		}
+		d := Warnf(
+			Pointer("Transitions", i), CodeDuplicateTransition,
+			"transition %v -> %v on '%v' is a duplicate of transition %v",
+			t.Start.Id, t.End.Id, t.Symbol, j)
+		d.States = []string{t.Start.Id, t.End.Id}
+		d.Transitions = []int{j, i}
+		diags = append(diags, d)
+	}
+
+	return diags
+}
+
+// The set of state ids that can be reached by following transitions from
+// `start` (including `start` itself)
+func (g *Graph) reachableFrom(start *State) map[string]bool {
+	seen := map[string]bool{}
+	if start == nil {
+		return seen
+	}
+	adjacent := make(map[string][]string, len(g.States))
+	for _, t := range g.Transitions {
+		adjacent[t.Start.Id] = append(adjacent[t.Start.Id], t.End.Id)
+	}
+	queue := []string{start.Id}
+	seen[start.Id] = true
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacent[id] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// The set of state ids from which an ending state can be reached
+func (g *Graph) productiveStates() map[string]bool {
+	seen := map[string]bool{}
+	incoming := make(map[string][]string, len(g.States))
+	for _, t := range g.Transitions {
+		incoming[t.End.Id] = append(incoming[t.End.Id], t.Start.Id)
+	}
+	var queue []string
+	for _, s := range g.States {
+		if s.Ending {
+			seen[s.Id] = true
+			queue = append(queue, s.Id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, prev := range incoming[id] {
+			if !seen[prev] {
+				seen[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+	return seen
+}
+
+func withState(d Diagnostic, ids ...string) Diagnostic {
+	d.States = ids
+	return d
+}
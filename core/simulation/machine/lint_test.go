@@ -0,0 +1,54 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	g := From(GraphParams{
+		Start: "q0",
+		States: []State{
+			{Id: "q0", Ending: false},
+			{Id: "q1", Ending: true},
+			{Id: "q2", Ending: false},
+			{Id: "q3", Ending: false},
+		},
+		Transitions: []TransitionParams{
+			{Start: "q0", End: "q1", Symbol: "a"},
+			{Start: "q1", End: "q1", Symbol: "a"},
+			{Start: "q0", End: "q2", Symbol: "b"},
+			{Start: "q0", End: "q1", Symbol: "a"},
+			{Start: "q3", End: "q1", Symbol: "a"},
+		},
+	})
+	assert.NotNil(t, g)
+
+	type warning struct {
+		pointer, code string
+		states        []string
+		transitions   []int
+	}
+	expected := []warning{
+		{"/States/2", CodeDeadState, []string{"q2"}, nil},
+		{"/States/2", CodeNoOutgoingTransitions, []string{"q2"}, nil},
+		{"/States/3", CodeUnreachableState, []string{"q3"}, nil},
+		{"/Transitions/3", CodeDuplicateTransition, []string{"q0", "q1"}, []int{0, 3}},
+	}
+
+	diags := g.Lint()
+	assert.Len(t, diags, len(expected))
+	for i, d := range diags {
+		assert.Equal(t, SeverityWarning, d.Severity)
+		assert.Equal(t, expected[i].pointer, d.Pointer)
+		assert.Equal(t, expected[i].code, d.Code)
+		assert.Equal(t, expected[i].states, d.States)
+		assert.Equal(t, expected[i].transitions, d.Transitions)
+	}
+}
+
+func TestLintCleanGraph(t *testing.T) {
+	g := basicGraph.Copy()
+	assert.Empty(t, g.Lint())
+}
@@ -144,6 +144,57 @@ var testCasesLoadMachine = []struct {
 	},
 }
 
+var testCasesLoadFreeFormIds = []struct {
+	name string
+	data []byte
+}{
+	{
+		name: "descriptive-ids-and-labels",
+		data: []byte(`
+		{
+			"Type": "DFA",
+			"Alphabet": "ab",
+			"Start": "even",
+			"States": [
+			  { "Id": "even", "Ending": false, "Label": "Even # of a's" },
+			  { "Id": "s_a", "Ending": true },
+			  { "Id": "q01" }
+			],
+			"Transitions": [
+			  { "Start": "even", "End": "s_a", "Symbol": "a" },
+			  { "Start": "s_a", "End": "even", "Symbol": "a" },
+			  { "Start": "q01", "End": "even", "Symbol": "b" }
+			]
+		}
+		`),
+	},
+}
+
+func TestLoadFreeFormIds(t *testing.T) {
+	for _, tc := range testCasesLoadFreeFormIds {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g, err := Load(tc.data)
+			assert.NoError(t, err)
+			assert.Equal(t, "even", g.Start.Id)
+			assert.Equal(t, "Even # of a's", g.Start.Label)
+			assert.Equal(t, "Even # of a's", g.Start.Name())
+			assert.Equal(t, "s_a", g.States[1].Name())
+			assert.False(t, g.States[2].Ending, "'Ending' should default to false")
+			assert.Equal(t, "q01", g.Transitions[2].Start.Id)
+
+			doc := g.JsonMap()
+			doc["Type"] = "DFA"
+			data, err := json.Marshal(doc)
+			assert.NoError(t, err)
+			reloaded, err := Load(data)
+			assert.NoError(t, err)
+			assertMarshalablesEqual(t, g, reloaded)
+		})
+	}
+}
+
 // Tests the `machine.Load` function
 func TestLoadMachine(t *testing.T) {
 	testLoadingViaMap := func(
@@ -278,3 +329,58 @@ func assertMarshalablesEqual(t *testing.T, expected, actual Marshalable) {
 	assert.Equal(t,
 		expected.Json(), actual.Json(), "json value of graphs should be equal")
 }
+
+func TestMetaIsPreserved(t *testing.T) {
+	doc := `
+	{
+		"Type": "DFA",
+		"Meta": { "Comment": "Accepts an odd number of a's", "Zoom": 1.5 },
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0", "Meta": { "X": 100, "Y": 50, "Color": "#ff0000" } },
+		  { "Id": "q1", "Ending": true }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "a", "Meta": { "Bend": [0.5, { "Y": -20 }] } },
+		  { "Start": "q1", "End": "q0", "Symbol": "a" }
+		]
+	}`
+	g, err := Load([]byte(doc))
+	assert.NoError(t, err)
+	assert.Equal(t, Meta{"X": 100.0, "Y": 50.0, "Color": "#ff0000"}, g.Start.Meta)
+	assert.Nil(t, g.States[1].Meta)
+
+	dumped := g.JsonMap()
+	assert.Equal(t, map[string]interface{}{
+		"Comment": "Accepts an odd number of a's",
+		"Zoom":    1.5,
+	}, dumped["Meta"])
+	assert.Equal(t, map[string]interface{}{
+		"Bend": []interface{}{0.5, map[string]interface{}{"Y": -20.0}},
+	}, dumped["Transitions"].([]map[string]interface{})[0]["Meta"])
+	assert.NotContains(t, dumped["States"].([]map[string]interface{})[1], "Meta")
+
+	dumped["Type"] = "DFA"
+	data, err := json.Marshal(dumped)
+	assert.NoError(t, err)
+	reloaded, err := Load(data)
+	assert.NoError(t, err)
+	assertMarshalablesEqual(t, g, reloaded)
+
+	// Copies do not share meta with the original
+	c := g.Copy()
+	c.Meta["Zoom"] = 2
+	c.Transitions[0].Meta["Bend"].([]interface{})[1].(map[string]interface{})["Y"] = 0
+	assert.Equal(t, 1.5, g.Meta["Zoom"])
+	assert.Equal(t, -20.0,
+		g.Transitions[0].Meta["Bend"].([]interface{})[1].(map[string]interface{})["Y"])
+
+	for name, invalid := range map[string]string{
+		"machine":    `{"Type": "DFA", "Meta": 1, "Start": "q0", "States": [{"Id": "q0"}], "Transitions": []}`,
+		"state":      `{"Type": "DFA", "Start": "q0", "States": [{"Id": "q0", "Meta": "x"}], "Transitions": []}`,
+		"transition": `{"Type": "DFA", "Start": "q0", "States": [{"Id": "q0"}], "Transitions": [{"Start": "q0", "End": "q0", "Symbol": "a", "Meta": []}]}`,
+	} {
+		_, err := Load([]byte(invalid))
+		assert.Error(t, err, name)
+	}
+}
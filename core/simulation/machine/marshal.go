@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"sync"
 
 	"github.com/xeipuuv/gojsonschema"
 )
@@ -17,44 +16,56 @@ const errMsg = "invalid %v, must be of type " +
 	"`[]byte`, " +
 	"or `io.Reader`"
 
-var defaultSchema = []byte(SCHEMA)
-var cachedSchema = struct {
-	*sync.Mutex
-	value map[string]interface{}
-}{&sync.Mutex{}, nil}
-
 func Load(document interface{}) (*Graph, error) {
 	return LoadWithSchema(document, nil)
 }
 
+// Loads a machine document (see `LoadMap`) and validates it against `schema`.
+// If `schema` is nil, the document is validated against the schema of its type
 func LoadWithSchema(document interface{}, schema interface{}) (*Graph, error) {
 	documentMap, err := LoadMap(document)
 	if err != nil {
 		return nil, err
 	}
 
-	schemaMap, err := schemaOrDefault(schema)
+	schemaMap, err := schemaOrDefault(schema, documentMap)
 	if err != nil {
 		return nil, err
 	}
 
-	validationResult, err := ValidateJson(documentMap, schemaMap)
+	validationResult, err := ValidateJson(schemaMap, documentMap)
 	if err != nil {
 		return nil, err
 	}
 
 	if !validationResult.Valid() {
-		return nil, unifyErrors(validationResult.Errors())
+		return nil, schemaDiagnostics(validationResult.Errors())
 	}
 
 	return createGraph(documentMap)
 }
 
+// Reads a machine document into a map, upgraded to the current version (see
+// `Migrate`). `document` may be the map itself, a path to a file, a `[]byte`
+// or an `io.Reader` of JSON. Files with a `.yml` or `.yaml` extension are read
+// as YAML, see `LoadYamlMap`
 func LoadMap(document interface{}) (map[string]interface{}, error) {
+	documentMap, err := readMap(document)
+	if err != nil {
+		return nil, err
+	}
+	return Migrate(documentMap)
+}
+
+// Reads a JSON (or YAML, see `LoadMap`) object into a map, as is
+func readMap(document interface{}) (map[string]interface{}, error) {
 	switch d := document.(type) {
 	case map[string]interface{}:
 		return d, nil
 	case string:
+		if IsYamlPath(d) {
+			return LoadYamlMap(d)
+		}
 		return loadFile(d)
 	case []byte:
 		return loadBuffer(d)
@@ -65,19 +76,6 @@ func LoadMap(document interface{}) (map[string]interface{}, error) {
 	}
 }
 
-func GetSchema() map[string]interface{} {
-	cachedSchema.Lock()
-	defer cachedSchema.Unlock()
-	if cachedSchema.value == nil {
-		err := json.Unmarshal(defaultSchema, &cachedSchema.value)
-		if err != nil {
-			cachedSchema.value = nil
-			return map[string]interface{}{}
-		}
-	}
-	return cachedSchema.value
-}
-
 func ValidateJson(
 	schema map[string]interface{},
 	document map[string]interface{},
@@ -115,28 +113,22 @@ func loadBuffer(buf []byte) (map[string]interface{}, error) {
 	return m, nil
 }
 
-func unifyErrors(errors []gojsonschema.ResultError) error {
-	err := "("
-	for i, e := range errors {
-		if err += fmt.Sprintf("%v", e); i != len(errors)-1 {
-			err += "... "
-		} else {
-			err += ")"
-		}
-	}
-	return fmt.Errorf("JSON schema is not valid: %v", err)
-}
-
 func createGraph(document map[string]interface{}) (*Graph, error) {
 	var g Graph
+	meta, err := metaOf(document)
+	if err != nil {
+		return nil, Diagnostics{Errorf(Pointer("Meta"), CodeInvalidDocument,
+			"invalid document: %v", err)}
+	}
+	g.Meta = meta
 	if err := addStates(&g, document); err != nil {
 		return nil, err
 	}
-	if err := addTransitions(&g, document); err != nil {
-		return nil, err
-	}
-	if err := addStartState(&g, document); err != nil {
-		return nil, err
+	index := stateIndex(&g)
+	diags := addTransitions(&g, index, document)
+	diags = append(diags, addStartState(&g, index, document)...)
+	if len(diags) > 0 {
+		return nil, diags
 	}
 	return &g, nil
 }
@@ -144,19 +136,34 @@ func createGraph(document map[string]interface{}) (*Graph, error) {
 func addStates(mach *Graph, document map[string]interface{}) error {
 	states, ok := document["States"]
 	if !ok {
-		return fmt.Errorf("'States' key not found within document")
+		return Diagnostics{Errorf(Pointer("States"), CodeInvalidDocument,
+			"'States' key not found within document")}
 	}
 	unknownStates, ok := states.([]interface{})
 	if !ok {
-		return fmt.Errorf("'States' key in document is not valid: %v", states)
+		return Diagnostics{Errorf(Pointer("States"), CodeInvalidDocument,
+			"'States' key in document is not valid: %v", states)}
 	}
-	for _, s := range unknownStates {
+	var diags Diagnostics
+	seen := make(map[string]bool, len(unknownStates))
+	for i, s := range unknownStates {
 		ss, err := addState(mach, s)
 		if err != nil {
-			return fmt.Errorf("invalid States set: %v", err)
+			diags = append(diags, Errorf(Pointer("States", i), CodeInvalidDocument,
+				"invalid States set: %v", err))
+			continue
+		}
+		if seen[ss.Id] {
+			diags = append(diags, Errorf(Pointer("States", i, "Id"), CodeDuplicateState,
+				"state id '%v' is used by more than one state", ss.Id))
+			continue
 		}
+		seen[ss.Id] = true
 		mach.States = append(mach.States, ss)
 	}
+	if len(diags) > 0 {
+		return diags
+	}
 	return nil
 }
 
@@ -173,101 +180,174 @@ func addState(mach *Graph, unknown interface{}) (State, error) {
 				" unknown state to 'string', invalid State")
 	}
 	ending, ok := s["Ending"].(bool)
-	if !ok {
+	if _, present := s["Ending"]; present && !ok {
 		return State{},
 			fmt.Errorf("error casting 'Ending' field of unknown " +
 				"state to 'bool', invalid State")
 	}
+	label, ok := s["Label"].(string)
+	if _, present := s["Label"]; present && !ok {
+		return State{},
+			fmt.Errorf("error casting 'Label' field of unknown " +
+				"state to 'string', invalid State")
+	}
+	meta, err := metaOf(s)
+	if err != nil {
+		return State{}, fmt.Errorf("%v of unknown state, invalid State", err)
+	}
 	return State{
 		Id:     id,
 		Ending: ending,
+		Label:  label,
+		Meta:   meta,
 	}, nil
 }
 
-func addTransitions(mach *Graph, document map[string]interface{}) error {
+func addTransitions(
+	mach *Graph,
+	index map[string]*State,
+	document map[string]interface{},
+) Diagnostics {
 	transitions, ok := document["Transitions"]
 	if !ok {
-		return fmt.Errorf("'Transitions' key not found within document")
+		return Diagnostics{Errorf(Pointer("Transitions"), CodeInvalidDocument,
+			"'Transitions' key not found within document")}
 	}
 	unknownTransitions, ok := transitions.([]interface{})
 	if !ok {
-		return fmt.Errorf("'Transitions' key in document is not valid: %v", transitions)
-	}
-	for _, t := range unknownTransitions {
-		tt, err := addTransition(mach, t)
-		if err != nil {
-			return fmt.Errorf("invalid Transitions set: %v", err)
+		return Diagnostics{Errorf(Pointer("Transitions"), CodeInvalidDocument,
+			"'Transitions' key in document is not valid: %v", transitions)}
+	}
+	var diags Diagnostics
+	for i, t := range unknownTransitions {
+		tt, errs := addTransition(index, i, t)
+		if len(errs) > 0 {
+			diags = append(diags, errs...)
+			continue
 		}
 		mach.Transitions = append(mach.Transitions, tt)
 	}
-	return nil
+	return diags
 }
 
-func addTransition(mach *Graph, unknown interface{}) (Transition, error) {
+func addTransition(
+	index map[string]*State,
+	i int,
+	unknown interface{},
+) (Transition, Diagnostics) {
 	t, ok := unknown.(map[string]interface{})
 	if !ok {
-		return Transition{},
-			fmt.Errorf("error casting unknown Transition to 'map', invalid Transition")
+		return Transition{}, Diagnostics{Errorf(
+			Pointer("Transitions", i), CodeInvalidDocument,
+			"error casting unknown Transition to 'map', invalid Transition")}
 	}
-	symbol, ok := t["Symbol"].(string)
-	if !ok {
-		return Transition{},
-			fmt.Errorf("error casting 'Symbol' field of unknown " +
-				"Transition to 'map', invalid Transition")
+	otherwise, ok := t["Otherwise"].(bool)
+	if _, present := t["Otherwise"]; present && !ok {
+		return Transition{}, Diagnostics{Errorf(
+			Pointer("Transitions", i, "Otherwise"), CodeInvalidDocument,
+			"error casting 'Otherwise' field of unknown "+
+				"Transition to 'bool', invalid Transition")}
 	}
-	tt := Transition{
-		Symbol: symbol,
+	symbol, ok := t["Symbol"].(string)
+	if _, present := t["Symbol"]; !ok && (present || !otherwise) {
+		return Transition{}, Diagnostics{Errorf(
+			Pointer("Transitions", i, "Symbol"), CodeInvalidDocument,
+			"error casting 'Symbol' field of unknown "+
+				"Transition to 'string', invalid Transition")}
 	}
 
-	start, err := findStateById(mach, t["Start"].(string))
+	meta, err := metaOf(t)
 	if err != nil {
-		return Transition{}, err
+		return Transition{}, Diagnostics{Errorf(
+			Pointer("Transitions", i, "Meta"), CodeInvalidDocument,
+			"%v of unknown Transition, invalid Transition", err)}
+	}
+
+	var diags Diagnostics
+	endpoint := func(field string) *State {
+		id, _ := t[field].(string)
+		s, ok := index[id]
+		if !ok {
+			diags = append(diags, Errorf(
+				Pointer("Transitions", i, field), CodeUnknownState,
+				"state with id '%v' was not found in machine", id))
+		}
+		return s
 	}
 
-	end, err := findStateById(mach, t["End"].(string))
-	if err != nil {
-		return Transition{}, err
+	tt := Transition{
+		Start:     endpoint("Start"),
+		End:       endpoint("End"),
+		Symbol:    symbol,
+		Otherwise: otherwise,
+		Meta:      meta,
 	}
-
-	tt.Start = start
-	tt.End = end
-
-	return tt, nil
+	return tt, diags
 }
 
-func addStartState(mach *Graph, document map[string]interface{}) error {
+func addStartState(
+	mach *Graph,
+	index map[string]*State,
+	document map[string]interface{},
+) Diagnostics {
 	state, ok := document["Start"]
 	if !ok {
-		return fmt.Errorf("'Start' key not found within document")
+		return Diagnostics{Errorf(Pointer("Start"), CodeInvalidDocument,
+			"'Start' key not found within document")}
 	}
 
 	stateId, ok := state.(string)
 	if !ok {
-		return fmt.Errorf("'Start' key in document is not valid: %v", state)
+		return Diagnostics{Errorf(Pointer("Start"), CodeInvalidDocument,
+			"'Start' key in document is not valid: %v", state)}
 	}
 
-	foundState, err := findStateById(mach, stateId)
-	if err != nil {
-		return fmt.Errorf("state with id '%v' was not found in state set", stateId)
+	foundState, ok := index[stateId]
+	if !ok {
+		return Diagnostics{Errorf(Pointer("Start"), CodeUnknownState,
+			"state with id '%v' was not found in state set", stateId)}
 	}
 
 	mach.Start = foundState
 	return nil
 }
 
-func findStateById(mach *Graph, id string) (*State, error) {
-	for i, s := range mach.States {
-		if s.Id == id {
-			return &mach.States[i], nil
-		}
+// Maps state ids to the states of the graph. The graph's state slice must not
+// be appended to while the index is in use
+func stateIndex(mach *Graph) map[string]*State {
+	index := make(map[string]*State, len(mach.States))
+	for i := range mach.States {
+		index[mach.States[i].Id] = &mach.States[i]
 	}
-	return nil, fmt.Errorf("state with id '%v' was not found in machine", id)
+	return index
 }
 
-func schemaOrDefault(schema interface{}) (map[string]interface{}, error) {
+func schemaOrDefault(
+	schema interface{},
+	document map[string]interface{},
+) (map[string]interface{}, error) {
 	if schema != nil {
-		return LoadMap(schema)
+		return readMap(schema)
 	} else {
-		return GetSchema(), nil
+		return schemaOf(document), nil
+	}
+}
+
+// The list of objects in a field of a document, such as 'States'. Documents
+// read from JSON hold `[]interface{}`, while `JsonMap` produces
+// `[]map[string]interface{}`. Elements that are not objects are skipped
+func ObjectList(unknown interface{}) []map[string]interface{} {
+	switch v := unknown.(type) {
+	case []map[string]interface{}:
+		return v
+	case []interface{}:
+		list := make([]map[string]interface{}, 0, len(v))
+		for _, o := range v {
+			if m, ok := o.(map[string]interface{}); ok {
+				list = append(list, m)
+			}
+		}
+		return list
 	}
+	return nil
 }
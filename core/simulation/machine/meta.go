@@ -0,0 +1,52 @@
+package machine
+
+import "fmt"
+
+// Free-form data about a machine, state or transition that is not used in
+// simulation, such as comments, or positions and colors in an editor. It is
+// kept as it is when a machine is loaded and dumped
+type Meta map[string]interface{}
+
+// Deep copies the meta, which holds values as produced by `encoding/json`
+func (m Meta) Copy() Meta {
+	if m == nil {
+		return nil
+	}
+	return copyValue(map[string]interface{}(m)).(map[string]interface{})
+}
+
+// The meta in a form that can be placed in a `JsonMap`
+func (m Meta) JsonValue() map[string]interface{} {
+	return m.Copy()
+}
+
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, vv := range v {
+			m[k] = copyValue(vv)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, vv := range v {
+			l[i] = copyValue(vv)
+		}
+		return l
+	}
+	return v
+}
+
+// Reads the 'Meta' field of an object in a document, which may be absent
+func metaOf(object map[string]interface{}) (Meta, error) {
+	unknown, present := object["Meta"]
+	if !present {
+		return nil, nil
+	}
+	m, ok := unknown.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("error casting 'Meta' field to 'map'")
+	}
+	return Meta(m).Copy(), nil
+}
@@ -0,0 +1,65 @@
+package machine
+
+import (
+	"math"
+	"regexp"
+)
+
+// Upgrades documents of each version to the next one: `migrations[v]` turns a
+// document of version v into one of version v+1
+var migrations = []func(document map[string]interface{}){
+	migrateFromUnversioned,
+}
+
+// Upgrades a document to the current `SchemaVersion`. Documents without a
+// 'SchemaVersion' are of version 0, the format from before versioning. The
+// document is not modified, an upgraded copy is returned (or the document
+// itself if it is already current)
+func Migrate(document map[string]interface{}) (map[string]interface{}, error) {
+	version := 0
+	if unknown, ok := document["SchemaVersion"]; ok {
+		v, ok := unknown.(float64)
+		if i, isInt := unknown.(int); isInt {
+			v, ok = float64(i), true
+		}
+		if !ok || v != math.Trunc(v) || v < 0 {
+			return nil, Diagnostics{Errorf(
+				Pointer("SchemaVersion"), CodeInvalidDocument,
+				"'SchemaVersion' must be a non-negative integer, got '%v'", unknown)}
+		}
+		if v > SchemaVersion {
+			return nil, Diagnostics{Errorf(
+				Pointer("SchemaVersion"), CodeUnsupportedSchemaVersion,
+				"schema version %v is not supported, the latest version is %v",
+				v, SchemaVersion)}
+		}
+		version = int(v)
+	}
+	if version == SchemaVersion {
+		return document, nil
+	}
+
+	migrated := copyValue(document).(map[string]interface{})
+	for ; version < SchemaVersion; version++ {
+		migrations[version](migrated)
+	}
+	migrated["SchemaVersion"] = float64(SchemaVersion)
+	return migrated, nil
+}
+
+// The pattern that version 0 matched 'Type' against
+var unversionedType = regexp.MustCompile(`DFA|NFA|PDA|TM`)
+
+// Version 0 only checked that 'Type' contained the name of a type of machine,
+// so types like "DFA machine" were accepted. Version 1 wants the exact name
+func migrateFromUnversioned(document map[string]interface{}) {
+	t, ok := document["Type"].(string)
+	if !ok {
+		return
+	}
+	if parsed := ParseMachineType(t); parsed != "" {
+		document["Type"] = parsed
+	} else if name := unversionedType.FindString(t); name != "" {
+		document["Type"] = name
+	}
+}
@@ -0,0 +1,324 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Applies a JSON Patch (RFC 6902) to a document: a list of operations like
+// {"op": "replace", "path": "/States/1/Ending", "value": true}. The document
+// is not modified, a patched copy is returned. If an operation fails, the
+// error is a `Diagnostics` pointing at the operation in the patch
+func JsonPatch(document map[string]interface{}, patch interface{}) (map[string]interface{}, error) {
+	doc, err := normalize(document)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := normalize(patch)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := ops.([]interface{})
+	if !ok {
+		return nil, Diagnostics{Errorf("", CodeInvalidPatch,
+			"a JSON Patch must be a list of operations")}
+	}
+
+	for i, unknown := range list {
+		op, ok := unknown.(map[string]interface{})
+		if !ok {
+			return nil, Diagnostics{Errorf(Pointer(i), CodeInvalidPatch,
+				"operation must be an object, got '%v'", unknown)}
+		}
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, Diagnostics{Errorf(Pointer(i), CodeInvalidPatch,
+				"'%v' operation failed: %v", op["op"], err)}
+		}
+	}
+
+	patched, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, Diagnostics{Errorf("", CodeInvalidPatch,
+			"the patched document is not an object")}
+	}
+	return patched, nil
+}
+
+// Applies a JSON Merge Patch (RFC 7386) to a document: an object whose fields
+// replace those of the document, where null deletes a field. The document is
+// not modified, a patched copy is returned
+func MergePatch(document map[string]interface{}, patch interface{}) (map[string]interface{}, error) {
+	doc, err := normalize(document)
+	if err != nil {
+		return nil, err
+	}
+	p, err := normalize(patch)
+	if err != nil {
+		return nil, err
+	}
+	patched, ok := mergePatch(doc, p).(map[string]interface{})
+	if !ok {
+		return nil, Diagnostics{Errorf("", CodeInvalidPatch,
+			"a merge patch of a machine must be an object")}
+	}
+	return patched, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+func applyOperation(doc interface{}, op map[string]interface{}) (interface{}, error) {
+	path, err := pointerField(op, "path")
+	if err != nil {
+		return nil, err
+	}
+	value, hasValue := op["value"]
+
+	switch op["op"] {
+	case "add", "replace", "test":
+		if !hasValue {
+			return nil, fmt.Errorf("missing 'value'")
+		}
+	case "move", "copy":
+		from, err := pointerField(op, "from")
+		if err != nil {
+			return nil, err
+		}
+		if value, err = valueAt(doc, from); err != nil {
+			return nil, err
+		}
+		if op["op"] == "copy" {
+			value = copyValue(value)
+		} else if isPrefix(from, path) {
+			return nil, fmt.Errorf("cannot move a value into itself")
+		} else if doc, err = removeAt(doc, from); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+
+	switch op["op"] {
+	case "add", "move", "copy":
+		return addAt(doc, path, value)
+	case "remove":
+		return removeAt(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if _, err := valueAt(doc, path); err != nil {
+			return nil, err
+		}
+		if doc, err = removeAt(doc, path); err != nil {
+			return nil, err
+		}
+		return addAt(doc, path, value)
+	default: // test
+		actual, err := valueAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		a, _ := json.Marshal(actual)
+		e, _ := json.Marshal(value)
+		if string(a) != string(e) {
+			return nil, fmt.Errorf("value at '%v' is %s, not %s",
+				Pointer(toInterfaces(path)...), a, e)
+		}
+		return doc, nil
+	}
+}
+
+// The value at a JSON pointer
+func valueAt(doc interface{}, path []string) (interface{}, error) {
+	for i, token := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("'%v' does not exist", Pointer(toInterfaces(path[:i+1])...))
+			}
+			doc = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[index]
+		default:
+			return nil, fmt.Errorf("'%v' does not exist", Pointer(toInterfaces(path[:i+1])...))
+		}
+	}
+	return doc, nil
+}
+
+// Adds a value at a JSON pointer: a field of an object is set, and a value is
+// inserted into an array ('-' appends)
+func addAt(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			index := len(v)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(v)); err != nil {
+					return nil, err
+				}
+			}
+			v = append(v, nil)
+			copy(v[index+1:], v[index:])
+			v[index] = value
+			return v, nil
+		}
+		return nil, fmt.Errorf("'%v' is not in an object or array", Pointer(toInterfaces(path)...))
+	})
+}
+
+// Removes the value at a JSON pointer
+func removeAt(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	return updateAt(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			if _, ok := v[token]; !ok {
+				return nil, fmt.Errorf("'%v' does not exist", Pointer(toInterfaces(path)...))
+			}
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:index], v[index+1:]...), nil
+		}
+		return nil, fmt.Errorf("'%v' does not exist", Pointer(toInterfaces(path)...))
+	})
+}
+
+// Calls `f` with the object or array holding the value at `path`, and the last
+// token of `path`. The container is replaced by what `f` returns, since arrays
+// may be reallocated
+func updateAt(
+	doc interface{},
+	path []string,
+	f func(container interface{}, token string) (interface{}, error),
+) (interface{}, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+	child, err := valueAt(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updateAt(child, path[1:], f); err != nil {
+		return nil, err
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		v[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(v)-1)
+		v[index] = child
+	}
+	return doc, nil
+}
+
+// Parses an array index of a JSON pointer, which must be at most `max`
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || token[0] < '0' || token[0] > '9' || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("'%v' is not an array index", token)
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %v is out of bounds", index)
+	}
+	return index, nil
+}
+
+// Parses a JSON pointer (RFC 6901) in a field of an operation into its
+// reference tokens
+func pointerField(op map[string]interface{}, field string) ([]string, error) {
+	p, ok := op[field].(string)
+	if !ok {
+		return nil, fmt.Errorf("'%v' must be a JSON pointer", field)
+	}
+	if p == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("'%v' must start with '/'", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+	for i, t := range tokens {
+		tokens[i] = unescaper.Replace(t)
+	}
+	return tokens, nil
+}
+
+// Whether pointer `prefix` is a proper prefix of pointer `path`
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i, t := range prefix {
+		if path[i] != t {
+			return false
+		}
+	}
+	return true
+}
+
+func toInterfaces(tokens []string) []interface{} {
+	l := make([]interface{}, len(tokens))
+	for i, t := range tokens {
+		l[i] = t
+	}
+	return l
+}
+
+// Deep copies a value into the form produced by `encoding/json`, so that
+// documents built by `JsonMap` or read from YAML can be patched. A `[]byte` is
+// parsed as JSON
+func normalize(v interface{}) (interface{}, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, Diagnostics{Errorf("", CodeInvalidPatch, "%v", err)}
+	}
+	return normalized, nil
+}
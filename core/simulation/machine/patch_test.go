@@ -0,0 +1,144 @@
+package machine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const patchBase = `{
+	"Type": "DFA",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+	"Transitions": [{ "Start": "q0", "End": "q1", "Symbol": "a" }]
+}`
+
+func TestJsonPatch(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		patch    string
+		expected string
+		pointer  string
+	}{
+		{
+			name: "add-replace-remove",
+			patch: `[
+				{ "op": "add", "path": "/States/-", "value": { "Id": "q2" } },
+				{ "op": "add", "path": "/Transitions/0", "value": { "Start": "q1", "End": "q2", "Symbol": "b" } },
+				{ "op": "replace", "path": "/States/1/Ending", "value": false },
+				{ "op": "remove", "path": "/Transitions/1" },
+				{ "op": "add", "path": "/Meta", "value": { "a/b": 1 } },
+				{ "op": "replace", "path": "/Meta/a~1b", "value": 2 }
+			]`,
+			expected: `{
+				"Type": "DFA",
+				"Start": "q0",
+				"Meta": { "a/b": 2 },
+				"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": false }, { "Id": "q2" }],
+				"Transitions": [{ "Start": "q1", "End": "q2", "Symbol": "b" }]
+			}`,
+		},
+		{
+			name: "move-copy-test",
+			patch: `[
+				{ "op": "test", "path": "/States/1", "value": { "Ending": true, "Id": "q1" } },
+				{ "op": "copy", "from": "/States/0", "path": "/States/-" },
+				{ "op": "replace", "path": "/States/2/Id", "value": "q2" },
+				{ "op": "move", "from": "/States/0", "path": "/States/2" }
+			]`,
+			expected: `{
+				"Type": "DFA",
+				"Start": "q0",
+				"States": [{ "Id": "q1", "Ending": true }, { "Id": "q2" }, { "Id": "q0" }],
+				"Transitions": [{ "Start": "q0", "End": "q1", "Symbol": "a" }]
+			}`,
+		},
+		{
+			name:    "failed-test",
+			patch:   `[{ "op": "test", "path": "/Start", "value": "q1" }]`,
+			pointer: "/0",
+		},
+		{
+			name: "missing-path",
+			patch: `[
+				{ "op": "add", "path": "/Meta", "value": {} },
+				{ "op": "remove", "path": "/States/5" }
+			]`,
+			pointer: "/1",
+		},
+		{
+			name:    "bad-index",
+			patch:   `[{ "op": "add", "path": "/States/01", "value": {} }]`,
+			pointer: "/0",
+		},
+		{
+			name:    "missing-value",
+			patch:   `[{ "op": "replace", "path": "/Start" }]`,
+			pointer: "/0",
+		},
+		{
+			name:    "move-into-itself",
+			patch:   `[{ "op": "move", "from": "/States", "path": "/States/0" }]`,
+			pointer: "/0",
+		},
+		{
+			name:    "unknown-op",
+			patch:   `[{ "op": "frobnicate", "path": "/Start" }]`,
+			pointer: "/0",
+		},
+		{
+			name:    "not-a-list",
+			patch:   `{ "op": "remove", "path": "/Start" }`,
+			pointer: "",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var doc map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(patchBase), &doc))
+			patched, err := JsonPatch(doc, []byte(tc.patch))
+			if tc.expected == "" {
+				diags := DiagnosticsOf(err)
+				if assert.Len(t, diags, 1) {
+					assert.Equal(t, CodeInvalidPatch, diags[0].Code)
+					assert.Equal(t, tc.pointer, diags[0].Pointer)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			actual, _ := json.Marshal(patched)
+			assert.JSONEq(t, tc.expected, string(actual))
+
+			// The document itself is left as it was
+			original, _ := json.Marshal(doc)
+			assert.JSONEq(t, patchBase, string(original))
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(patchBase), &doc))
+	patched, err := MergePatch(doc, map[string]interface{}{
+		"Start":    "q1",
+		"Alphabet": "ab",
+		"Meta":     map[string]interface{}{"Author": "someone", "Draft": nil},
+		"Type":     nil,
+		"States":   []interface{}{map[string]interface{}{"Id": "q1"}},
+	})
+	assert.NoError(t, err)
+	actual, _ := json.Marshal(patched)
+	assert.JSONEq(t, `{
+		"Start": "q1",
+		"Alphabet": "ab",
+		"Meta": { "Author": "someone" },
+		"States": [{ "Id": "q1" }],
+		"Transitions": [{ "Start": "q0", "End": "q1", "Symbol": "a" }]
+	}`, string(actual))
+	assert.Equal(t, "DFA", doc["Type"])
+
+	_, err = MergePatch(doc, []byte(`["not", "an", "object"]`))
+	assert.Equal(t, CodeInvalidPatch, DiagnosticsOf(err)[0].Code)
+}
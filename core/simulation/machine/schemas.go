@@ -0,0 +1,118 @@
+package machine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The version of the document format that the schemas describe, see `Migrate`
+const SchemaVersion = 1
+
+// Where schemas are published
+const schemaHost = "https://machinist.flapflap.io"
+
+// The Go type that describes the documents of each type of machine
+var documentTypes = []struct {
+	machineType string
+	title       string
+	document    interface{}
+}{
+	{DFA, "Deterministic Finite Automaton", DFADocument{}},
+	{NFA, "Non-Deterministic Finite Automaton", NFADocument{}},
+	{PDA, "Pushdown Automaton", PDADocument{}},
+	{TM, "Turing Machine", TMDocument{}},
+}
+
+// Schemas are generated once, on first use
+var schemas struct {
+	sync.Once
+	byType   map[string]map[string]interface{}
+	combined map[string]interface{}
+}
+
+// The schema of documents of a type of machine, or nil if the type is not
+// known. The schema is shared, it must not be modified
+func Schema(machineType string) map[string]interface{} {
+	generateSchemas()
+	return schemas.byType[machineType]
+}
+
+// The schema of documents of any type of machine: a `oneOf` of the schemas of
+// each type. The schema is shared, it must not be modified
+func GetSchema() map[string]interface{} {
+	generateSchemas()
+	return schemas.combined
+}
+
+// The path where the schema of a type of machine is served and published e.g.
+// "/schemas/v1/dfa.schema.json"
+func SchemaPath(machineType string) string {
+	return fmt.Sprintf("/schemas/v%v/%v.schema.json",
+		SchemaVersion, strings.ToLower(machineType))
+}
+
+// The types of machines that have a schema
+func SchemaTypes() []string {
+	types := make([]string, len(documentTypes))
+	for i, d := range documentTypes {
+		types[i] = d.machineType
+	}
+	return types
+}
+
+func generateSchemas() {
+	schemas.Do(func() {
+		schemas.byType = map[string]map[string]interface{}{}
+		var alternatives []interface{}
+		for _, d := range documentTypes {
+			s := generateSchema(reflect.TypeOf(d.document))
+			props := s["properties"].(map[string]interface{})
+			props["Type"].(map[string]interface{})["const"] = d.machineType
+			props["SchemaVersion"].(map[string]interface{})["maximum"] = float64(SchemaVersion)
+			s["title"] = d.title
+
+			alternatives = append(alternatives, s)
+			published := map[string]interface{}{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"$id":     schemaHost + SchemaPath(d.machineType),
+			}
+			for k, v := range s {
+				published[k] = v
+			}
+			schemas.byType[d.machineType] = published
+		}
+
+		types := []interface{}{}
+		for _, t := range SchemaTypes() {
+			types = append(types, t)
+		}
+		schemas.combined = map[string]interface{}{
+			"$schema":     "https://json-schema.org/draft/2020-12/schema",
+			"$id":         schemaHost + "/machine.schema.json",
+			"title":       "Machine",
+			"description": "A graph datastructure representing a state machine",
+			"type":        "object",
+			"properties": map[string]interface{}{
+				"Type": map[string]interface{}{
+					"description": "What type of state machine this is",
+					"enum":        types,
+				},
+			},
+			"required": []interface{}{"Type"},
+			"oneOf":    alternatives,
+		}
+	})
+}
+
+// The schema that a document is validated against: the schema of its type, or
+// the combined schema if its type is not known
+func schemaOf(document map[string]interface{}) map[string]interface{} {
+	if t, ok := document["Type"].(string); ok {
+		if s := Schema(t); s != nil {
+			return s
+		}
+	}
+	return GetSchema()
+}
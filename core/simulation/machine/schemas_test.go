@@ -0,0 +1,115 @@
+package machine
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the published schema files")
+
+// The schema files are published (e.g. docs/example-machine.json refers to
+// machine.schema.json), run `go test -run TestSchemaFiles -update` to rewrite
+// them after changing a document type
+func TestSchemaFilesAreUpToDate(t *testing.T) {
+	files := map[string]map[string]interface{}{"machine.schema.json": GetSchema()}
+	for _, mt := range SchemaTypes() {
+		files[filepath.Join(".", filepath.FromSlash(SchemaPath(mt)))] = Schema(mt)
+	}
+	for file, schema := range files {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		assert.NoError(t, encoder.Encode(schema))
+
+		if *update {
+			assert.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+			assert.NoError(t, os.WriteFile(file, buf.Bytes(), 0644))
+			continue
+		}
+		published, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Equal(t, buf.String(), string(published),
+			"%v is out of date, run the tests with -update", file)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	dfa := Schema(DFA)
+	assert.Equal(t, "https://machinist.flapflap.io/schemas/v1/dfa.schema.json", dfa["$id"])
+	assert.Nil(t, Schema("Mealy"))
+
+	props := Schema(TM)["properties"].(map[string]interface{})
+	move := props["Transitions"].(map[string]interface{})["items"].(map[string]interface{})["properties"].(map[string]interface{})["Move"]
+	assert.Equal(t, []interface{}{"L", "R", "S"}, move.(map[string]interface{})["enum"])
+	assert.ElementsMatch(t,
+		[]interface{}{"Type", "Start", "States", "Transitions"}, Schema(PDA)["required"])
+
+	for _, tc := range []struct {
+		name   string
+		schema map[string]interface{}
+		doc    string
+		valid  bool
+	}{
+		{"dfa", dfa, `{"Type": "DFA", "Start": "q0", "States": [{"Id": "q0"}], "Transitions": [{"Start": "q0", "End": "q0", "Otherwise": true}]}`, true},
+		{"dfa-wrong-type", dfa, `{"Type": "NFA", "Start": "q0", "States": [], "Transitions": []}`, false},
+		{"dfa-future-version", dfa, `{"SchemaVersion": 2, "Type": "DFA", "Start": "q0", "States": [], "Transitions": []}`, false},
+		{"pda", GetSchema(), `{"Type": "PDA", "Start": "q0", "States": [], "Transitions": [{"Start": "q0", "End": "q0", "Symbol": "", "Pop": "Z", "Push": "AZ"}]}`, true},
+		{"pda-missing-symbol", GetSchema(), `{"Type": "PDA", "Start": "q0", "States": [], "Transitions": [{"Start": "q0", "End": "q0", "Otherwise": true}]}`, false},
+		{"tm", GetSchema(), `{"Type": "TM", "Start": "q0", "States": [], "Transitions": [{"Start": "q0", "End": "q0", "Symbol": "1", "Write": "0", "Move": "R"}]}`, true},
+		{"tm-bad-move", GetSchema(), `{"Type": "TM", "Start": "q0", "States": [], "Transitions": [{"Start": "q0", "End": "q0", "Symbol": "1", "Move": "Up"}]}`, false},
+		{"unknown-type", GetSchema(), `{"Type": "Mealy", "Start": "q0", "States": [], "Transitions": []}`, false},
+	} {
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(tc.doc), &doc))
+		res, err := ValidateJson(tc.schema, doc)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.valid, res.Valid(), "%v: %v", tc.name, res.Errors())
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for typ, expected := range map[string]string{
+		"DFA":                "DFA",
+		"my DFA":             "DFA",
+		"pushdown automaton": "PDA",
+		"Mealy":              "Mealy",
+	} {
+		doc := map[string]interface{}{"Type": typ}
+		migrated, err := Migrate(doc)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"Type":          expected,
+			"SchemaVersion": float64(SchemaVersion),
+		}, migrated)
+		assert.Equal(t, map[string]interface{}{"Type": typ}, doc, "the document is not modified")
+	}
+
+	current := map[string]interface{}{"Type": "DFA", "SchemaVersion": 1.0}
+	migrated, err := Migrate(current)
+	assert.NoError(t, err)
+	assert.Equal(t, current, migrated)
+
+	for _, version := range []interface{}{2.0, -1.0, 0.5, "1"} {
+		_, err := Migrate(map[string]interface{}{"Type": "DFA", "SchemaVersion": version})
+		assert.Error(t, err, "version %v", version)
+	}
+	_, err = Migrate(map[string]interface{}{"SchemaVersion": 2.0})
+	assert.Equal(t, CodeUnsupportedSchemaVersion, DiagnosticsOf(err)[0].Code)
+
+	// Documents are migrated when they are read
+	doc, err := LoadMap([]byte(`{"Type": "a DFA"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"Type": "DFA", "SchemaVersion": 1.0}, doc)
+
+	// Unversioned documents with loose types load
+	g, err := Load([]byte(`{"Type": "a DFA", "Start": "q0", "States": [{"Id": "q0"}], "Transitions": []}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "q0", g.Start.Id)
+}
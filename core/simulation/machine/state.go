@@ -8,6 +8,11 @@ import (
 type State struct {
 	Id     string `json:"Id"`
 	Ending bool   `json:"Ending"`
+
+	// An optional name to display instead of the id
+	Label string `json:"Label,omitempty"`
+
+	Meta Meta `json:"Meta,omitempty"`
 }
 
 func (s State) String() string {
@@ -24,12 +29,27 @@ func (s State) Json() string {
 }
 
 func (s State) JsonMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"Id":     s.Id,
 		"Ending": s.Ending,
 	}
+	if s.Label != "" {
+		m["Label"] = s.Label
+	}
+	if s.Meta != nil {
+		m["Meta"] = s.Meta.JsonValue()
+	}
+	return m
+}
+
+// The label of the state, or its id if it has no label
+func (s State) Name() string {
+	if s.Label != "" {
+		return s.Label
+	}
+	return s.Id
 }
 
 func (s State) Copy() State {
-	return State{Id: s.Id, Ending: s.Ending}
+	return State{Id: s.Id, Ending: s.Ending, Label: s.Label, Meta: s.Meta.Copy()}
 }
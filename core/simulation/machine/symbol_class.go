@@ -0,0 +1,121 @@
+package machine
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The most symbols that a class may expand to when its members are listed
+const maxClassMembers = 4096
+
+// A set of symbols written in the 'Symbol' field of a transition. Two forms are
+// supported, and they can be combined:
+//
+//	"[a-z0-9_]"  a bracket class of characters and character ranges (use a
+//	             backslash to escape ']', '-' or '\')
+//	"a,b,c"      a comma separated list of symbols (each item may also be a
+//	             bracket class e.g. "[a-z],_")
+type SymbolClass struct {
+	symbols map[string]bool
+	order   []string
+	ranges  []runeRange
+}
+
+type runeRange struct{ lo, hi rune }
+
+// Whether `s` uses the syntax of a symbol class. Note that a symbol that is
+// part of the alphabet should always be treated as a literal, even if it looks
+// like a class
+func IsSymbolClass(s string) bool {
+	return isBracketClass(s) || (len(s) > 1 && strings.Contains(s, ","))
+}
+
+func ParseSymbolClass(s string) (*SymbolClass, error) {
+	c := &SymbolClass{symbols: map[string]bool{}}
+	if isBracketClass(s) {
+		return c, c.addBracketClass(s)
+	}
+	for _, item := range strings.Split(s, ",") {
+		switch {
+		case item == "":
+			return nil, fmt.Errorf("symbol class '%v' contains an empty symbol", s)
+		case isBracketClass(item):
+			if err := c.addBracketClass(item); err != nil {
+				return nil, err
+			}
+		default:
+			c.add(item)
+		}
+	}
+	return c, nil
+}
+
+func (c *SymbolClass) Contains(symbol string) bool {
+	if c.symbols[symbol] {
+		return true
+	}
+	r, size := utf8.DecodeRuneInString(symbol)
+	if size == 0 || size != len(symbol) {
+		return false
+	}
+	for _, rr := range c.ranges {
+		if rr.lo <= r && r <= rr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// Lists every symbol of the class. Returns an error if the class is too large
+// to be listed
+func (c *SymbolClass) Members() ([]string, error) {
+	members := append([]string{}, c.order...)
+	for _, rr := range c.ranges {
+		if len(members)+int(rr.hi-rr.lo) >= maxClassMembers {
+			return nil, fmt.Errorf(
+				"symbol class has more than %v members", maxClassMembers)
+		}
+		for r := rr.lo; r <= rr.hi; r++ {
+			members = append(members, string(r))
+		}
+	}
+	return members, nil
+}
+
+func (c *SymbolClass) add(symbol string) {
+	if !c.symbols[symbol] {
+		c.symbols[symbol] = true
+		c.order = append(c.order, symbol)
+	}
+}
+
+func isBracketClass(s string) bool {
+	return len(s) > 2 && s[0] == '[' && s[len(s)-1] == ']'
+}
+
+func (c *SymbolClass) addBracketClass(s string) error {
+	body := []rune(s[1 : len(s)-1])
+	next := func(i int) (rune, int) {
+		if body[i] == '\\' && i+1 < len(body) {
+			return body[i+1], i + 2
+		}
+		return body[i], i + 1
+	}
+	for i := 0; i < len(body); {
+		lo, j := next(i)
+		if j+1 < len(body) && body[j] == '-' {
+			hi, k := next(j + 1)
+			if hi < lo {
+				return fmt.Errorf(
+					"invalid range '%c-%c' in symbol class '%v'", lo, hi, s)
+			}
+			c.ranges = append(c.ranges, runeRange{lo, hi})
+			i = k
+			continue
+		}
+		c.add(string(lo))
+		i = j
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSymbolClass(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		class    string
+		in       []string
+		notIn    []string
+		members  []string
+		hasError bool
+	}{
+		{
+			name:    "range",
+			class:   "[a-c]",
+			in:      []string{"a", "b", "c"},
+			notIn:   []string{"d", "ab", ""},
+			members: []string{"a", "b", "c"},
+		},
+		{
+			name:    "ranges-and-characters",
+			class:   "[a-b0-1_]",
+			in:      []string{"a", "1", "_"},
+			notIn:   []string{"c", "-"},
+			members: []string{"_", "a", "b", "0", "1"},
+		},
+		{
+			name:    "escapes",
+			class:   `[\]\-]`,
+			in:      []string{"]", "-"},
+			notIn:   []string{`\`},
+			members: []string{"]", "-"},
+		},
+		{
+			name:    "list",
+			class:   "if,else,[x-y]",
+			in:      []string{"if", "else", "x", "y"},
+			notIn:   []string{"i", "if,else"},
+			members: []string{"if", "else", "x", "y"},
+		},
+		{name: "unicode", class: "[α-γ]", in: []string{"β"}, notIn: []string{"a"}},
+		{name: "reversed-range", class: "[z-a]", hasError: true},
+		{name: "empty-item", class: "a,,b", hasError: true},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.True(t, IsSymbolClass(tc.class))
+			c, err := ParseSymbolClass(tc.class)
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for _, s := range tc.in {
+				assert.True(t, c.Contains(s), "symbol: %v", s)
+			}
+			for _, s := range tc.notIn {
+				assert.False(t, c.Contains(s), "symbol: %v", s)
+			}
+			if tc.members != nil {
+				members, err := c.Members()
+				assert.NoError(t, err)
+				assert.Equal(t, tc.members, members)
+			}
+		})
+	}
+}
+
+func TestIsSymbolClass(t *testing.T) {
+	for s, expected := range map[string]bool{
+		"a": false, ",": false, "[": false, "[]": false, "if": false,
+		"[a]": true, "a,b": true,
+	} {
+		assert.Equal(t, expected, IsSymbolClass(s), "symbol: %v", s)
+	}
+}
+
+func TestMembersOfLargeClass(t *testing.T) {
+	c, err := ParseSymbolClass("[\u0000-￿]")
+	assert.NoError(t, err)
+	assert.True(t, c.Contains("€"))
+	_, err = c.Members()
+	assert.Error(t, err)
+}
@@ -9,6 +9,12 @@ type Transition struct {
 	Start  *State `json:"Start"`
 	End    *State `json:"End"`
 	Symbol string `json:"Symbol"`
+
+	// An "otherwise" transition is taken when no other transition leaving its
+	// start state matches the next symbol on the tape. It has no symbol
+	Otherwise bool `json:"Otherwise,omitempty"`
+
+	Meta Meta `json:"Meta,omitempty"`
 }
 
 func (s Transition) String() string {
@@ -25,17 +31,29 @@ func (s Transition) Json() string {
 }
 
 func (s Transition) JsonMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"Start":  s.Start.Id,
 		"End":    s.End.Id,
 		"Symbol": s.Symbol,
 	}
+	if s.Otherwise {
+		m["Otherwise"] = true
+		if s.Symbol == "" {
+			delete(m, "Symbol")
+		}
+	}
+	if s.Meta != nil {
+		m["Meta"] = s.Meta.JsonValue()
+	}
+	return m
 }
 
 func (s Transition) Copy() *Transition {
 	return &Transition{
-		Start:  s.Start,
-		End:    s.End,
-		Symbol: s.Symbol,
+		Start:     s.Start,
+		End:       s.End,
+		Symbol:    s.Symbol,
+		Otherwise: s.Otherwise,
+		Meta:      s.Meta.Copy(),
 	}
 }
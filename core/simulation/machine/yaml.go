@@ -0,0 +1,194 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alternative names accepted for the keys of YAML documents, in lower case.
+// An alternative name is only used where the schema has the key it maps to
+var keyAliases = map[string]string{
+	"startingstate": "Start",
+}
+
+// Whether the file at `path` is a YAML document, judging by its extension
+func IsYamlPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return true
+	}
+	return false
+}
+
+// Loads a machine document written in YAML. `document` can be a path to a
+// file, a `[]byte`, or an `io.Reader`. Keys are matched to the keys of the
+// schema regardless of case (so `start` and `Start` are the same), and
+// `startingState` is accepted in place of `Start`.
+// Scalars are kept as strings wherever the schema expects a string, so that
+// symbols like `0` or `y` are not read as numbers or booleans
+func LoadYamlMap(document interface{}) (map[string]interface{}, error) {
+	var buf []byte
+	var err error
+	switch d := document.(type) {
+	case map[string]interface{}:
+		return d, nil
+	case string:
+		buf, err = os.ReadFile(d)
+	case []byte:
+		buf = d
+	case io.Reader:
+		buf, err = io.ReadAll(d)
+	default:
+		return nil, fmt.Errorf(errMsg, "document")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, errors.New("cannot load machine from an empty buffer")
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf, &node); err != nil {
+		return nil, err
+	}
+	m, ok := fromYaml(&node, yamlSchema()).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid document, a YAML mapping was expected")
+	}
+	return m, nil
+}
+
+// The schema that guides the conversion of YAML documents: the schemas of every
+// type of machine merged into one, so that the keys of every type are known
+func yamlSchema() map[string]interface{} {
+	mergedSchema.Do(func() {
+		mergedSchema.value = map[string]interface{}{}
+		for _, t := range SchemaTypes() {
+			mergeSchema(mergedSchema.value, Schema(t))
+		}
+	})
+	return mergedSchema.value
+}
+
+var mergedSchema struct {
+	sync.Once
+	value map[string]interface{}
+}
+
+// Adds the properties and items of `from` that `into` does not have yet
+func mergeSchema(into, from map[string]interface{}) {
+	for k, v := range from {
+		if _, ok := into[k]; !ok {
+			into[k] = copyValue(v)
+		}
+	}
+	for _, keyword := range []string{"properties", "items"} {
+		a, _ := into[keyword].(map[string]interface{})
+		b, _ := from[keyword].(map[string]interface{})
+		if a == nil || b == nil {
+			continue
+		}
+		if keyword == "items" {
+			mergeSchema(a, b)
+			continue
+		}
+		for name, prop := range b {
+			pa, _ := a[name].(map[string]interface{})
+			pb, _ := prop.(map[string]interface{})
+			if pa != nil && pb != nil {
+				mergeSchema(pa, pb)
+			}
+		}
+	}
+}
+
+// Converts a YAML node to the values produced by `encoding/json`, guided by
+// the (sub)schema that the node should match
+func fromYaml(node *yaml.Node, schema map[string]interface{}) interface{} {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return fromYaml(node.Content[0], schema)
+	case yaml.AliasNode:
+		return fromYaml(node.Alias, schema)
+	case yaml.MappingNode:
+		props, _ := schema["properties"].(map[string]interface{})
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := canonicalKey(node.Content[i].Value, props)
+			sub, _ := props[key].(map[string]interface{})
+			m[key] = fromYaml(node.Content[i+1], sub)
+		}
+		return m
+	case yaml.SequenceNode:
+		items, _ := schema["items"].(map[string]interface{})
+		if items == nil {
+			items, _ = schemaAlternative(schema, "array")["items"].(map[string]interface{})
+		}
+		list := make([]interface{}, len(node.Content))
+		for i, c := range node.Content {
+			list[i] = fromYaml(c, items)
+		}
+		return list
+	}
+
+	if node.Tag != "!!null" && expectsString(schema) {
+		return node.Value
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return node.Value
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	}
+	return v
+}
+
+// Finds the key of the schema's properties that `key` refers to
+func canonicalKey(key string, props map[string]interface{}) string {
+	if _, ok := props[key]; ok {
+		return key
+	}
+	for p := range props {
+		if strings.EqualFold(p, key) {
+			return p
+		}
+	}
+	if alias, ok := keyAliases[strings.ToLower(key)]; ok {
+		if _, ok := props[alias]; ok {
+			return alias
+		}
+	}
+	return key
+}
+
+func expectsString(schema map[string]interface{}) bool {
+	return schema["type"] == "string" || schemaAlternative(schema, "string") != nil
+}
+
+// The alternative of a `oneOf` or `anyOf` schema that has the given type
+func schemaAlternative(schema map[string]interface{}, t string) map[string]interface{} {
+	for _, keyword := range []string{"oneOf", "anyOf"} {
+		alternatives, _ := schema[keyword].([]interface{})
+		for _, a := range alternatives {
+			if a, ok := a.(map[string]interface{}); ok && a["type"] == t {
+				return a
+			}
+		}
+	}
+	return nil
+}
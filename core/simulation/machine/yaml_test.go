@@ -0,0 +1,62 @@
+package machine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExampleYamlMatchesJson(t *testing.T) {
+	fromYaml, err := LoadMap("../../../docs/example-machine.yml")
+	assert.NoError(t, err)
+	fromJson, err := LoadMap("../../../docs/example-machine.json")
+	assert.NoError(t, err)
+	delete(fromJson, "$schema")
+	assert.Equal(t, fromJson, fromYaml)
+}
+
+func TestLoadYamlMap(t *testing.T) {
+	doc, err := LoadYamlMap([]byte(`
+type: DFA
+alphabet: [0, 1, y]
+startingState: 0
+states:
+  - id: 0
+    ending: true
+  - { ID: 1, label: one }
+transitions:
+  - { start: 0, end: 1, symbol: 1 }
+  - { start: 1, end: 0, SYMBOL: y, otherwise: true }
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Type":     "DFA",
+		"Alphabet": []interface{}{"0", "1", "y"},
+		"Start":    "0",
+		"States": []interface{}{
+			map[string]interface{}{"Id": "0", "Ending": true},
+			map[string]interface{}{"Id": "1", "Label": "one"},
+		},
+		"Transitions": []interface{}{
+			map[string]interface{}{"Start": "0", "End": "1", "Symbol": "1"},
+			map[string]interface{}{
+				"Start": "1", "End": "0", "Symbol": "y", "Otherwise": true,
+			},
+		},
+	}, doc)
+
+	g, err := Load(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "0", g.Start.Id)
+}
+
+func TestLoadYamlMapErrors(t *testing.T) {
+	for name, doc := range map[string]string{
+		"empty":    "",
+		"sequence": "- a\n- b",
+		"invalid":  "Start: [q0",
+	} {
+		_, err := LoadYamlMap([]byte(doc))
+		assert.Error(t, err, name)
+	}
+}
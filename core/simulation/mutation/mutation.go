@@ -0,0 +1,290 @@
+// Mutation testing of machines: small changes are made to a machine, and a
+// test suite that is strong enough should notice every change that alters
+// what the machine accepts
+package mutation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+)
+
+// A kind of change made to a machine
+type Operator string
+
+const (
+	// A transition goes to another state
+	RedirectTransition Operator = "RedirectTransition"
+
+	// An ending state stops being one, or the other way around
+	FlipEnding Operator = "FlipEnding"
+
+	// A transition reads another symbol of the alphabet
+	SwapSymbol Operator = "SwapSymbol"
+
+	// Another state is the start state
+	ChangeStart Operator = "ChangeStart"
+)
+
+// A change to a machine. `Transition` is the index of the changed transition,
+// and `State` and `Symbol` are what was changed to
+type Mutant struct {
+	Operator    Operator `json:"Operator"`
+	Description string   `json:"Description"`
+	Transition  *int     `json:"Transition,omitempty"`
+	State       string   `json:"State,omitempty"`
+	Symbol      string   `json:"Symbol,omitempty"`
+}
+
+// Lists the mutants of a DFA, in a fixed order: start states first, then
+// ending flags, redirected transitions and swapped symbols. "Otherwise"
+// transitions keep reading anything else, so their symbol is never swapped, and
+// a symbol is never swapped in if its state already reads it with another
+// transition. If `limit` is positive, only the first `limit` mutants are listed
+func Generate(d *dfa.DFA, limit int) []Mutant {
+	var mutants []Mutant
+	full := func() bool {
+		return limit > 0 && len(mutants) >= limit
+	}
+
+	for _, s := range d.States {
+		if full() {
+			return mutants
+		}
+		if s.Id != d.Start.Id {
+			mutants = append(mutants, Mutant{
+				Operator:    ChangeStart,
+				Description: fmt.Sprintf("start in state '%v' instead of '%v'", s.Id, d.Start.Id),
+				State:       s.Id,
+			})
+		}
+	}
+
+	for _, s := range d.States {
+		if full() {
+			return mutants
+		}
+		verb := "an ending state"
+		if s.Ending {
+			verb = "not an ending state"
+		}
+		mutants = append(mutants, Mutant{
+			Operator:    FlipEnding,
+			Description: fmt.Sprintf("make state '%v' %v", s.Id, verb),
+			State:       s.Id,
+		})
+	}
+
+	for i, t := range d.Transitions {
+		i := i
+		for _, s := range d.States {
+			if full() {
+				return mutants
+			}
+			if s.Id != t.End.Id {
+				mutants = append(mutants, Mutant{
+					Operator: RedirectTransition,
+					Description: fmt.Sprintf("transition %v (%v) goes to '%v' instead",
+						i, describe(t), s.Id),
+					Transition: &i,
+					State:      s.Id,
+				})
+			}
+		}
+	}
+
+	for i, t := range d.Transitions {
+		i := i
+		if t.Otherwise {
+			continue
+		}
+		for _, symbol := range d.Alphabet {
+			if full() {
+				return mutants
+			}
+			if symbol == t.Symbol {
+				continue
+			}
+
+			// The state would read the symbol with two transitions
+			if j, ok := d.TransitionOn(t.Start, symbol); ok && j != i {
+				continue
+			}
+			mutants = append(mutants, Mutant{
+				Operator: SwapSymbol,
+				Description: fmt.Sprintf("transition %v (%v) reads '%v' instead",
+					i, describe(t), symbol),
+				Transition: &i,
+				Symbol:     symbol,
+			})
+		}
+	}
+	return mutants
+}
+
+// A copy of the DFA with the change of the mutant. The copy is not required
+// to be a complete DFA, tapes that reach a missing transition are rejected
+func (m Mutant) Apply(d *dfa.DFA) *dfa.DFA {
+	states := make([]machine.State, len(d.States))
+	index := make(map[string]int, len(d.States))
+	for i, s := range d.States {
+		states[i] = s.Copy()
+		index[s.Id] = i
+		if m.Operator == FlipEnding && s.Id == m.State {
+			states[i].Ending = !s.Ending
+		}
+	}
+
+	// The states are added at once, so pointers into them stay valid
+	g := machine.NewBlankGraph().WithStates(states...)
+	state := func(id string) *machine.State {
+		if i, ok := index[id]; ok {
+			return &g.States[i]
+		}
+		return nil
+	}
+	g.Start = state(d.Start.Id)
+	if m.Operator == ChangeStart {
+		g.Start = state(m.State)
+	}
+	for i, t := range d.Transitions {
+		t := machine.Transition{
+			Start:     state(t.Start.Id),
+			End:       state(t.End.Id),
+			Symbol:    t.Symbol,
+			Otherwise: t.Otherwise,
+		}
+		if m.Transition != nil && *m.Transition == i {
+			switch m.Operator {
+			case RedirectTransition:
+				t.End = state(m.State)
+			case SwapSymbol:
+				t.Symbol = m.Symbol
+			}
+		}
+		g.WithTransition(t)
+	}
+
+	mutant := &dfa.DFA{
+		Graph:     g,
+		Alphabet:  d.Alphabet,
+		Separator: d.Separator,
+		Trap:      d.Trap,
+	}
+	mutant.Compile()
+	return mutant
+}
+
+func describe(t machine.Transition) string {
+	symbol := "'" + t.Symbol + "'"
+	if t.Otherwise {
+		symbol = "otherwise"
+	}
+	return fmt.Sprintf("'%v' on %v to '%v'", t.Start.Id, symbol, t.End.Id)
+}
+
+// What became of a mutant. A mutant is killed by the first case that the
+// machine passes but the mutant fails. A mutant that accepts the same tapes as
+// the machine cannot be killed, and is marked as equivalent instead. For the
+// other mutants that survive, `Tape` is the shortest tape that tells them
+// apart from the machine, so a case with that tape would kill them
+type Result struct {
+	Mutant
+	Killed     bool    `json:"Killed"`
+	KilledBy   *int    `json:"KilledBy,omitempty"`
+	Equivalent bool    `json:"Equivalent"`
+	Tape       *string `json:"Tape,omitempty"`
+}
+
+// The results of testing every mutant of a machine. `Score` is the fraction of
+// the mutants that are not equivalent that were killed, or 1 if there are none
+type Report struct {
+	Mutants    []Result `json:"Mutants"`
+	Killed     int      `json:"Killed"`
+	Survived   int      `json:"Survived"`
+	Equivalent int      `json:"Equivalent"`
+	Score      float64  `json:"Score"`
+
+	// Set if there were more mutants than `Tester.MaxMutants`
+	Truncated bool `json:"Truncated,omitempty"`
+}
+
+// Tests the mutants of machines against a test suite
+type Tester struct {
+	Cases []suite.Case
+
+	// The budget of each run over the tape of a case
+	Budget simulation.Budget
+
+	// If positive, only the first mutants (see `Generate`) are tested
+	MaxMutants int
+}
+
+// Tests the mutants of a DFA. An error is returned if a case cannot be run, or
+// if the context is cancelled
+func (t Tester) Test(ctx context.Context, d *dfa.DFA) (Report, error) {
+	original, err := suite.Run(ctx, d, t.Cases, t.Budget)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// One more mutant than tested tells whether there were too many
+	limit := t.MaxMutants
+	if limit > 0 {
+		limit++
+	}
+	mutants := Generate(d, limit)
+	report := Report{Mutants: make([]Result, 0, len(mutants))}
+	if t.MaxMutants > 0 && len(mutants) > t.MaxMutants {
+		mutants = mutants[:t.MaxMutants]
+		report.Truncated = true
+	}
+	for _, m := range mutants {
+		res := Result{Mutant: m}
+		mutant := m.Apply(d)
+		if res.KilledBy, err = t.kill(ctx, mutant, original); err != nil {
+			return Report{}, err
+		}
+		if res.KilledBy != nil {
+			res.Killed = true
+			report.Killed++
+		} else if tape, distinct := dfa.Distinguish(d, mutant); distinct {
+			res.Tape = &tape
+			report.Survived++
+		} else {
+			res.Equivalent = true
+			report.Equivalent++
+		}
+		report.Mutants = append(report.Mutants, res)
+	}
+
+	report.Score = 1
+	if tested := report.Killed + report.Survived; tested > 0 {
+		report.Score = float64(report.Killed) / float64(tested)
+	}
+	return report, nil
+}
+
+// The index of the first case that the machine passes and the mutant fails
+func (t Tester) kill(ctx context.Context, m *dfa.DFA, original suite.Report) (*int, error) {
+	for i, c := range t.Cases {
+		if !original.Cases[i].Passed {
+			continue
+		}
+		res, err := simulation.Run(ctx, m.Simulate(c.Tape), t.Budget)
+		if err != nil {
+			return nil, err
+		}
+		if res.Outcome == simulation.OutcomeCancelled {
+			return nil, ctx.Err()
+		}
+		if !c.Passes(res) {
+			return &i, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,152 @@
+package mutation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/flapflapio/simulator/core/simulation/suite"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	odda := load(t, dfa.ODDA)
+	mutants := Generate(odda, 0)
+	count := map[Operator]int{}
+	for _, m := range mutants {
+		count[m.Operator]++
+	}
+	assert.Equal(t, map[Operator]int{
+		ChangeStart:        1,
+		FlipEnding:         2,
+		RedirectTransition: 4,
+	}, count)
+
+	// Mutants are copies
+	for _, m := range mutants {
+		m.Apply(odda)
+	}
+	assert.Equal(t, "q0", odda.Start.Id)
+	assert.False(t, odda.States[0].Ending)
+	assert.Equal(t, "q1", odda.Transitions[0].End.Id)
+	assert.Equal(t, "a", odda.Transitions[0].Symbol)
+
+	for _, tc := range []struct {
+		mutant   Mutant
+		tape     string
+		accepted bool
+	}{
+		{mutants[0], "", true},
+		{mutants[1], "", true},
+		{mutants[3], "a", false},
+		{mutants[len(mutants)-1], "aa", true},
+	} {
+		res := simulation.ResultOf(tc.mutant.Apply(odda).Simulate(tc.tape))
+		assert.Equal(t, tc.accepted, res.Accepted, tc.mutant.Description)
+	}
+	assert.Equal(t, "transition 0 ('q0' on 'a' to 'q1') goes to 'q0' instead",
+		mutants[3].Description)
+
+	// Only the first mutants are listed
+	assert.Equal(t, mutants[:4], Generate(odda, 4))
+}
+
+// Symbols are only swapped for ones that their state does not read yet
+func TestSwapSymbol(t *testing.T) {
+	t.Parallel()
+	d := dfa.From(dfa.DFAParams{
+		GraphParams: machine.GraphParams{
+			Start:  "q0",
+			States: []machine.State{{Id: "q0"}, {Id: "q1", Ending: true}},
+			Transitions: []machine.TransitionParams{
+				{Start: "q0", End: "q1", Symbol: "a"},
+				{Start: "q0", End: "q0", Symbol: "b"},
+				{Start: "q1", End: "q0", Symbol: "b"},
+			},
+		},
+		Alphabet: machine.RunesAlphabet("ab"),
+	})
+	var swaps []Mutant
+	for _, m := range Generate(d, 0) {
+		if m.Operator == SwapSymbol {
+			swaps = append(swaps, m)
+		}
+	}
+	if assert.Len(t, swaps, 1) {
+		assert.Equal(t, 2, *swaps[0].Transition)
+		assert.Equal(t, "a", swaps[0].Symbol)
+		res := simulation.ResultOf(swaps[0].Apply(d).Simulate("aaa"))
+		assert.True(t, res.Accepted)
+	}
+}
+
+func TestTester(t *testing.T) {
+	t.Parallel()
+	odda := load(t, dfa.ODDA)
+	cases := []suite.Case{
+		{Tape: "a", Accept: true},
+		{Tape: "bb", Accept: false},
+	}
+	report, err := Tester{Cases: cases}.Test(context.Background(), odda)
+	assert.NoError(t, err)
+	assert.Len(t, report.Mutants, 7)
+	assert.Equal(t, 7, report.Killed+report.Survived+report.Equivalent)
+	assert.Equal(t, 0, report.Equivalent)
+	assert.Greater(t, report.Survived, 0)
+	assert.InDelta(t, float64(report.Killed)/7, report.Score, 1e-9)
+
+	// Adding a case for the tape of every survivor kills every mutant
+	for _, m := range report.Mutants {
+		if m.Killed {
+			assert.Nil(t, m.Tape)
+			assert.NotNil(t, m.KilledBy)
+			continue
+		}
+		accepted := simulation.ResultOf(odda.Simulate(*m.Tape)).Accepted
+		cases = append(cases, suite.Case{Tape: *m.Tape, Accept: accepted})
+	}
+	report, err = Tester{Cases: cases}.Test(context.Background(), odda)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, report.Killed)
+	assert.Equal(t, 1.0, report.Score)
+
+	report, err = Tester{Cases: cases, MaxMutants: 3}.Test(context.Background(), odda)
+	assert.NoError(t, err)
+	assert.Len(t, report.Mutants, 3)
+	assert.True(t, report.Truncated)
+}
+
+func TestEquivalentMutants(t *testing.T) {
+	t.Parallel()
+
+	// Both states accept, so redirecting a transition changes nothing
+	all := load(t, `{
+		"Type": "DFA",
+		"Alphabet": "a",
+		"Start": "q0",
+		"States": [{ "Id": "q0", "Ending": true }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q1", "End": "q0", "Symbol": "a" }
+		]
+	}`)
+	report, err := Tester{}.Test(context.Background(), all)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Equivalent)
+	assert.Equal(t, 2, report.Survived)
+	assert.Equal(t, 0.0, report.Score)
+	for _, m := range report.Mutants {
+		assert.Equal(t, m.Operator != FlipEnding, m.Equivalent, m.Description)
+	}
+}
+
+func load(t *testing.T, doc string) *dfa.DFA {
+	d, err := dfa.Load([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
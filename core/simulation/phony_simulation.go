@@ -38,7 +38,12 @@ func (ps *PhonySimulation) Step() {
 }
 
 func (ps *PhonySimulation) Stat() Report {
-	return Report{}
+	return Report{
+		Result: Result{
+			Path:           ps.Path[:ps.I],
+			RemainingInput: ps.Input,
+		},
+	}
 }
 
 func (ps *PhonySimulation) Result() (Result, error) {
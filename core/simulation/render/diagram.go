@@ -0,0 +1,145 @@
+// Drawings of machines, as Graphviz DOT, Mermaid, SVG and TikZ
+package render
+
+import (
+	"fmt"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+const (
+	epsilon = "ε"
+	blank   = "□"
+)
+
+// What gets drawn: the states of a machine, and its transitions merged into
+// one edge per pair of states
+type Diagram struct {
+	Start  string
+	States []Node
+	Edges  []Edge
+}
+
+type Node struct {
+	Id     string
+	Label  string
+	Ending bool
+
+	// Position hint, taken from the 'X' and 'Y' of the state's 'Meta'
+	Position *Point
+}
+
+// An edge from one state to another. Each line describes one of the
+// transitions that the edge stands for
+type Edge struct {
+	From, To string
+	Lines    []string
+}
+
+type Point struct{ X, Y float64 }
+
+// The name drawn on a node
+func (n Node) Name() string {
+	if n.Label != "" {
+		return n.Label
+	}
+	return n.Id
+}
+
+// Creates a diagram of a loaded machine
+func FromMachine(m simulation.Machine) (*Diagram, error) {
+	return FromDocument(m.JsonMap())
+}
+
+// Creates a diagram of a graph
+func FromGraph(g *machine.Graph) (*Diagram, error) {
+	return FromDocument(g.JsonMap())
+}
+
+// Creates a diagram of a machine document. Transitions are described
+// according to the type of the machine: "a" for finite automata, "a, X → Y"
+// (read, pop → push) for PDAs and "a → b, R" (read → write, move) for TMs
+func FromDocument(doc map[string]interface{}) (*Diagram, error) {
+	d := &Diagram{}
+	d.Start, _ = doc["Start"].(string)
+	machineType, _ := doc["Type"].(string)
+	machineType = machine.ParseMachineType(machineType)
+
+	known := map[string]bool{}
+	for _, s := range machine.ObjectList(doc["States"]) {
+		n := Node{}
+		n.Id, _ = s["Id"].(string)
+		n.Label, _ = s["Label"].(string)
+		n.Ending, _ = s["Ending"].(bool)
+		meta, _ := s["Meta"].(map[string]interface{})
+		x, okx := meta["X"].(float64)
+		y, oky := meta["Y"].(float64)
+		if okx && oky {
+			n.Position = &Point{x, y}
+		}
+		if n.Id == "" || known[n.Id] {
+			return nil, fmt.Errorf("state ids must be unique and non-empty, got '%v'", n.Id)
+		}
+		known[n.Id] = true
+		d.States = append(d.States, n)
+	}
+	if !known[d.Start] {
+		return nil, fmt.Errorf("start state '%v' was not found", d.Start)
+	}
+
+	type pair struct{ from, to string }
+	edges := map[pair]int{}
+	for i, t := range machine.ObjectList(doc["Transitions"]) {
+		from, _ := t["Start"].(string)
+		to, _ := t["End"].(string)
+		if !known[from] || !known[to] {
+			return nil, fmt.Errorf("transition %v uses an unknown state", i)
+		}
+		line := describe(machineType, t)
+		if j, ok := edges[pair{from, to}]; ok {
+			d.Edges[j].Lines = append(d.Edges[j].Lines, line)
+			continue
+		}
+		edges[pair{from, to}] = len(d.Edges)
+		d.Edges = append(d.Edges, Edge{From: from, To: to, Lines: []string{line}})
+	}
+	return d, nil
+}
+
+// The index of the state with the given id, or -1
+func (d *Diagram) index(id string) int {
+	for i, n := range d.States {
+		if n.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Describes a transition in the notation of the machine's type
+func describe(machineType string, t map[string]interface{}) string {
+	symbol, _ := t["Symbol"].(string)
+	if otherwise, _ := t["Otherwise"].(bool); otherwise {
+		symbol = "otherwise"
+	}
+	or := func(s, empty string) string {
+		if s == "" {
+			return empty
+		}
+		return s
+	}
+	field := func(key string) string {
+		s, _ := t[key].(string)
+		return s
+	}
+	switch machineType {
+	case machine.PDA:
+		return fmt.Sprintf("%v, %v → %v",
+			or(symbol, epsilon), or(field("Pop"), epsilon), or(field("Push"), epsilon))
+	case machine.TM:
+		return fmt.Sprintf("%v → %v, %v",
+			or(symbol, blank), or(field("Write"), blank), or(field("Move"), "S"))
+	}
+	return or(symbol, epsilon)
+}
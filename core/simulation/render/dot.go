@@ -0,0 +1,49 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Writes the diagram in the Graphviz DOT language, laid out left to right
+func (d *Diagram) Dot() string {
+	var b strings.Builder
+	b.WriteString("digraph machine {\n")
+	b.WriteString("\trankdir=LR;\n")
+
+	// An invisible node that the arrow to the start state comes from
+	start := "__start"
+	for d.index(start) >= 0 {
+		start += "_"
+	}
+	fmt.Fprintf(&b, "\t%v [shape=point, style=invis];\n", dotId(start))
+
+	for _, n := range d.States {
+		shape := "circle"
+		if n.Ending {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(&b, "\t%v [shape=%v", dotId(n.Id), shape)
+		if n.Label != "" {
+			fmt.Fprintf(&b, ", label=%v", dotId(n.Label))
+		}
+		b.WriteString("];\n")
+	}
+
+	fmt.Fprintf(&b, "\t%v -> %v;\n", dotId(start), dotId(d.Start))
+	for _, e := range d.Edges {
+		fmt.Fprintf(&b, "\t%v -> %v [label=%v];\n",
+			dotId(e.From), dotId(e.To), dotId(strings.Join(e.Lines, "\n")))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Quotes a DOT identifier or label
+func dotId(s string) string {
+	return `"` + strings.NewReplacer(
+		`\`, `\\`,
+		`"`, `\"`,
+		"\n", `\n`,
+	).Replace(s) + `"`
+}
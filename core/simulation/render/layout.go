@@ -0,0 +1,117 @@
+package render
+
+import "sort"
+
+// Spacing of the automatic layout
+const (
+	layerSpacing = 160.0
+	rowSpacing   = 110.0
+)
+
+// Positions of the states, in the order of `d.States`. If every state has a
+// position hint, the hints are used as they are. Otherwise the states are laid
+// out in layers from left to right: the start state is alone in the first
+// layer, and every other state is one layer right of the closest state with
+// an edge to it. States that cannot be reached from the start state come last.
+// Within a layer, states are ordered to limit crossing edges
+func (d *Diagram) Layout() []Point {
+	if hinted := d.hintedLayout(); hinted != nil {
+		return hinted
+	}
+
+	adjacent := make([][]int, len(d.States))
+	for _, e := range d.Edges {
+		from, to := d.index(e.From), d.index(e.To)
+		adjacent[from] = append(adjacent[from], to)
+	}
+
+	layerOf := make([]int, len(d.States))
+	for i := range layerOf {
+		layerOf[i] = -1
+	}
+	var layers [][]int
+	bfs := func(root int) {
+		first := len(layers)
+		layerOf[root] = first
+		layers = append(layers, []int{root})
+		for queue := []int{root}; len(queue) > 0; queue = queue[1:] {
+			s := queue[0]
+			for _, next := range adjacent[s] {
+				if layerOf[next] >= 0 {
+					continue
+				}
+				layerOf[next] = layerOf[s] + 1
+				if layerOf[next] == len(layers) {
+					layers = append(layers, nil)
+				}
+				layers[layerOf[next]] = append(layers[layerOf[next]], next)
+				queue = append(queue, next)
+			}
+		}
+	}
+	bfs(d.index(d.Start))
+	for i := range d.States {
+		if layerOf[i] < 0 {
+			bfs(i)
+		}
+	}
+
+	// Order each layer by the average row of the states in the previous layer
+	// that have edges to it
+	row := make([]float64, len(d.States))
+	for l, layer := range layers {
+		if l > 0 {
+			barycenter := make(map[int]float64, len(layer))
+			for _, s := range layer {
+				sum, n := 0.0, 0
+				for _, p := range layers[l-1] {
+					for _, next := range adjacent[p] {
+						if next == s {
+							sum += row[p]
+							n++
+						}
+					}
+				}
+				barycenter[s] = row[s]
+				if n > 0 {
+					barycenter[s] = sum / float64(n)
+				}
+			}
+			sort.SliceStable(layer, func(i, j int) bool {
+				return barycenter[layer[i]] < barycenter[layer[j]]
+			})
+		}
+		for r, s := range layer {
+			row[s] = float64(r)
+		}
+	}
+
+	tallest := 0
+	for _, layer := range layers {
+		if len(layer) > tallest {
+			tallest = len(layer)
+		}
+	}
+	points := make([]Point, len(d.States))
+	for l, layer := range layers {
+		offset := float64(tallest-len(layer)) / 2
+		for r, s := range layer {
+			points[s] = Point{
+				X: float64(l) * layerSpacing,
+				Y: (offset + float64(r)) * rowSpacing,
+			}
+		}
+	}
+	return points
+}
+
+func (d *Diagram) hintedLayout() []Point {
+	points := make([]Point, len(d.States))
+	for i, n := range d.States {
+		if n.Position == nil {
+			return nil
+		}
+		points[i] = *n.Position
+	}
+	return points
+}
@@ -0,0 +1,48 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Writes the diagram as a Mermaid state diagram. States are given short
+// aliases (s0, s1, ...) since Mermaid only accepts simple identifiers, and
+// accepting states get a transition to the end marker [*]
+func (d *Diagram) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	b.WriteString("    direction LR\n")
+	alias := func(id string) string { return fmt.Sprintf("s%v", d.index(id)) }
+
+	for _, n := range d.States {
+		fmt.Fprintf(&b, "    state \"%v\" as %v\n", mermaidText(n.Name()), alias(n.Id))
+	}
+	fmt.Fprintf(&b, "    [*] --> %v\n", alias(d.Start))
+	for _, e := range d.Edges {
+		lines := make([]string, len(e.Lines))
+		for i, l := range e.Lines {
+			lines[i] = mermaidText(l)
+		}
+		fmt.Fprintf(&b, "    %v --> %v: %v\n",
+			alias(e.From), alias(e.To), strings.Join(lines, "<br/>"))
+	}
+	for _, n := range d.States {
+		if n.Ending {
+			fmt.Fprintf(&b, "    %v --> [*]\n", alias(n.Id))
+		}
+	}
+	return b.String()
+}
+
+// Escapes the characters that end or break Mermaid text, using Mermaid's
+// entity codes
+func mermaidText(s string) string {
+	return strings.NewReplacer(
+		"#", "#35;",
+		`"`, "#quot;",
+		";", "#59;",
+		"<", "#lt;",
+		">", "#gt;",
+		"\n", " ",
+	).Replace(s)
+}
@@ -0,0 +1,242 @@
+package render
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation/automata"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+// Accepts an odd number of a's, the second state has a label
+const oddA = `
+{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [
+	  { "Id": "q0", "Ending": false },
+	  { "Id": "q1", "Ending": true, "Label": "odd \"a\"s" }
+	],
+	"Transitions": [
+	  { "Start": "q0", "End": "q1", "Symbol": "a" },
+	  { "Start": "q0", "End": "q0", "Symbol": "b" },
+	  { "Start": "q1", "End": "q1", "Symbol": "b" },
+	  { "Start": "q1", "End": "q0", "Symbol": "a" }
+	]
+}`
+
+const anbn = `
+{
+	"Type": "PDA",
+	"Start": "q0",
+	"States": [{ "Id": "q0" }, { "Id": "q1" }, { "Id": "q2", "Ending": true }],
+	"Transitions": [
+	  { "Start": "q0", "End": "q0", "Symbol": "a", "Pop": "", "Push": "A" },
+	  { "Start": "q0", "End": "q1", "Symbol": "b", "Pop": "A", "Push": "" },
+	  { "Start": "q1", "End": "q1", "Symbol": "b", "Pop": "A", "Push": "" },
+	  { "Start": "q1", "End": "q2", "Symbol": "", "Pop": "Z", "Push": "Z" }
+	]
+}`
+
+func TestDot(t *testing.T) {
+	d := mustDiagram(t, oddA)
+	assert.Equal(t, `digraph machine {
+	rankdir=LR;
+	"__start" [shape=point, style=invis];
+	"q0" [shape=circle];
+	"q1" [shape=doublecircle, label="odd \"a\"s"];
+	"__start" -> "q0";
+	"q0" -> "q1" [label="a"];
+	"q0" -> "q0" [label="b"];
+	"q1" -> "q1" [label="b"];
+	"q1" -> "q0" [label="a"];
+}
+`, d.Dot())
+}
+
+func TestMermaid(t *testing.T) {
+	d := mustDiagram(t, anbn)
+	assert.Equal(t, `stateDiagram-v2
+    direction LR
+    state "q0" as s0
+    state "q1" as s1
+    state "q2" as s2
+    [*] --> s0
+    s0 --> s0: a, ε → A
+    s0 --> s1: b, A → ε
+    s1 --> s1: b, A → ε
+    s1 --> s2: ε, Z → Z
+    s2 --> [*]
+`, d.Mermaid())
+}
+
+func TestEdgesAreMerged(t *testing.T) {
+	d := mustDiagram(t, `
+	{
+		"Type": "TM",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+		  { "Start": "q0", "End": "q0", "Symbol": "0", "Write": "1", "Move": "R" },
+		  { "Start": "q0", "End": "q0", "Symbol": "1", "Write": "0", "Move": "R" },
+		  { "Start": "q0", "End": "q1", "Symbol": "" }
+		]
+	}`)
+	assert.Equal(t, []Edge{
+		{From: "q0", To: "q0", Lines: []string{"0 → 1, R", "1 → 0, R"}},
+		{From: "q0", To: "q1", Lines: []string{"□ → □, S"}},
+	}, d.Edges)
+}
+
+func TestLayout(t *testing.T) {
+	// q0 -> q1 -> q3, q0 -> q2, and q4 is unreachable
+	d := mustDiagram(t, `
+	{
+		"Type": "NFA",
+		"Start": "q0",
+		"States": [
+		  { "Id": "q0" }, { "Id": "q1" }, { "Id": "q2" }, { "Id": "q3" }, { "Id": "q4" }
+		],
+		"Transitions": [
+		  { "Start": "q0", "End": "q1", "Symbol": "a" },
+		  { "Start": "q0", "End": "q2", "Symbol": "b" },
+		  { "Start": "q1", "End": "q3", "Symbol": "a" },
+		  { "Start": "q4", "End": "q0", "Symbol": "a" }
+		]
+	}`)
+	assert.Equal(t, []Point{
+		{0, 0.5 * rowSpacing},
+		{layerSpacing, 0},
+		{layerSpacing, rowSpacing},
+		{2 * layerSpacing, 0.5 * rowSpacing},
+		{3 * layerSpacing, 0.5 * rowSpacing},
+	}, d.Layout())
+
+	// Position hints are used if every state has one
+	d.States[0].Position = &Point{10, 10}
+	assert.Equal(t, layerSpacing, d.Layout()[1].X)
+	for i := range d.States {
+		d.States[i].Position = &Point{float64(i), 0}
+	}
+	assert.Equal(t, Point{4, 0}, d.Layout()[4])
+}
+
+func TestSvg(t *testing.T) {
+	for _, doc := range []string{oddA, anbn, dfa.ODDA} {
+		svg := mustDiagram(t, doc).Svg()
+
+		// Well formed XML
+		decoder := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			_, err := decoder.Token()
+			if err != nil {
+				assert.Equal(t, "EOF", err.Error())
+				break
+			}
+		}
+		assert.True(t, strings.HasPrefix(svg, "<svg "))
+		assert.NotContains(t, svg, "NaN")
+	}
+
+	// States hinted to the same position
+	d := mustDiagram(t, anbn)
+	for i := range d.States {
+		d.States[i].Position = &Point{5, 5}
+	}
+	assert.NotContains(t, d.Svg(), "NaN")
+
+	svg := mustDiagram(t, oddA).Svg()
+	assert.Equal(t, 3, strings.Count(svg, "<circle"))
+	assert.Contains(t, svg, "odd &#34;a&#34;s")
+}
+
+func TestFromMachine(t *testing.T) {
+	m, err := automata.Load([]byte(oddA))
+	assert.NoError(t, err)
+	d, err := FromMachine(m)
+	assert.NoError(t, err)
+	assert.Equal(t, mustDiagram(t, oddA), d)
+
+	// Position hints are kept by loaded machines
+	m, err = automata.Load([]byte(strings.Replace(oddA,
+		`{ "Id": "q0", "Ending": false }`,
+		`{ "Id": "q0", "Ending": false, "Meta": { "X": 30, "Y": 40 } }`, 1)))
+	assert.NoError(t, err)
+	d, err = FromMachine(m)
+	assert.NoError(t, err)
+	assert.Equal(t, &Point{30, 40}, d.States[0].Position)
+	assert.Nil(t, d.States[1].Position)
+}
+
+func TestInvalidDiagrams(t *testing.T) {
+	for name, doc := range map[string]string{
+		"unknown-start":      `{"Start": "q9", "States": [{"Id": "q0"}], "Transitions": []}`,
+		"duplicate-state":    `{"Start": "q0", "States": [{"Id": "q0"}, {"Id": "q0"}]}`,
+		"unknown-transition": `{"Start": "q0", "States": [{"Id": "q0"}], "Transitions": [{"Start": "q0", "End": "q1"}]}`,
+	} {
+		m, err := machine.LoadMap([]byte(doc))
+		assert.NoError(t, err)
+		_, err = FromDocument(m)
+		assert.Error(t, err, name)
+	}
+}
+
+func mustDiagram(t *testing.T, doc string) *Diagram {
+	m, err := machine.LoadMap([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromDocument(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestTikz(t *testing.T) {
+	d := mustDiagram(t, oddA)
+	tikz, err := d.Tikz(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `% \usetikzlibrary{automata}
+\begin{tikzpicture}[>=stealth, shorten >=1pt, auto, highlight/.style={draw=black, text=black, very thick}]
+  \node[state, initial] (s0) at (0, 0) {q0};
+  \node[state, accepting] (s1) at (2.67, 0) {odd "a"s};
+  \path[->, bend left] (s0) edge node[align=center] {a} (s1);
+  \path[->, loop above] (s0) edge node[align=center] {b} (s0);
+  \path[->, loop above] (s1) edge node[align=center] {b} (s1);
+  \path[->, bend left] (s1) edge node[align=center] {a} (s0);
+\end{tikzpicture}
+`, tikz)
+
+	// A path through every state: q0 -a-> q1 -b-> q1 -a-> q0
+	tikz, err = d.Tikz(&Highlight{Path: []string{"q0", "q1", "q1", "q0"}, Color: "red!70!black"})
+	assert.NoError(t, err)
+	assert.Contains(t, tikz, "highlight/.style={draw=red!70!black, text=red!70!black, very thick}")
+	assert.Contains(t, tikz, `\node[state, initial, highlight] (s0)`)
+	assert.Contains(t, tikz, `\path[->, bend left, highlight] (s0) edge`)
+	assert.Contains(t, tikz, `\path[->, loop above] (s0) edge`)
+	assert.Contains(t, tikz, `\path[->, loop above, highlight] (s1) edge`)
+
+	// Position hints are flipped, and PDA notation is written in math mode
+	d = mustDiagram(t, anbn)
+	for i := range d.States {
+		d.States[i].Position = &Point{float64(i) * 120, 60}
+	}
+	tikz, err = d.Tikz(nil)
+	assert.NoError(t, err)
+	assert.Contains(t, tikz, `\node[state] (s1) at (2, -1) {q1};`)
+	assert.Contains(t, tikz, `{$\varepsilon$, Z $\rightarrow$ Z}`)
+
+	for _, h := range []*Highlight{
+		{Path: []string{"q0"}, Color: "red}, fill=blue"},
+		{Path: []string{"q0"}, Color: ""},
+		{Path: []string{"q9"}, Color: "red"},
+	} {
+		_, err = d.Tikz(h)
+		assert.Error(t, err, "%+v", h)
+	}
+}
@@ -0,0 +1,193 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+// Dimensions of SVG drawings
+const (
+	nodeRadius  = 24.0
+	svgMargin   = 40.0
+	startLength = 40.0
+	loopHeight  = 70.0
+	bendOffset  = 30.0
+	fontSize    = 14.0
+	lineHeight  = 1.2 * fontSize
+)
+
+// Draws the diagram as a standalone SVG image, using `Layout` to place the
+// states
+func (d *Diagram) Svg() string {
+	points := d.Layout()
+
+	// Move the drawing so that everything fits in the image, leaving room for
+	// the start arrow on the left and self loops on the top
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range points {
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+	dx := svgMargin + startLength + nodeRadius - minX
+	dy := svgMargin + loopHeight + nodeRadius - minY
+	for i := range points {
+		points[i].X += dx
+		points[i].Y += dy
+	}
+	width := maxX + dx + nodeRadius + svgMargin
+	height := maxY + dy + nodeRadius + svgMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" `+
+		`width="%v" height="%v" viewBox="0 0 %v %v" `+
+		`font-family="sans-serif" font-size="%v">`+"\n",
+		num(width), num(height), num(width), num(height), num(fontSize))
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" ` +
+		`markerWidth="8" markerHeight="8" orient="auto-start-reverse">` +
+		`<path d="M 0 0 L 10 5 L 0 10 z"/></marker></defs>` + "\n")
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>` + "\n")
+
+	start := points[d.index(d.Start)]
+	fmt.Fprintf(&b, `<line x1="%v" y1="%v" x2="%v" y2="%v" `+
+		`stroke="black" marker-end="url(#arrow)"/>`+"\n",
+		num(start.X-nodeRadius-startLength), num(start.Y),
+		num(start.X-nodeRadius), num(start.Y))
+
+	for _, e := range d.Edges {
+		d.svgEdge(&b, e, points)
+	}
+
+	for i, n := range d.States {
+		p := points[i]
+		fmt.Fprintf(&b, `<g class="state" id="%v">`, html.EscapeString(n.Id))
+		fmt.Fprintf(&b, `<circle cx="%v" cy="%v" r="%v" fill="white" stroke="black"/>`,
+			num(p.X), num(p.Y), num(nodeRadius))
+		if n.Ending {
+			fmt.Fprintf(&b, `<circle cx="%v" cy="%v" r="%v" fill="none" stroke="black"/>`,
+				num(p.X), num(p.Y), num(nodeRadius-4))
+		}
+		svgText(&b, p, []string{n.Name()})
+		b.WriteString("</g>\n")
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func (d *Diagram) svgEdge(b *strings.Builder, e Edge, points []Point) {
+	from, to := points[d.index(e.From)], points[d.index(e.To)]
+	b.WriteString(`<g class="transition">`)
+	defer b.WriteString("</g>\n")
+
+	// States whose positions coincide (e.g. hints that put two states at the
+	// same place) have no direction between them, so their edges are drawn
+	// like loops
+	if e.From == e.To || from == to {
+		// A loop above the state
+		a1, a2 := -2*math.Pi/3, -math.Pi/3
+		p1 := Point{from.X + nodeRadius*math.Cos(a1), from.Y + nodeRadius*math.Sin(a1)}
+		p2 := Point{from.X + nodeRadius*math.Cos(a2), from.Y + nodeRadius*math.Sin(a2)}
+		fmt.Fprintf(b, `<path d="M %v %v C %v %v %v %v %v %v" `+
+			`fill="none" stroke="black" marker-end="url(#arrow)"/>`,
+			num(p1.X), num(p1.Y),
+			num(from.X-nodeRadius), num(from.Y-loopHeight),
+			num(from.X+nodeRadius), num(from.Y-loopHeight),
+			num(p2.X), num(p2.Y))
+		svgLabel(b, Point{from.X, from.Y - loopHeight + 4}, e.Lines)
+		return
+	}
+
+	// Edges bend if there is an edge going the other way, or if a straight
+	// line would cross another state
+	bend := 0.0
+	if d.hasEdge(e.To, e.From) {
+		bend = bendOffset
+	}
+	for i, p := range points {
+		if id := d.States[i].Id; id != e.From && id != e.To &&
+			distanceToSegment(p, from, to) < nodeRadius+8 {
+			bend = 2 * bendOffset
+		}
+	}
+
+	length := math.Hypot(to.X-from.X, to.Y-from.Y)
+	nx, ny := (from.Y-to.Y)/length, (to.X-from.X)/length
+	control := Point{(from.X+to.X)/2 + 2*bend*nx, (from.Y+to.Y)/2 + 2*bend*ny}
+	p1 := towards(from, control, nodeRadius)
+	p2 := towards(to, control, nodeRadius)
+	fmt.Fprintf(b, `<path d="M %v %v Q %v %v %v %v" `+
+		`fill="none" stroke="black" marker-end="url(#arrow)"/>`,
+		num(p1.X), num(p1.Y), num(control.X), num(control.Y), num(p2.X), num(p2.Y))
+
+	// The label sits at the middle of the edge, on the outside of the curve
+	// (or above the edge if it is straight)
+	side := 1.0
+	if bend == 0 && ny > 0 {
+		side = -1
+	}
+	mid := Point{
+		(p1.X+2*control.X+p2.X)/4 + 12*side*nx,
+		(p1.Y+2*control.Y+p2.Y)/4 + 12*side*ny,
+	}
+	svgLabel(b, mid, e.Lines)
+}
+
+func (d *Diagram) hasEdge(from, to string) bool {
+	for _, e := range d.Edges {
+		if e.From == from && e.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes lines of text centered on `p`
+func svgText(b *strings.Builder, p Point, lines []string) {
+	fmt.Fprintf(b, `<text x="%v" y="%v" text-anchor="middle" dominant-baseline="central">`,
+		num(p.X), num(p.Y-lineHeight*float64(len(lines)-1)/2))
+	for i, l := range lines {
+		dy := 0.0
+		if i > 0 {
+			dy = lineHeight
+		}
+		fmt.Fprintf(b, `<tspan x="%v" dy="%v">%v</tspan>`,
+			num(p.X), num(dy), html.EscapeString(l))
+	}
+	b.WriteString("</text>")
+}
+
+// Writes the label of an edge, with its last line just above `p`
+func svgLabel(b *strings.Builder, p Point, lines []string) {
+	p.Y -= lineHeight * float64(len(lines)-1) / 2
+	svgText(b, p, lines)
+}
+
+// The point at distance `r` from `center`, in the direction of `target`, or
+// to the right of `center` if the points coincide
+func towards(center, target Point, r float64) Point {
+	l := math.Hypot(target.X-center.X, target.Y-center.Y)
+	if l == 0 {
+		return Point{center.X + r, center.Y}
+	}
+	return Point{
+		center.X + r*(target.X-center.X)/l,
+		center.Y + r*(target.Y-center.Y)/l,
+	}
+}
+
+func distanceToSegment(p, a, b Point) float64 {
+	l2 := (b.X-a.X)*(b.X-a.X) + (b.Y-a.Y)*(b.Y-a.Y)
+	if l2 == 0 {
+		return math.Hypot(p.X-a.X, p.Y-a.Y)
+	}
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*(b.X-a.X)+(p.Y-a.Y)*(b.Y-a.Y))/l2))
+	return math.Hypot(p.X-(a.X+t*(b.X-a.X)), p.Y-(a.Y+t*(b.Y-a.Y)))
+}
+
+// Formats a coordinate with at most one decimal
+func num(f float64) string {
+	return fmt.Sprintf("%g", math.Round(f*10)/10)
+}
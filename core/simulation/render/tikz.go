@@ -0,0 +1,124 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Size of one unit of `Layout` in TikZ drawings, in centimeters
+const tikzScale = 1 / 60.0
+
+// Color names and xcolor expressions such as "red", "blue!60!black" or
+// "MyColor"
+var tikzColor = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(![0-9]{1,3}(![A-Za-z][A-Za-z0-9]*)?)*$`)
+
+// A run of a machine to draw in color: the states of the path and the edges
+// taken between them
+type Highlight struct {
+	Path  []string
+	Color string
+}
+
+// Writes the diagram as a `tikzpicture` using the `automata` TikZ library
+// (`\usetikzlibrary{automata}` in the preamble). States are placed using
+// `Layout`, with the y axis flipped since TikZ draws upwards. If `highlight`
+// is not nil, the states and edges of its path are drawn thick and in its
+// color
+func (d *Diagram) Tikz(highlight *Highlight) (string, error) {
+	color := "black"
+	onPath := map[int]bool{}
+	taken := map[[2]string]bool{}
+	if highlight != nil {
+		if !tikzColor.MatchString(highlight.Color) {
+			return "", fmt.Errorf("'%v' is not a color", highlight.Color)
+		}
+		color = highlight.Color
+		for i, id := range highlight.Path {
+			s := d.index(id)
+			if s < 0 {
+				return "", fmt.Errorf("state '%v' of the path was not found", id)
+			}
+			onPath[s] = true
+			if i > 0 {
+				taken[[2]string{highlight.Path[i-1], id}] = true
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("% \\usetikzlibrary{automata}\n")
+	fmt.Fprintf(&b, "\\begin{tikzpicture}[>=stealth, shorten >=1pt, auto, "+
+		"highlight/.style={draw=%v, text=%v, very thick}]\n", color, color)
+
+	for i, p := range d.Layout() {
+		n := d.States[i]
+		options := []string{"state"}
+		if n.Id == d.Start {
+			options = append(options, "initial")
+		}
+		if n.Ending {
+			options = append(options, "accepting")
+		}
+		if onPath[i] {
+			options = append(options, "highlight")
+		}
+		fmt.Fprintf(&b, "  \\node[%v] (s%v) at (%v, %v) {%v};\n",
+			strings.Join(options, ", "), i,
+			tikzNum(p.X*tikzScale), tikzNum(-p.Y*tikzScale), tikzText(n.Name()))
+	}
+
+	for _, e := range d.Edges {
+		options := []string{"->"}
+		switch {
+		case e.From == e.To:
+			options = append(options, "loop above")
+		case d.hasEdge(e.To, e.From):
+			options = append(options, "bend left")
+		}
+		if taken[[2]string{e.From, e.To}] {
+			options = append(options, "highlight")
+		}
+		lines := make([]string, len(e.Lines))
+		for i, l := range e.Lines {
+			lines[i] = tikzText(l)
+		}
+		fmt.Fprintf(&b, "  \\path[%v] (s%v) edge node[align=center] {%v} (s%v);\n",
+			strings.Join(options, ", "), d.index(e.From),
+			strings.Join(lines, `\\`), d.index(e.To))
+	}
+
+	b.WriteString("\\end{tikzpicture}\n")
+	return b.String(), nil
+}
+
+// Escapes the characters that LaTeX treats specially, and writes the symbols
+// used in transition descriptions in math mode
+func tikzText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`{`, `\{`,
+		`}`, `\}`,
+		`$`, `\$`,
+		`&`, `\&`,
+		`#`, `\#`,
+		`%`, `\%`,
+		`_`, `\_`,
+		`^`, `\textasciicircum{}`,
+		`~`, `\textasciitilde{}`,
+		"\n", " ",
+		epsilon, `$\varepsilon$`,
+		blank, `$\sqcup$`,
+		"→", `$\rightarrow$`,
+	).Replace(s)
+}
+
+// Formats a coordinate with at most two decimals
+func tikzNum(f float64) string {
+	f = math.Round(f*100) / 100
+	if f == 0 {
+		f = 0 // No negative zero
+	}
+	return fmt.Sprintf("%g", f)
+}
@@ -2,13 +2,52 @@ package simulation
 
 import "fmt"
 
+// How a simulation run came to an end
+type Outcome string
+
+const (
+	// The machine consumed its input and ended in an accepting state
+	OutcomeAccepted Outcome = "Accepted"
+
+	// The machine consumed its input and ended in a non-accepting state
+	OutcomeRejected Outcome = "Rejected"
+
+	// The machine stopped before consuming all of its input (e.g. it had no
+	// transition to take)
+	OutcomeHalted Outcome = "Halted"
+
+	// The run was abandoned because it used up its step or time budget
+	OutcomeBudgetExhausted Outcome = "BudgetExhausted"
+
+	// The run was abandoned because its context was cancelled (e.g. the client
+	// disconnected)
+	OutcomeCancelled Outcome = "Cancelled"
+)
+
 // A report of the end result of a simulation
 type Result struct {
 	Accepted       bool     `json:"Accepted"`
 	Path           []string `json:"Path"`
 	RemainingInput string   `json:"RemainingInput"`
+	Outcome        Outcome  `json:"Outcome,omitempty"`
+
+	// Number of symbols read. Only reported by simulations of a stream,
+	// which do not keep the whole path
+	Steps int `json:"Steps,omitempty"`
 }
 
 func (r Result) String() string {
 	return fmt.Sprintf("Result[Accepted:%v Path:%v]", r.Accepted, r.Path)
 }
+
+// Derives the outcome of a finished simulation from its result
+func OutcomeOf(r Result) Outcome {
+	switch {
+	case r.Accepted:
+		return OutcomeAccepted
+	case r.RemainingInput != "":
+		return OutcomeHalted
+	default:
+		return OutcomeRejected
+	}
+}
@@ -0,0 +1,75 @@
+package simulation
+
+import (
+	"context"
+	"time"
+)
+
+// Limits on how much work a single simulation run may do. A zero value for a
+// field means that there is no limit
+type Budget struct {
+	MaxSteps int
+	Timeout  time.Duration
+}
+
+// Returns the tighter of the two budgets, field by field
+func (b Budget) Min(other Budget) Budget {
+	return Budget{
+		MaxSteps: minPositive(b.MaxSteps, other.MaxSteps),
+		Timeout:  time.Duration(minPositive(int(b.Timeout), int(other.Timeout))),
+	}
+}
+
+// Runs a simulation until it is done, the context is cancelled, or the budget
+// is exhausted. The returned Result always has its Outcome set. If the run is
+// abandoned, the Result is the last report of the simulation.
+//
+// An error is returned only if the simulation finished but its result could not
+// be obtained
+func Run(ctx context.Context, sim Simulation, budget Budget) (Result, error) {
+	runCtx := ctx
+	if budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, budget.Timeout)
+		defer cancel()
+	}
+
+	for steps := 0; !sim.Done(); steps++ {
+		if budget.MaxSteps > 0 && steps >= budget.MaxSteps {
+			return abandoned(sim, OutcomeBudgetExhausted), nil
+		}
+		select {
+		case <-runCtx.Done():
+			if ctx.Err() != nil {
+				return abandoned(sim, OutcomeCancelled), nil
+			}
+			return abandoned(sim, OutcomeBudgetExhausted), nil
+		default:
+		}
+		sim.Step()
+	}
+
+	res, err := sim.Result()
+	if err != nil {
+		return Result{}, err
+	}
+	res.Outcome = OutcomeOf(res)
+	return res, nil
+}
+
+func abandoned(sim Simulation, outcome Outcome) Result {
+	res := sim.Stat().Result
+	res.Accepted = false
+	res.Outcome = outcome
+	return res
+}
+
+func minPositive(a, b int) int {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A simulation that never finishes
+type endlessSimulation struct{ steps int }
+
+func (s *endlessSimulation) Step()                   { s.steps++ }
+func (s *endlessSimulation) Stat() Report            { return Report{} }
+func (s *endlessSimulation) Result() (Result, error) { return Result{}, errors.New("not done") }
+func (s *endlessSimulation) Done() bool              { return false }
+
+func TestRunOutcomes(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sim     func() Simulation
+		ctx     func() context.Context
+		budget  Budget
+		outcome Outcome
+	}{
+		{
+			name:    "accepted",
+			sim:     func() Simulation { return NewPhonyMachine().Simulate("aaa") },
+			ctx:     context.Background,
+			outcome: OutcomeAccepted,
+		},
+		{
+			name:    "max-steps",
+			sim:     func() Simulation { return &endlessSimulation{} },
+			ctx:     context.Background,
+			budget:  Budget{MaxSteps: 1000},
+			outcome: OutcomeBudgetExhausted,
+		},
+		{
+			name:    "timeout",
+			sim:     func() Simulation { return &endlessSimulation{} },
+			ctx:     context.Background,
+			budget:  Budget{Timeout: 10 * time.Millisecond},
+			outcome: OutcomeBudgetExhausted,
+		},
+		{
+			name: "cancelled",
+			sim:  func() Simulation { return &endlessSimulation{} },
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			budget:  Budget{Timeout: time.Minute},
+			outcome: OutcomeCancelled,
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := Run(tc.ctx(), tc.sim(), tc.budget)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.outcome, res.Outcome)
+		})
+	}
+}
+
+func TestRunStopsAtMaxSteps(t *testing.T) {
+	sim := NewPhonyMachine().Simulate("aaaaaaaaaa").(*PhonySimulation)
+	res, err := Run(context.Background(), sim, Budget{MaxSteps: 4})
+	assert.NoError(t, err)
+	assert.Equal(t, OutcomeBudgetExhausted, res.Outcome)
+	assert.False(t, res.Accepted)
+	assert.Equal(t, []string{"q0", "q1", "q2", "q3"}, res.Path)
+	assert.Equal(t, "aaaaaa", res.RemainingInput)
+}
+
+func TestRunResultError(t *testing.T) {
+	sim := (&PhonyMachine{FailOnResult: true}).Simulate("a")
+	_, err := Run(context.Background(), sim, Budget{})
+	assert.Error(t, err)
+}
+
+func TestOutcomeOf(t *testing.T) {
+	assert.Equal(t, OutcomeAccepted, OutcomeOf(Result{Accepted: true}))
+	assert.Equal(t, OutcomeRejected, OutcomeOf(Result{}))
+	assert.Equal(t, OutcomeHalted, OutcomeOf(Result{RemainingInput: "ab"}))
+}
+
+func TestBudgetMin(t *testing.T) {
+	b := Budget{MaxSteps: 10}.Min(Budget{MaxSteps: 5, Timeout: time.Second})
+	assert.Equal(t, Budget{MaxSteps: 5, Timeout: time.Second}, b)
+	b = Budget{MaxSteps: 10, Timeout: time.Second}.Min(Budget{})
+	assert.Equal(t, Budget{MaxSteps: 10, Timeout: time.Second}, b)
+}
@@ -0,0 +1,144 @@
+package suite
+
+import (
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+)
+
+// Which parts of a machine the cases of a suite exercise. A state is covered
+// if a run visits it, and a transition if a run takes it, whether or not the
+// case passes. Runs that are abandoned count for what they did so far. The
+// trap state that `Complete` adds to a DFA, and the transitions to and from it,
+// are not part of the machine as written and are left out
+type Coverage struct {
+	States      StateCoverage      `json:"States"`
+	Transitions TransitionCoverage `json:"Transitions"`
+}
+
+type StateCoverage struct {
+	Covered int     `json:"Covered"`
+	Total   int     `json:"Total"`
+	Percent float64 `json:"Percent"`
+
+	// Ids of the states that no run visited
+	Missed []string `json:"Missed"`
+}
+
+type TransitionCoverage struct {
+	Covered int     `json:"Covered"`
+	Total   int     `json:"Total"`
+	Percent float64 `json:"Percent"`
+
+	// The transitions that no run took
+	Missed []MissedTransition `json:"Missed"`
+}
+
+// A transition, and its index in the machine
+type MissedTransition struct {
+	Index     int    `json:"Index"`
+	Start     string `json:"Start"`
+	End       string `json:"End"`
+	Symbol    string `json:"Symbol,omitempty"`
+	Otherwise bool   `json:"Otherwise,omitempty"`
+}
+
+// Records the states and transitions of a machine that runs exercise.
+// Coverage can only be recorded for DFAs, other machines have a nil tracker
+type coverageTracker struct {
+	graph       *machine.Graph
+	trap        string
+	states      map[string]bool
+	transitions map[int]bool
+}
+
+func newCoverageTracker(m simulation.Machine) *coverageTracker {
+	d, ok := m.(*dfa.DFA)
+	if !ok {
+		return nil
+	}
+	return &coverageTracker{
+		graph:       d.Graph,
+		trap:        d.Trap,
+		states:      map[string]bool{},
+		transitions: map[int]bool{},
+	}
+}
+
+// Simulates the machine over a tape, tracing the transitions that it takes if
+// coverage is recorded
+func (c *coverageTracker) simulate(m simulation.Machine, tape string) simulation.Simulation {
+	if tm, ok := m.(simulation.TracingMachine); ok && c != nil {
+		return tm.SimulateTraced(tape)
+	}
+	return m.Simulate(tape)
+}
+
+// Records what a run did, given its simulation and result
+func (c *coverageTracker) record(sim simulation.Simulation, res simulation.Result) {
+	if c == nil {
+		return
+	}
+
+	// Every run starts in the start state, even if it does not take a step
+	if c.graph.Start != nil {
+		c.states[c.graph.Start.Id] = true
+	}
+	for _, id := range res.Path {
+		c.states[id] = true
+	}
+	if tracer, ok := sim.(simulation.TransitionTracer); ok {
+		for _, i := range tracer.Transitions() {
+			c.transitions[i] = true
+		}
+	}
+}
+
+func (c *coverageTracker) coverage() *Coverage {
+	if c == nil {
+		return nil
+	}
+	cov := Coverage{
+		States:      StateCoverage{Missed: []string{}},
+		Transitions: TransitionCoverage{Missed: []MissedTransition{}},
+	}
+	for _, s := range c.graph.States {
+		if c.trap != "" && s.Id == c.trap {
+			continue
+		}
+		cov.States.Total++
+		if c.states[s.Id] {
+			cov.States.Covered++
+		} else {
+			cov.States.Missed = append(cov.States.Missed, s.Id)
+		}
+	}
+	for i, t := range c.graph.Transitions {
+		if c.trap != "" && (t.Start.Id == c.trap || t.End.Id == c.trap) {
+			continue
+		}
+		cov.Transitions.Total++
+		if c.transitions[i] {
+			cov.Transitions.Covered++
+		} else {
+			cov.Transitions.Missed = append(cov.Transitions.Missed, MissedTransition{
+				Index:     i,
+				Start:     t.Start.Id,
+				End:       t.End.Id,
+				Symbol:    t.Symbol,
+				Otherwise: t.Otherwise,
+			})
+		}
+	}
+	cov.States.Percent = percent(cov.States.Covered, cov.States.Total)
+	cov.Transitions.Percent = percent(cov.Transitions.Covered, cov.Transitions.Total)
+	return &cov
+}
+
+// A percentage, or 100 if there is nothing to cover
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
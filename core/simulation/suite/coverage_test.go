@@ -0,0 +1,100 @@
+package suite
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/stretchr/testify/assert"
+)
+
+// ODDA with a state that cannot be reached
+const oddAWithUnreachable = `{
+	"Type": "DFA",
+	"Alphabet": "ab",
+	"Start": "q0",
+	"States": [
+		{ "Id": "q0" },
+		{ "Id": "q1", "Ending": true },
+		{ "Id": "q2" }
+	],
+	"Transitions": [
+		{ "Start": "q0", "End": "q1", "Symbol": "a" },
+		{ "Start": "q0", "End": "q0", "Symbol": "b" },
+		{ "Start": "q1", "End": "q1", "Symbol": "b" },
+		{ "Start": "q1", "End": "q0", "Symbol": "a" },
+		{ "Start": "q2", "End": "q0", "Otherwise": true }
+	]
+}`
+
+func TestCoverage(t *testing.T) {
+	t.Parallel()
+	m := load(t, oddAWithUnreachable)
+	run := func(budget simulation.Budget, tapes ...string) *Coverage {
+		cases := make([]Case, len(tapes))
+		for i, tape := range tapes {
+			cases[i] = Case{Tape: tape}
+		}
+		report, err := Run(context.Background(), m, cases, budget)
+		assert.NoError(t, err)
+		return report.Coverage
+	}
+
+	// The start state is visited even by empty tapes
+	cov := run(simulation.Budget{}, "")
+	assert.Equal(t, StateCoverage{
+		Covered: 1,
+		Total:   3,
+		Percent: 100.0 / 3,
+		Missed:  []string{"q1", "q2"},
+	}, cov.States)
+	assert.Equal(t, 0, cov.Transitions.Covered)
+	assert.Len(t, cov.Transitions.Missed, 5)
+
+	cov = run(simulation.Budget{}, "ab", "b")
+	assert.Equal(t, 2, cov.States.Covered)
+	assert.Equal(t, []string{"q2"}, cov.States.Missed)
+	assert.Equal(t, TransitionCoverage{
+		Covered: 3,
+		Total:   5,
+		Percent: 60,
+		Missed: []MissedTransition{
+			{Index: 3, Start: "q1", End: "q0", Symbol: "a"},
+			{Index: 4, Start: "q2", End: "q0", Otherwise: true},
+		},
+	}, cov.Transitions)
+
+	// Abandoned runs count for the steps they took
+	cov = run(simulation.Budget{MaxSteps: 2}, "aaaa")
+	assert.Equal(t, 2, cov.Transitions.Covered)
+
+	report := Report{Cases: []CaseResult{}, Score: 1, Coverage: cov}
+	assert.True(t, strings.HasSuffix(report.TAP(),
+		"# states covered 2/3 (66.67%)\n# transitions covered 2/5 (40%)\n"))
+	data, err := report.JUnit("odd-a")
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `<property name="state-coverage" value="66.67"></property>`)
+	assert.Contains(t, string(data), `<property name="transition-coverage" value="40"></property>`)
+}
+
+// The trap state added by completing a DFA is not part of its coverage
+func TestCoverageLeavesOutTrap(t *testing.T) {
+	t.Parallel()
+	m := load(t, `{
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Complete": true,
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [{ "Start": "q0", "End": "q1", "Symbol": "a" }]
+	}`)
+	assert.Equal(t, "q2", m.Trap)
+
+	report, err := Run(context.Background(), m, []Case{{Tape: "a"}}, simulation.Budget{})
+	assert.NoError(t, err)
+	assert.Equal(t, StateCoverage{Covered: 2, Total: 2, Percent: 100, Missed: []string{}},
+		report.Coverage.States)
+	assert.Equal(t, TransitionCoverage{Covered: 1, Total: 1, Percent: 100, Missed: []MissedTransition{}},
+		report.Coverage.Transitions)
+}
@@ -0,0 +1,96 @@
+package suite
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+)
+
+var ErrNotADFA = errors.New(
+	"only DFAs can be checked for equivalence with the reference")
+
+// A tape that a submitted machine gets wrong: `Expected` is whether the
+// reference accepts it, and `Accepted` whether the submission does
+type Counterexample struct {
+	Tape     string `json:"Tape"`
+	Expected bool   `json:"Expected"`
+	Accepted bool   `json:"Accepted"`
+}
+
+// The results of grading a submission. The equivalence check counts towards
+// `Score` like one more case, with a weight of `EquivalenceWeight`
+type Grade struct {
+	Report
+	Equivalent     bool            `json:"Equivalent"`
+	Counterexample *Counterexample `json:"Counterexample,omitempty"`
+}
+
+// Grades submitted machines against a reference: a submission is run over
+// the cases of the suite, and then compared with the reference over every tape
+type Grader struct {
+	Reference *dfa.DFA
+	Cases     []Case
+
+	// The weight of the equivalence check, where 0 counts as 1 like the
+	// weights of cases
+	EquivalenceWeight float64
+
+	// The budget of each run over the tape of a case
+	Budget simulation.Budget
+}
+
+// Grades a submission. The counterexample is the shortest tape on which the
+// submission and the reference disagree, or if they agree on every tape (but
+// the cases do not), the tape of the first case that failed
+func (g Grader) Grade(ctx context.Context, submission simulation.Machine) (Grade, error) {
+	d, ok := submission.(*dfa.DFA)
+	if !ok {
+		return Grade{}, ErrNotADFA
+	}
+	report, err := Run(ctx, submission, g.Cases, g.Budget)
+	if err != nil {
+		return Grade{}, err
+	}
+
+	grade := Grade{Report: report}
+	if symbols, distinct := dfa.DistinguishSymbols(d, g.Reference); distinct {
+		// Each DFA reads the tape written with its own separator
+		tape := strings.Join(symbols, d.Separator)
+		expected := strings.Join(symbols, g.Reference.Separator)
+		grade.Counterexample = &Counterexample{
+			Tape:     tape,
+			Expected: simulation.ResultOf(g.Reference.Simulate(expected)).Accepted,
+			Accepted: simulation.ResultOf(d.Simulate(tape)).Accepted,
+		}
+	} else {
+		grade.Equivalent = true
+		for _, c := range report.Cases {
+			if !c.Passed {
+				grade.Counterexample = &Counterexample{
+					Tape:     c.Tape,
+					Expected: c.Expected,
+					Accepted: c.Accepted,
+				}
+				break
+			}
+		}
+	}
+
+	var passed, total float64
+	for _, c := range report.Cases {
+		total += c.Weight
+		if c.Passed {
+			passed += c.Weight
+		}
+	}
+	weight := Case{Weight: g.EquivalenceWeight}.weight()
+	total += weight
+	if grade.Equivalent {
+		passed += weight
+	}
+	grade.Score = score(passed, total)
+	return grade, nil
+}
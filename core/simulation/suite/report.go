@@ -0,0 +1,191 @@
+package suite
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A test of a JUnit or TAP report: a case, or the equivalence check of a grade
+type test struct {
+	name    string
+	passed  bool
+	message string
+	details map[string]string
+}
+
+func (r Report) tests() []test {
+	tests := make([]test, len(r.Cases))
+	for i, c := range r.Cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("tape %q", c.Tape)
+		}
+		message := fmt.Sprintf("expected the tape to be %v, but the outcome was %v",
+			verdict(c.Expected), c.Outcome)
+		tests[i] = test{
+			name:    name,
+			passed:  c.Passed,
+			message: message,
+			details: map[string]string{
+				"tape":     strconv.Quote(c.Tape),
+				"expected": verdict(c.Expected),
+				"outcome":  string(c.Outcome),
+			},
+		}
+	}
+	return tests
+}
+
+func (g Grade) tests() []test {
+	equivalence := test{name: "equivalent to the reference", passed: g.Equivalent}
+	if c := g.Counterexample; !g.Equivalent && c != nil {
+		equivalence.message = fmt.Sprintf("the reference %v tape %q, but the machine %v it",
+			verdict(c.Expected), c.Tape, verdict(c.Accepted))
+		equivalence.details = map[string]string{
+			"tape":     strconv.Quote(c.Tape),
+			"expected": verdict(c.Expected),
+			"got":      verdict(c.Accepted),
+		}
+	}
+	return append(g.Report.tests(), equivalence)
+}
+
+func verdict(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "rejected"
+}
+
+// The report as a JUnit XML document with a single test suite called `name`.
+// The score and the coverage are given as properties of the suite
+func (r Report) JUnit(name string) ([]byte, error) {
+	return junit(name, r.tests(), r)
+}
+
+// The report as a TAP (Test Anything Protocol) version 13 document. The score
+// and the coverage are given in comments at the end
+func (r Report) TAP() string {
+	return tap(r.tests(), r)
+}
+
+// The grade as a JUnit XML document, see `Report.JUnit`. The equivalence check
+// is reported as one more test case
+func (g Grade) JUnit(name string) ([]byte, error) {
+	return junit(name, g.tests(), g.Report)
+}
+
+// The grade as a TAP document, see `Report.TAP`. The equivalence check is
+// reported as one more test
+func (g Grade) TAP() string {
+	return tap(g.tests(), g.Report)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junit(name string, tests []test, r Report) ([]byte, error) {
+	suite := junitSuite{
+		Name:       name,
+		Tests:      len(tests),
+		Properties: []junitProperty{{Name: "score", Value: formatFloat(r.Score)}},
+		Cases:      make([]junitCase, len(tests)),
+	}
+	if c := r.Coverage; c != nil {
+		suite.Properties = append(suite.Properties,
+			junitProperty{Name: "state-coverage", Value: formatFloat(c.States.Percent)},
+			junitProperty{Name: "transition-coverage", Value: formatFloat(c.Transitions.Percent)})
+	}
+	for i, t := range tests {
+		suite.Cases[i] = junitCase{Name: t.name, Classname: name}
+		if !t.passed {
+			suite.Failures++
+			suite.Cases[i].Failure = &junitFailure{
+				Message: t.message,
+				Type:    "WrongOutcome",
+				Text:    t.message,
+			}
+		}
+	}
+	data, err := xml.MarshalIndent(junitSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// Test names end at a newline, and '#' starts a directive
+var tapEscaper = strings.NewReplacer("#", `\#`, "\n", " ")
+
+func tap(tests []test, r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%v\n", len(tests))
+	for i, t := range tests {
+		status := "ok"
+		if !t.passed {
+			status = "not ok"
+		}
+		fmt.Fprintf(&b, "%v %v - %v\n", status, i+1, tapEscaper.Replace(t.name))
+		if t.passed {
+			continue
+		}
+
+		// A YAML block with the details of the failure
+		fmt.Fprintf(&b, "  ---\n  message: %v\n", strconv.Quote(t.message))
+		for _, key := range []string{"tape", "expected", "got", "outcome"} {
+			if v, ok := t.details[key]; ok {
+				fmt.Fprintf(&b, "  %v: %v\n", key, v)
+			}
+		}
+		b.WriteString("  ...\n")
+	}
+	fmt.Fprintf(&b, "# score %v\n", formatFloat(r.Score))
+	if c := r.Coverage; c != nil {
+		fmt.Fprintf(&b, "# states covered %v/%v (%v%%)\n",
+			c.States.Covered, c.States.Total, formatFloat(c.States.Percent))
+		fmt.Fprintf(&b, "# transitions covered %v/%v (%v%%)\n",
+			c.Transitions.Covered, c.Transitions.Total, formatFloat(c.Transitions.Percent))
+	}
+	return b.String()
+}
+
+// Formats a score or percentage with at most two decimals
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,82 @@
+package suite
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/stretchr/testify/assert"
+)
+
+var report = Report{
+	Cases: []CaseResult{
+		{
+			Name:     "odd",
+			Tape:     "a",
+			Expected: true,
+			Accepted: true,
+			Outcome:  simulation.OutcomeAccepted,
+			Passed:   true,
+			Weight:   1,
+		},
+		{
+			Tape:     "aa",
+			Expected: true,
+			Outcome:  simulation.OutcomeRejected,
+			Weight:   1,
+		},
+	},
+	Passed: 1,
+	Failed: 1,
+	Score:  0.5,
+}
+
+func TestJUnit(t *testing.T) {
+	t.Parallel()
+	data, err := report.JUnit("odd-a")
+	assert.NoError(t, err)
+	assert.Equal(t, xml.Header+`<testsuites tests="2" failures="1">
+  <testsuite name="odd-a" tests="2" failures="1">
+    <properties>
+      <property name="score" value="0.5"></property>
+    </properties>
+    <testcase name="odd" classname="odd-a"></testcase>
+    <testcase name="tape &#34;aa&#34;" classname="odd-a">
+      <failure message="expected the tape to be accepted, but the outcome was Rejected" type="WrongOutcome">expected the tape to be accepted, but the outcome was Rejected</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, string(data))
+}
+
+func TestTAP(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, `TAP version 13
+1..2
+ok 1 - odd
+not ok 2 - tape "aa"
+  ---
+  message: "expected the tape to be accepted, but the outcome was Rejected"
+  tape: "aa"
+  expected: accepted
+  outcome: Rejected
+  ...
+# score 0.5
+`, report.TAP())
+
+	grade := Grade{
+		Report:         Report{Cases: []CaseResult{}, Score: 0},
+		Counterexample: &Counterexample{Tape: "b#", Expected: false, Accepted: true},
+	}
+	assert.Equal(t, `TAP version 13
+1..1
+not ok 1 - equivalent to the reference
+  ---
+  message: "the reference rejected tape \"b#\", but the machine accepted it"
+  tape: "b#"
+  expected: rejected
+  got: accepted
+  ...
+# score 0
+`, grade.TAP())
+}
@@ -0,0 +1,121 @@
+// Test suites for machines: lists of tapes with the outcome that a correct
+// machine should have for each of them
+package suite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flapflapio/simulator/core/simulation"
+)
+
+// A tape and whether a correct machine accepts it. Cases are weighted by
+// `Weight` when a suite is scored, where a weight of 0 counts as 1
+type Case struct {
+	Name   string  `json:"Name,omitempty"`
+	Tape   string  `json:"Tape"`
+	Accept bool    `json:"Accept"`
+	Weight float64 `json:"Weight,omitempty"`
+}
+
+// The outcome of running a machine over the tape of a case. A case passes if
+// the simulation finished and accepted the tape exactly when it should have
+type CaseResult struct {
+	Name     string             `json:"Name,omitempty"`
+	Tape     string             `json:"Tape"`
+	Expected bool               `json:"Expected"`
+	Accepted bool               `json:"Accepted"`
+	Outcome  simulation.Outcome `json:"Outcome"`
+	Passed   bool               `json:"Passed"`
+	Weight   float64            `json:"Weight"`
+}
+
+// The results of running a machine over every case of a suite. `Score` is the
+// weighted fraction of the cases that passed, or 1 if there are no cases.
+// `Coverage` is only reported for DFAs
+type Report struct {
+	Cases    []CaseResult `json:"Cases"`
+	Passed   int          `json:"Passed"`
+	Failed   int          `json:"Failed"`
+	Score    float64      `json:"Score"`
+	Coverage *Coverage    `json:"Coverage,omitempty"`
+}
+
+// The weight that a case counts for
+func (c Case) weight() float64 {
+	if c.Weight == 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// Whether a run over the tape of the case passes it: the run finished, and it
+// accepted the tape exactly when it should have
+func (c Case) Passes(res simulation.Result) bool {
+	return res.Outcome != simulation.OutcomeBudgetExhausted && res.Accepted == c.Accept
+}
+
+// Checks that the cases of a suite can be run
+func Validate(cases []Case) error {
+	for i, c := range cases {
+		if c.Weight < 0 {
+			return fmt.Errorf("case %v has a negative weight", i)
+		}
+	}
+	return nil
+}
+
+// Runs the machine over the tape of each case, with `budget` as the budget of
+// each run (see `simulation.Run`). An error is returned if a case cannot be
+// run, or if the context is cancelled
+func Run(
+	ctx context.Context,
+	m simulation.Machine,
+	cases []Case,
+	budget simulation.Budget,
+) (Report, error) {
+	if err := Validate(cases); err != nil {
+		return Report{}, err
+	}
+	report := Report{Cases: make([]CaseResult, len(cases))}
+	tracker := newCoverageTracker(m)
+	var passed, total float64
+	for i, c := range cases {
+		sim := tracker.simulate(m, c.Tape)
+		res, err := simulation.Run(ctx, sim, budget)
+		if err != nil {
+			return Report{}, err
+		}
+		if res.Outcome == simulation.OutcomeCancelled {
+			return Report{}, ctx.Err()
+		}
+		tracker.record(sim, res)
+		result := CaseResult{
+			Name:     c.Name,
+			Tape:     c.Tape,
+			Expected: c.Accept,
+			Accepted: res.Accepted,
+			Outcome:  res.Outcome,
+			Passed:   c.Passes(res),
+			Weight:   c.weight(),
+		}
+		report.Cases[i] = result
+		total += result.Weight
+		if result.Passed {
+			report.Passed++
+			passed += result.Weight
+		} else {
+			report.Failed++
+		}
+	}
+	report.Score = score(passed, total)
+	report.Coverage = tracker.coverage()
+	return report, nil
+}
+
+func score(passed, total float64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return passed / total
+}
@@ -0,0 +1,124 @@
+package suite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/flapflapio/simulator/core/simulation"
+	"github.com/flapflapio/simulator/core/simulation/automata/dfa"
+	"github.com/flapflapio/simulator/core/simulation/machine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+	odda := load(t, dfa.ODDA)
+	report, err := Run(context.Background(), odda, []Case{
+		{Tape: "a", Accept: true, Weight: 3},
+		{Name: "even", Tape: "aa", Accept: false},
+		{Tape: "ab", Accept: false},
+		{Tape: "abbbbbbbb", Accept: true, Weight: 2},
+	}, simulation.Budget{MaxSteps: 5})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, report.Passed)
+	assert.Equal(t, 2, report.Failed)
+	assert.InDelta(t, 4.0/7.0, report.Score, 1e-9)
+	assert.Equal(t, CaseResult{
+		Name:     "even",
+		Tape:     "aa",
+		Expected: false,
+		Accepted: false,
+		Outcome:  simulation.OutcomeRejected,
+		Passed:   true,
+		Weight:   1,
+	}, report.Cases[1])
+	assert.False(t, report.Cases[2].Passed)
+
+	// Runs that do not finish never pass
+	assert.Equal(t, simulation.OutcomeBudgetExhausted, report.Cases[3].Outcome)
+	assert.False(t, report.Cases[3].Passed)
+
+	empty, err := Run(context.Background(), odda, nil, simulation.Budget{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, empty.Score)
+
+	_, err = Run(context.Background(), odda, []Case{{Weight: -1}}, simulation.Budget{})
+	assert.Error(t, err)
+}
+
+func TestGrade(t *testing.T) {
+	t.Parallel()
+	reference, err := dfa.FromRegex("b*a(b*ab*a)*b*", machine.RunesAlphabet("ab"))
+	assert.NoError(t, err)
+	grader := Grader{
+		Reference: reference,
+		Cases: []Case{
+			{Tape: "a", Accept: true},
+			{Tape: "aa", Accept: false},
+			{Tape: "bab", Accept: true},
+		},
+		EquivalenceWeight: 3,
+	}
+
+	grade, err := grader.Grade(context.Background(), load(t, dfa.ODDA))
+	assert.NoError(t, err)
+	assert.True(t, grade.Equivalent)
+	assert.Nil(t, grade.Counterexample)
+	assert.Equal(t, 1.0, grade.Score)
+
+	// Accepts every tape that contains an a
+	grade, err = grader.Grade(context.Background(), load(t, `{
+		"Type": "DFA",
+		"Alphabet": "ab",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }, { "Id": "q1", "Ending": true }],
+		"Transitions": [
+			{ "Start": "q0", "End": "q1", "Symbol": "a" },
+			{ "Start": "q0", "End": "q0", "Symbol": "b" },
+			{ "Start": "q1", "End": "q1", "Symbol": "[ab]" }
+		]
+	}`))
+	assert.NoError(t, err)
+	assert.False(t, grade.Equivalent)
+	assert.Equal(t, &Counterexample{Tape: "aa", Expected: false, Accepted: true},
+		grade.Counterexample)
+	assert.Equal(t, 2, grade.Passed)
+	assert.InDelta(t, 2.0/6.0, grade.Score, 1e-9)
+
+	// A wrong case fails even for a machine that matches the reference
+	grader.Cases = append(grader.Cases, Case{Tape: "b", Accept: true})
+	grade, err = grader.Grade(context.Background(), load(t, dfa.ODDA))
+	assert.NoError(t, err)
+	assert.True(t, grade.Equivalent)
+	assert.Equal(t, &Counterexample{Tape: "b", Expected: true, Accepted: false},
+		grade.Counterexample)
+}
+
+// The counterexample is read by each DFA with its own separator
+func TestGradeWithSeparator(t *testing.T) {
+	t.Parallel()
+	reference, err := dfa.FromRegex("ab", machine.RunesAlphabet("ab"))
+	assert.NoError(t, err)
+
+	// Accepts no tape
+	grade, err := Grader{Reference: reference}.Grade(context.Background(), load(t, `{
+		"Type": "DFA",
+		"Alphabet": ["a", "b"],
+		"Separator": " ",
+		"Start": "q0",
+		"States": [{ "Id": "q0" }],
+		"Transitions": [{ "Start": "q0", "End": "q0", "Symbol": "[ab]" }]
+	}`))
+	assert.NoError(t, err)
+	assert.False(t, grade.Equivalent)
+	assert.Equal(t, &Counterexample{Tape: "a b", Expected: true, Accepted: false},
+		grade.Counterexample)
+}
+
+func load(t *testing.T, doc string) *dfa.DFA {
+	d, err := dfa.Load([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
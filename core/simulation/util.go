@@ -1,6 +1,8 @@
 // Utilities for manipulating simulations
 package simulation
 
+// Steps the simulation until it is done. There is no bound on the number of
+// steps taken, use `Run` for simulations of untrusted machines
 func RunToCompletion(sim Simulation) {
 	for ; !sim.Done(); sim.Step() {
 	}
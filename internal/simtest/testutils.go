@@ -3,13 +3,16 @@ package simtest
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"syscall"
 	"testing"
@@ -254,3 +257,21 @@ func DoRequest(t *testing.T, r *http.Request) *http.Response {
 	}
 	return resp
 }
+
+// Serves a request with the given method, path and body on the handler and
+// returns the recorded response
+func ServeRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+// Decodes the recorded response as a JSON object, fails your test if it is not
+// one
+func DecodeJSON(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	var res map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &res); err != nil {
+		t.Fatalf("response is not JSON: %v", recorder.Body.String())
+	}
+	return res
+}